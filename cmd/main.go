@@ -14,6 +14,9 @@ import (
 	commonoptions "open-cluster-management.io/ocm/pkg/common/options"
 	"open-cluster-management.io/ocm/pkg/version"
 
+	"github.com/stolostron/cloudevents-conductor/pkg/cli/config"
+	"github.com/stolostron/cloudevents-conductor/pkg/cli/events"
+	"github.com/stolostron/cloudevents-conductor/pkg/cli/inspect"
 	"github.com/stolostron/cloudevents-conductor/pkg/server/grpc"
 )
 
@@ -32,6 +35,9 @@ func main() {
 	}
 
 	command.AddCommand(newGRPCCommand())
+	command.AddCommand(newEventsCommand())
+	command.AddCommand(newConfigCommand())
+	command.AddCommand(newInspectCommand())
 
 	if err := command.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
@@ -55,3 +61,113 @@ func newGRPCCommand() *cobra.Command {
 
 	return cmd
 }
+
+func newEventsCommand() *cobra.Command {
+	eventsOpts := events.NewEventsOptions()
+
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Manage the spec events in the Maestro database",
+	}
+	eventsOpts.AddFlags(cmd.PersistentFlags())
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "pending",
+		Short: "List the events that are not reconciled yet with their ages",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return eventsOpts.RunListPending(cmd.Context(), cmd.OutOrStdout())
+		},
+	})
+
+	replayCmd := &cobra.Command{
+		Use:   "replay EVENT_ID...",
+		Short: "Notify the conductor to handle the unreconciled events again",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return eventsOpts.RunReplay(cmd.Context(), cmd.OutOrStdout(), args)
+		},
+	}
+	eventsOpts.AddDryRunFlag(replayCmd.Flags())
+	cmd.AddCommand(replayCmd)
+
+	purgeCmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Purge the reconciled events by their reconciled age",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return eventsOpts.RunPurge(cmd.Context(), cmd.OutOrStdout())
+		},
+	}
+	eventsOpts.AddPurgeFlags(purgeCmd.Flags())
+	cmd.AddCommand(purgeCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "poisoned",
+		Short: "List the events that are poisoned after exceeding the max retries",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return eventsOpts.RunListPoisoned(cmd.Context(), cmd.OutOrStdout())
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "redrive EVENT_ID...",
+		Short: "Re-drive the poisoned events to be handled again",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return eventsOpts.RunRedrive(cmd.Context(), cmd.OutOrStdout(), args)
+		},
+	})
+
+	return cmd
+}
+
+func newConfigCommand() *cobra.Command {
+	configOpts := config.NewConfigOptions()
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the server configuration",
+	}
+	configOpts.AddFlags(cmd.PersistentFlags())
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Validate the server configuration file, optionally probe the database and the TLS files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configOpts.RunValidate(cmd.Context(), cmd.OutOrStdout())
+		},
+	})
+
+	return cmd
+}
+
+func newInspectCommand() *cobra.Command {
+	inspectOpts := inspect.NewInspectOptions()
+
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Print the CloudEvents of the manifest bundles that the conductor serves to the agents",
+	}
+	inspectOpts.AddFlags(cmd.PersistentFlags())
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list CLUSTER_NAME",
+		Short: "List the resources that the agent of the cluster receives when it resyncs",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return inspectOpts.RunList(cmd.Context(), cmd.OutOrStdout(), args[0])
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get RESOURCE_ID...",
+		Short: "Get the resources by their routed IDs, e.g. kube::<namespace>/<name> or maestro::<id>",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return inspectOpts.RunGet(cmd.Context(), cmd.OutOrStdout(), args)
+		},
+	})
+
+	return cmd
+}
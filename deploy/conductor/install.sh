@@ -5,7 +5,6 @@ set -o errexit
 cloudevents_conductor_image="${1:-quay.io/redhat-user-workloads/crt-redhat-acm-tenant/cloudevents-conductor-main@sha256:ab5444876e954bbf2b05d944380de4a927dfac237c8e5691a5304225ba6946fe}"
 
 echo "Prepare the cloudevents-conductor configuration"
-db_pw=$(kubectl -n maestro get secret maestro-db-config -o jsonpath='{.data.password}' | base64 -d)
 cat << EOF | kubectl -n open-cluster-management-hub apply -f -
 apiVersion: v1
 kind: ConfigMap
@@ -18,12 +17,65 @@ data:
       tls_key_file: /var/run/secrets/hub/grpc/serving-cert/tls.key
       client_ca_file: /var/run/secrets/hub/grpc/ca/ca-bundle.crt
     db_config:
-      host: maestro-db.maestro
-      port: 5432
-      name: maestro
-      username: maestro
-      password: ${db_pw}
       sslmode: disable
+    db_secret_ref:
+      namespace: maestro
+      name: maestro-db-config
+EOF
+
+echo "Allow the cloudevents-conductor to read the maestro database secret"
+cat << EOF | kubectl -n maestro apply -f -
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: cloudevents-conductor:db-secret
+rules:
+- apiGroups: [""]
+  resources: ["secrets"]
+  resourceNames: ["maestro-db-config"]
+  verbs: ["get", "list", "watch"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: cloudevents-conductor:db-secret
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: cloudevents-conductor:db-secret
+subjects:
+- kind: ServiceAccount
+  name: grpc-server-sa
+  namespace: open-cluster-management-hub
+EOF
+
+echo "Allow the cloudevents-conductor to elect the leader"
+cat << EOF | kubectl -n open-cluster-management-hub apply -f -
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: cloudevents-conductor:leader-election
+rules:
+- apiGroups: ["coordination.k8s.io"]
+  resources: ["leases"]
+  verbs: ["create"]
+- apiGroups: ["coordination.k8s.io"]
+  resources: ["leases"]
+  resourceNames: ["cloudevents-conductor-lock"]
+  verbs: ["get", "update"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: cloudevents-conductor:leader-election
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: cloudevents-conductor:leader-election
+subjects:
+- kind: ServiceAccount
+  name: grpc-server-sa
+  namespace: open-cluster-management-hub
 EOF
 
 echo "Create the route for the cloudevents-conductor"
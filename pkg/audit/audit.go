@@ -0,0 +1,211 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	grpcauthn "open-cluster-management.io/sdk-go/pkg/server/grpc/authn"
+)
+
+// stdoutPath is the audit log path that writes the audit events to stdout.
+const stdoutPath = "-"
+
+const defaultMaxSize = 100
+
+// Operation is the operation of the router that is audited.
+type Operation string
+
+const (
+	OperationGet          Operation = "get"
+	OperationStatusUpdate Operation = "status_update"
+	OperationCreate       Operation = "create"
+	OperationUpdate       Operation = "update"
+	OperationDelete       Operation = "delete"
+)
+
+// Outcome is the outcome of an audited operation.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Options defines the options of the audit log.
+type Options struct {
+	// Path is the file that the audit events are written to, the events are written to stdout if it is "-".
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// MaxSize is the max size in megabytes of the audit log file before it is rotated.
+	MaxSize int `json:"max_size,omitempty" yaml:"max_size,omitempty"`
+
+	// MaxBackups is the max number of the rotated audit log files to keep, all of them are kept if it is zero.
+	MaxBackups int `json:"max_backups,omitempty" yaml:"max_backups,omitempty"`
+
+	// MaxAge is the max number of days to keep the rotated audit log files, they are not removed by their age if
+	// it is zero.
+	MaxAge int `json:"max_age,omitempty" yaml:"max_age,omitempty"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		Path:    stdoutPath,
+		MaxSize: defaultMaxSize,
+	}
+}
+
+// Validate validates the options, the errors are reported with the given field path.
+func (o *Options) Validate(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if o.Path == "" {
+		errs = append(errs, field.Required(fldPath.Child("path"), "use \"-\" to write to stdout"))
+	}
+	if o.MaxSize < 1 {
+		errs = append(errs, field.Invalid(fldPath.Child("max_size"), o.MaxSize, "must be at least 1"))
+	}
+	if o.MaxBackups < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("max_backups"), o.MaxBackups, "must not be negative"))
+	}
+	if o.MaxAge < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("max_age"), o.MaxAge, "must not be negative"))
+	}
+	return errs
+}
+
+// Event is an audit event, it records who did what on which resource and the outcome.
+type Event struct {
+	Timestamp time.Time `json:"timestamp"`
+	// User and Groups are the identity that is authenticated by the gRPC server, they are empty if the operation
+	// is not requested by an agent, e.g. the resource specs that are sent to the agents.
+	User            string    `json:"user,omitempty"`
+	Groups          []string  `json:"groups,omitempty"`
+	Operation       Operation `json:"operation"`
+	ClusterName     string    `json:"cluster_name,omitempty"`
+	ResourceID      string    `json:"resource_id,omitempty"`
+	Source          string    `json:"source,omitempty"`
+	ResourceVersion int64     `json:"resource_version,omitempty"`
+	EventType       string    `json:"event_type,omitempty"`
+	Outcome         Outcome   `json:"outcome"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// NewEvent creates an audit event of the operation on the resource, the cluster name, the source, the resource
+// version and the event type are filled from the cloudevent if it is not nil, the outcome is a failure if err
+// is not nil.
+func NewEvent(operation Operation, resourceID string, evt *ce.Event, err error) *Event {
+	event := &Event{
+		Operation:  operation,
+		ResourceID: resourceID,
+		Outcome:    OutcomeSuccess,
+	}
+	if err != nil {
+		event.Outcome = OutcomeFailure
+		event.Error = err.Error()
+	}
+	if evt == nil {
+		return event
+	}
+
+	extensions := evt.Extensions()
+	event.Source = evt.Source()
+	if originalSource, err := cloudeventstypes.ToString(extensions[types.ExtensionOriginalSource]); err == nil {
+		event.Source = originalSource
+	}
+	if clusterName, err := cloudeventstypes.ToString(extensions[types.ExtensionClusterName]); err == nil {
+		event.ClusterName = clusterName
+	}
+	if resourceVersion, err := cloudeventstypes.ToInteger(extensions[types.ExtensionResourceVersion]); err == nil {
+		event.ResourceVersion = int64(resourceVersion)
+	}
+	event.EventType = evt.Type()
+	return event
+}
+
+// Logger writes the audit events as JSON lines. A nil Logger discards the audit events, so the audit can be
+// disabled by not creating the Logger.
+type Logger struct {
+	sync.Mutex
+	writer io.Writer
+	now    func() time.Time
+}
+
+// NewLogger creates a Logger that writes to the file of the options, the file is rotated when it exceeds
+// the max size.
+func NewLogger(options *Options) *Logger {
+	if options.Path == stdoutPath {
+		return NewWriterLogger(os.Stdout)
+	}
+
+	return NewWriterLogger(&lumberjack.Logger{
+		Filename:   options.Path,
+		MaxSize:    options.MaxSize,
+		MaxBackups: options.MaxBackups,
+		MaxAge:     options.MaxAge,
+	})
+}
+
+// NewWriterLogger creates a Logger that writes to the writer.
+func NewWriterLogger(writer io.Writer) *Logger {
+	return &Logger{
+		writer: writer,
+		now:    time.Now,
+	}
+}
+
+// Log writes the audit event, the user and the groups are filled from the authenticated identity in the
+// context if they are not set. The audit event is dropped with an error log if it cannot be written.
+func (l *Logger) Log(ctx context.Context, event *Event) {
+	if l == nil {
+		return
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = l.now().UTC()
+	}
+	if event.User == "" {
+		if user, ok := ctx.Value(grpcauthn.ContextUserKey).(string); ok {
+			event.User = user
+		}
+	}
+	if len(event.Groups) == 0 {
+		if groups, ok := ctx.Value(grpcauthn.ContextGroupsKey).([]string); ok {
+			event.Groups = groups
+		}
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		klog.Errorf("failed to marshal the audit event of %s %s: %v", event.Operation, event.ResourceID, err)
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if _, err := l.writer.Write(append(data, '\n')); err != nil {
+		klog.Errorf("failed to write the audit event of %s %s: %v", event.Operation, event.ResourceID, err)
+	}
+}
+
+// Close closes the audit log file, it does nothing if the audit events are written to stdout.
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if closer, ok := l.writer.(*lumberjack.Logger); ok {
+		return closer.Close()
+	}
+	return nil
+}
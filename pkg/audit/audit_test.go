@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	grpcauthn "open-cluster-management.io/sdk-go/pkg/server/grpc/authn"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	cases := []struct {
+		name           string
+		mutate         func(options *Options)
+		expectedFields []string
+	}{
+		{
+			name:   "default",
+			mutate: func(options *Options) {},
+		},
+		{
+			name: "invalid",
+			mutate: func(options *Options) {
+				options.Path = ""
+				options.MaxSize = 0
+				options.MaxBackups = -1
+				options.MaxAge = -1
+			},
+			expectedFields: []string{"audit.path", "audit.max_size", "audit.max_backups", "audit.max_age"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			options := NewOptions()
+			c.mutate(options)
+
+			fields := []string{}
+			for _, err := range options.Validate(field.NewPath("audit")) {
+				fields = append(fields, err.Field)
+			}
+			assert.ElementsMatch(t, c.expectedFields, fields)
+		})
+	}
+}
+
+func TestLogger(t *testing.T) {
+	statusEvent := types.NewEventBuilder("cluster1-work-agent", types.CloudEventsType{
+		CloudEventsDataType: payload.ManifestBundleEventDataType,
+		SubResource:         types.SubResourceStatus,
+		Action:              types.UpdateRequestAction,
+	}).WithResourceID("r1").
+		WithResourceVersion(2).
+		WithClusterName("cluster1").
+		WithOriginalSource("maestro").
+		NewEvent()
+
+	buf := &bytes.Buffer{}
+	logger := NewWriterLogger(buf)
+	logger.now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	ctx := context.WithValue(context.Background(), grpcauthn.ContextUserKey, "system:open-cluster-management:cluster1")
+	ctx = context.WithValue(ctx, grpcauthn.ContextGroupsKey, []string{"system:open-cluster-management:managed-clusters"})
+	logger.Log(ctx, NewEvent(OperationStatusUpdate, "r1", &statusEvent, nil))
+	logger.Log(context.Background(), NewEvent(OperationDelete, "maestro::r2", nil, fmt.Errorf("not connected")))
+
+	// the audit events are discarded by a nil logger
+	var disabled *Logger
+	disabled.Log(ctx, NewEvent(OperationGet, "r1", nil, nil))
+	assert.NoError(t, disabled.Close())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	events := []Event{}
+	for _, line := range lines {
+		event := Event{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &event))
+		events = append(events, event)
+	}
+	assert.Equal(t, []Event{
+		{
+			Timestamp:       time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+			User:            "system:open-cluster-management:cluster1",
+			Groups:          []string{"system:open-cluster-management:managed-clusters"},
+			Operation:       OperationStatusUpdate,
+			ClusterName:     "cluster1",
+			ResourceID:      "r1",
+			Source:          "maestro",
+			ResourceVersion: 2,
+			EventType:       "io.open-cluster-management.works.v1alpha1.manifestbundles.status.update_request",
+			Outcome:         OutcomeSuccess,
+		},
+		{
+			Timestamp:  time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+			Operation:  OperationDelete,
+			ResourceID: "maestro::r2",
+			Outcome:    OutcomeFailure,
+			Error:      "not connected",
+		},
+	}, events)
+}
+
+func TestFileLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	options := NewOptions()
+	options.Path = path
+
+	logger := NewLogger(options)
+	logger.Log(context.Background(), NewEvent(OperationGet, "kube::ns/work", nil, nil))
+	assert.NoError(t, logger.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"operation":"get","resource_id":"kube::ns/work","outcome":"success"`)
+}
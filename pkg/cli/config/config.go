@@ -0,0 +1,102 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/pflag"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/stolostron/cloudevents-conductor/pkg/server/grpc"
+)
+
+// defaultProbeTimeout is the default timeout to probe the database connectivity.
+const defaultProbeTimeout = 10 * time.Second
+
+// ConfigOptions defines the options of the config commands.
+type ConfigOptions struct {
+	GRPCServerConfigFile string
+	Kubeconfig           string
+	ProbeDatabase        bool
+	ProbeTLSFiles        bool
+	ProbeTimeout         time.Duration
+}
+
+func NewConfigOptions() *ConfigOptions {
+	return &ConfigOptions{
+		ProbeTimeout: defaultProbeTimeout,
+	}
+}
+
+func (o *ConfigOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.GRPCServerConfigFile, "server-config", o.GRPCServerConfigFile, "Location of the server configuration file.")
+	fs.StringVar(&o.Kubeconfig, "kubeconfig", o.Kubeconfig,
+		"Location of the kubeconfig to resolve the database secret of the db_secret_ref, the in-cluster config is used if it is empty.")
+	fs.BoolVar(&o.ProbeDatabase, "probe-db", o.ProbeDatabase, "Check the database can be connected with the db_config.")
+	fs.BoolVar(&o.ProbeTLSFiles, "probe-tls", o.ProbeTLSFiles, "Check the TLS files of the grpc_config can be loaded.")
+	fs.DurationVar(&o.ProbeTimeout, "probe-timeout", o.ProbeTimeout, "The timeout to probe the database connectivity.")
+}
+
+// RunValidate validates the server configuration file, the errors are printed one per line and an error is
+// returned if the configuration is invalid.
+func (o *ConfigOptions) RunValidate(ctx context.Context, out io.Writer) error {
+	if o.GRPCServerConfigFile == "" {
+		return fmt.Errorf("the server configuration file is required")
+	}
+
+	grpcServerConfig, err := grpc.LoadGRPCServerConfig(o.GRPCServerConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to load gRPC server config %s: %w", o.GRPCServerConfigFile, err)
+	}
+
+	errs := []error{}
+	if err := grpcServerConfig.Validate(); err != nil {
+		// print the invalid fields one per line
+		if agg, ok := err.(utilerrors.Aggregate); ok {
+			errs = append(errs, utilerrors.Flatten(agg).Errors()...)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	if o.ProbeTLSFiles {
+		if err := grpcServerConfig.ProbeTLSFiles(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// the database is not probed with an invalid db_config
+	if o.ProbeDatabase && len(errs) == 0 {
+		probeCtx, cancel := context.WithTimeout(ctx, o.ProbeTimeout)
+		defer cancel()
+		if err := o.probeDatabase(probeCtx, grpcServerConfig); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintln(out, err)
+		}
+		return fmt.Errorf("gRPC server config %s is invalid", o.GRPCServerConfigFile)
+	}
+
+	fmt.Fprintf(out, "gRPC server config %s is valid\n", o.GRPCServerConfigFile)
+	return nil
+}
+
+func (o *ConfigOptions) probeDatabase(ctx context.Context, grpcServerConfig *grpc.GRPCServerConfig) error {
+	var kubeClient kubernetes.Interface
+	if grpcServerConfig.DBSecretRef != nil {
+		kubeConfig, err := clientcmd.BuildConfigFromFlags("", o.Kubeconfig)
+		if err != nil {
+			return fmt.Errorf("failed to load kubeconfig: %w", err)
+		}
+		if kubeClient, err = kubernetes.NewForConfig(kubeConfig); err != nil {
+			return err
+		}
+	}
+	return grpcServerConfig.ProbeDatabase(ctx, kubeClient)
+}
@@ -0,0 +1,246 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	maestrodb "github.com/openshift-online/maestro/pkg/db"
+	"github.com/openshift-online/maestro/pkg/db/db_session"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+
+	"github.com/stolostron/cloudevents-conductor/pkg/server/grpc"
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db"
+	dbevent "github.com/stolostron/cloudevents-conductor/pkg/services/db/event"
+)
+
+// defaultPurgeBatchSize is the default max number of the reconciled events deleted in one transaction.
+const defaultPurgeBatchSize = 1000
+
+// EventsOptions defines the options of the events commands, the database is connected with the db_config
+// of the gRPC server configuration.
+type EventsOptions struct {
+	GRPCServerConfigFile string
+	Kubeconfig           string
+	Channel              string
+	DryRun               bool
+	OlderThan            time.Duration
+	PurgeBatchSize       int
+}
+
+func NewEventsOptions() *EventsOptions {
+	return &EventsOptions{
+		PurgeBatchSize: defaultPurgeBatchSize,
+	}
+}
+
+func (o *EventsOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.GRPCServerConfigFile, "server-config", o.GRPCServerConfigFile, "Location of the server configuration file.")
+	fs.StringVar(&o.Kubeconfig, "kubeconfig", o.Kubeconfig,
+		"Location of the kubeconfig to resolve the database secret of the db_secret_ref, the in-cluster config is used if it is empty.")
+	fs.StringVar(&o.Channel, "channel", o.Channel,
+		"The pg channel that the conductor listens on for the events, the listener_config.channel of the server configuration is used if it is empty.")
+}
+
+// AddDryRunFlag adds the flag to print the changes of a command without making them.
+func (o *EventsOptions) AddDryRunFlag(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.DryRun, "dry-run", o.DryRun, "Print the changes without making them.")
+}
+
+// AddPurgeFlags adds the flags of the purge command.
+func (o *EventsOptions) AddPurgeFlags(fs *pflag.FlagSet) {
+	o.AddDryRunFlag(fs)
+	fs.DurationVar(&o.OlderThan, "older-than", o.OlderThan,
+		"Purge the events that were reconciled longer ago than this, all of the reconciled events are purged if it is zero.")
+	fs.IntVar(&o.PurgeBatchSize, "batch-size", o.PurgeBatchSize,
+		"The max number of the reconciled events deleted in one transaction.")
+}
+
+// RunListPending prints the events that are not reconciled yet with their ages.
+func (o *EventsOptions) RunListPending(ctx context.Context, out io.Writer) error {
+	return o.withSessionFactory(ctx, func(sessionFactory maestrodb.SessionFactory) error {
+		pendingEvents, svcErr := dbevent.NewEventService(sessionFactory).FindAllUnreconciledEvents(ctx)
+		if svcErr != nil {
+			return fmt.Errorf("failed to list unreconciled events: %s", svcErr.Error())
+		}
+
+		now := time.Now()
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "EVENT ID\tSOURCE\tRESOURCE ID\tTYPE\tAGE")
+		for _, e := range pendingEvents {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.ID, e.Source, e.SourceID, e.EventType,
+				duration.HumanDuration(now.Sub(e.CreatedAt)))
+		}
+		return w.Flush()
+	})
+}
+
+// RunReplay notifies the conductor to handle the given unreconciled events again, e.g. the events that were
+// missed by the listener. The poisoned events must be re-driven instead.
+func (o *EventsOptions) RunReplay(ctx context.Context, out io.Writer, eventIDs []string) error {
+	if len(eventIDs) == 0 {
+		return fmt.Errorf("at least one event ID is required")
+	}
+
+	return o.withSessionFactory(ctx, func(sessionFactory maestrodb.SessionFactory) error {
+		events := dbevent.NewEventService(sessionFactory)
+		store := dbevent.NewPoisonedEventStore(sessionFactory)
+		for _, eventID := range eventIDs {
+			event, svcErr := events.Get(ctx, eventID)
+			if svcErr != nil {
+				return fmt.Errorf("failed to get event %s: %s", eventID, svcErr.Error())
+			}
+			// the reconciled events are skipped by the conductor
+			if event.ReconciledDate != nil {
+				return fmt.Errorf("event %s was reconciled at %s", eventID, event.ReconciledDate.Format(time.RFC3339))
+			}
+			poisoned, err := store.Get(ctx, eventID)
+			if err != nil {
+				return err
+			}
+			if poisoned != nil {
+				return fmt.Errorf("event %s is poisoned, re-drive it instead", eventID)
+			}
+
+			if o.DryRun {
+				fmt.Fprintf(out, "event %s would be replayed (dry run)\n", eventID)
+				continue
+			}
+			if err := dbevent.NotifyEvent(ctx, sessionFactory, o.Channel, eventID); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "event %s is replayed\n", eventID)
+		}
+		return nil
+	})
+}
+
+// RunPurge deletes the events that were reconciled longer ago than the older-than duration in batches, all of the
+// reconciled events are deleted at once if it is zero. The unreconciled events are never purged.
+func (o *EventsOptions) RunPurge(ctx context.Context, out io.Writer) error {
+	if o.OlderThan < 0 {
+		return fmt.Errorf("the older-than duration must not be negative, but got %s", o.OlderThan)
+	}
+
+	return o.withSessionFactory(ctx, func(sessionFactory maestrodb.SessionFactory) error {
+		purger := dbevent.NewReconciledEventsPurger(sessionFactory)
+		reconciledBefore := time.Now().Add(-o.OlderThan)
+		if o.DryRun {
+			count, err := purger.CountReconciledEvents(ctx, reconciledBefore)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%d events reconciled before %s would be purged (dry run)\n", count,
+				reconciledBefore.Format(time.RFC3339))
+			return nil
+		}
+
+		if o.OlderThan == 0 {
+			if svcErr := dbevent.NewEventService(sessionFactory).DeleteAllReconciledEvents(ctx); svcErr != nil {
+				return fmt.Errorf("failed to purge reconciled events: %s", svcErr.Error())
+			}
+			fmt.Fprintln(out, "all of the reconciled events are purged")
+			return nil
+		}
+
+		purged, err := purger.PurgeReconciledEvents(ctx, reconciledBefore, o.PurgeBatchSize)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d events reconciled before %s are purged\n", purged, reconciledBefore.Format(time.RFC3339))
+		return nil
+	})
+}
+
+// RunListPoisoned prints the poisoned events.
+func (o *EventsOptions) RunListPoisoned(ctx context.Context, out io.Writer) error {
+	return o.withSessionFactory(ctx, func(sessionFactory maestrodb.SessionFactory) error {
+		poisonedEvents, err := dbevent.NewPoisonedEventStore(sessionFactory).List(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "EVENT ID\tSOURCE\tRESOURCE ID\tTYPE\tATTEMPTS\tPOISONED AT\tLAST ERROR")
+		for _, e := range poisonedEvents {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", e.EventID, e.Source, e.SourceID, e.EventType,
+				e.Attempts, e.PoisonedAt.Format(time.RFC3339), e.LastError)
+		}
+		return w.Flush()
+	})
+}
+
+// RunRedrive removes the given events from the poisoned events and notifies the conductor to handle them again.
+func (o *EventsOptions) RunRedrive(ctx context.Context, out io.Writer, eventIDs []string) error {
+	if len(eventIDs) == 0 {
+		return fmt.Errorf("at least one event ID is required")
+	}
+
+	return o.withSessionFactory(ctx, func(sessionFactory maestrodb.SessionFactory) error {
+		store := dbevent.NewPoisonedEventStore(sessionFactory)
+		for _, eventID := range eventIDs {
+			poisoned, err := store.Get(ctx, eventID)
+			if err != nil {
+				return err
+			}
+			if poisoned == nil {
+				return fmt.Errorf("event %s is not poisoned", eventID)
+			}
+
+			if err := store.Delete(ctx, eventID); err != nil {
+				return err
+			}
+			if err := dbevent.NotifyEvent(ctx, sessionFactory, o.Channel, eventID); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "event %s is re-driven\n", eventID)
+		}
+		return nil
+	})
+}
+
+// withSessionFactory calls fn with the session factory of the db_config, the channel is defaulted to the one that
+// the conductor listens on if it is not set.
+func (o *EventsOptions) withSessionFactory(ctx context.Context,
+	fn func(sessionFactory maestrodb.SessionFactory) error) error {
+	grpcServerConfig, err := grpc.LoadGRPCServerConfig(o.GRPCServerConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to load gRPC server config: %w", err)
+	}
+	if o.Channel == "" {
+		o.Channel = db.NewListenerOptions().Channel
+		if grpcServerConfig.ListenerConfig != nil {
+			o.Channel = grpcServerConfig.ListenerConfig.Channel
+		}
+	}
+
+	var kubeClient kubernetes.Interface
+	if grpcServerConfig.DBSecretRef != nil {
+		kubeConfig, err := clientcmd.BuildConfigFromFlags("", o.Kubeconfig)
+		if err != nil {
+			return fmt.Errorf("failed to load kubeconfig: %w", err)
+		}
+		if kubeClient, err = kubernetes.NewForConfig(kubeConfig); err != nil {
+			return err
+		}
+	}
+	dbConfig, err := grpcServerConfig.ResolveDBConfig(ctx, kubeClient)
+	if err != nil {
+		return err
+	}
+
+	sessionFactory := db_session.NewProdFactory(dbConfig)
+	defer func() {
+		if err := sessionFactory.Close(); err != nil {
+			klog.Errorf("failed to close session factory: %v", err)
+		}
+	}()
+
+	return fn(sessionFactory)
+}
@@ -0,0 +1,286 @@
+package inspect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	cetypes "github.com/cloudevents/sdk-go/v2/types"
+	"github.com/openshift-online/maestro/pkg/constants"
+	"github.com/openshift-online/maestro/pkg/db/db_session"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+	workclientset "open-cluster-management.io/api/client/work/clientset/versioned"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions"
+	ocmservices "open-cluster-management.io/ocm/pkg/server/services"
+	"open-cluster-management.io/ocm/pkg/server/services/work"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/stolostron/cloudevents-conductor/pkg/server/grpc"
+	"github.com/stolostron/cloudevents-conductor/pkg/services"
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db"
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db/resource"
+	dbstatusevent "github.com/stolostron/cloudevents-conductor/pkg/services/db/statusevent"
+)
+
+// The output formats of the inspect commands.
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// supportedSources are the sources of the backends that are registered to the router by the conductor.
+var supportedSources = []string{ocmservices.CloudEventsSourceKube, constants.DefaultSourceID}
+
+// InspectOptions defines the options of the inspect commands, the resources are read with the same router
+// and backends as the conductor, the database is connected with the db_config of the gRPC server configuration
+// and the ManifestWorks are read from the hub of the kubeconfig.
+type InspectOptions struct {
+	GRPCServerConfigFile string
+	Kubeconfig           string
+	Sources              []string
+	Output               string
+}
+
+func NewInspectOptions() *InspectOptions {
+	return &InspectOptions{
+		Sources: slices.Clone(supportedSources),
+		Output:  OutputTable,
+	}
+}
+
+func (o *InspectOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.GRPCServerConfigFile, "server-config", o.GRPCServerConfigFile,
+		"Location of the server configuration file, it is required for the maestro source.")
+	fs.StringVar(&o.Kubeconfig, "kubeconfig", o.Kubeconfig,
+		"Location of the kubeconfig of the hub, the in-cluster config is used if it is empty.")
+	fs.StringSliceVar(&o.Sources, "source", o.Sources,
+		fmt.Sprintf("The sources of the resources to inspect, one or more of %s.", strings.Join(supportedSources, ", ")))
+	fs.StringVarP(&o.Output, "output", "o", o.Output,
+		fmt.Sprintf("The output format, one of %s, %s or %s.", OutputTable, OutputJSON, OutputYAML))
+}
+
+// Validate validates the sources and the output format.
+func (o *InspectOptions) Validate() error {
+	if len(o.Sources) == 0 {
+		return fmt.Errorf("at least one source is required")
+	}
+	for _, source := range o.Sources {
+		if !slices.Contains(supportedSources, source) {
+			return fmt.Errorf("unsupported source %q, it must be one of %s", source, strings.Join(supportedSources, ", "))
+		}
+	}
+	if o.Output != OutputTable && o.Output != OutputJSON && o.Output != OutputYAML {
+		return fmt.Errorf("unsupported output %q, it must be one of %s, %s or %s", o.Output, OutputTable, OutputJSON, OutputYAML)
+	}
+	return nil
+}
+
+// RunList prints the cloudevents of the resources that an agent of the cluster receives when it resyncs.
+func (o *InspectOptions) RunList(ctx context.Context, out io.Writer, clusterName string) error {
+	return o.withRouterService(ctx, clusterName, func(router *services.RouterService) error {
+		evts, err := router.List(types.ListOptions{
+			ClusterName:         clusterName,
+			CloudEventsDataType: payload.ManifestBundleEventDataType,
+		})
+		if err != nil {
+			return err
+		}
+		return printEvents(out, o.Output, evts)
+	})
+}
+
+// RunGet prints the cloudevents of the routed resource IDs, e.g. `kube::<namespace>/<name>` or
+// `maestro::<resource id>`, that an agent receives when the resources are changed.
+func (o *InspectOptions) RunGet(ctx context.Context, out io.Writer, resourceIDs []string) error {
+	if len(resourceIDs) == 0 {
+		return fmt.Errorf("at least one resource ID is required")
+	}
+
+	return o.withRouterService(ctx, kubeNamespaceOf(resourceIDs), func(router *services.RouterService) error {
+		evts := []*ce.Event{}
+		for _, resourceID := range resourceIDs {
+			evt, err := router.Get(ctx, resourceID)
+			if err != nil {
+				return fmt.Errorf("failed to get resource %s: %w", resourceID, err)
+			}
+			evts = append(evts, evt)
+		}
+		return printEvents(out, o.Output, evts)
+	})
+}
+
+// withRouterService registers the backends of the sources to a router and passes it to fn, the ManifestWorks
+// are only cached in the namespace if it is not empty.
+func (o *InspectOptions) withRouterService(ctx context.Context, namespace string,
+	fn func(router *services.RouterService) error) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	router := services.NewRouterService()
+	for _, source := range o.Sources {
+		switch source {
+		case ocmservices.CloudEventsSourceKube:
+			backend, err := o.newKubeBackend(ctx, namespace)
+			if err != nil {
+				return err
+			}
+			if err := router.Register(backend); err != nil {
+				return err
+			}
+		case constants.DefaultSourceID:
+			backend, closeFunc, err := o.newDBBackend(ctx)
+			if err != nil {
+				return err
+			}
+			defer closeFunc()
+			if err := router.Register(backend); err != nil {
+				return err
+			}
+		}
+	}
+
+	return fn(router)
+}
+
+func (o *InspectOptions) newKubeBackend(ctx context.Context, namespace string) (*services.KubeBackend, error) {
+	kubeConfig, err := o.kubeConfig()
+	if err != nil {
+		return nil, err
+	}
+	workClient, err := workclientset.NewForConfig(kubeConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if namespace == "" {
+		namespace = metav1.NamespaceAll
+	}
+	workInformerFactory := workinformers.NewSharedInformerFactoryWithOptions(workClient, 0,
+		workinformers.WithNamespace(namespace))
+	workInformer := workInformerFactory.Work().V1().ManifestWorks()
+	workService := work.NewWorkService(workClient, workInformer)
+	// the informer is added to the factory before the factory is started
+	workInformer.Informer()
+
+	workInformerFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), workInformer.Informer().HasSynced) {
+		return nil, fmt.Errorf("failed to wait for the ManifestWorks to be cached")
+	}
+	return services.NewKubeBackend(workService, workInformer), nil
+}
+
+// newDBBackend connects the database of the gRPC server configuration, the returned function closes the
+// connection. The backend is not registered to the spec controller, since no event is handled.
+func (o *InspectOptions) newDBBackend(ctx context.Context) (*services.DBBackend, func(), error) {
+	if o.GRPCServerConfigFile == "" {
+		return nil, nil, fmt.Errorf("the server configuration file is required for the %s source", constants.DefaultSourceID)
+	}
+	grpcServerConfig, err := grpc.LoadGRPCServerConfig(o.GRPCServerConfigFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load gRPC server config: %w", err)
+	}
+
+	var kubeClient kubernetes.Interface
+	if grpcServerConfig.DBSecretRef != nil {
+		kubeConfig, err := o.kubeConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+		if kubeClient, err = kubernetes.NewForConfig(kubeConfig); err != nil {
+			return nil, nil, err
+		}
+	}
+	dbConfig, err := grpcServerConfig.ResolveDBConfig(ctx, kubeClient)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sessionFactory := db_session.NewProdFactory(dbConfig)
+	closeFunc := func() {
+		if err := sessionFactory.Close(); err != nil {
+			klog.Errorf("failed to close session factory: %v", err)
+		}
+	}
+	dbService := db.NewDBWorkService(resource.NewResourceService(sessionFactory),
+		dbstatusevent.NewStatusEventService(sessionFactory))
+	return services.NewDBBackend(dbService, nil), closeFunc, nil
+}
+
+func (o *InspectOptions) kubeConfig() (*rest.Config, error) {
+	kubeConfig, err := clientcmd.BuildConfigFromFlags("", o.Kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return kubeConfig, nil
+}
+
+// kubeNamespaceOf returns the namespace of the kube resource IDs if all of them are in the same namespace,
+// otherwise an empty namespace is returned.
+func kubeNamespaceOf(resourceIDs []string) string {
+	namespace := ""
+	for _, resourceID := range resourceIDs {
+		id, found := strings.CutPrefix(resourceID, ocmservices.CloudEventsSourceKube+"::")
+		if !found {
+			continue
+		}
+		ns, _, _ := strings.Cut(id, "/")
+		if namespace != "" && namespace != ns {
+			return ""
+		}
+		namespace = ns
+	}
+	return namespace
+}
+
+// printEvents prints the cloudevents as a table, or as a JSON or YAML list of the structured cloudevents.
+func printEvents(out io.Writer, output string, evts []*ce.Event) error {
+	switch output {
+	case OutputJSON, OutputYAML:
+		data, err := json.MarshalIndent(evts, "", "  ")
+		if err != nil {
+			return err
+		}
+		if output == OutputYAML {
+			if data, err = yaml.JSONToYAML(data); err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprintln(out, strings.TrimSuffix(string(data), "\n"))
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tRESOURCE ID\tVERSION\tCLUSTER\tDELETION\tTYPE")
+	for _, evt := range evts {
+		extensions := evt.Extensions()
+		resourceID, _ := cetypes.ToString(extensions[types.ExtensionResourceID])
+		resourceVersion, _ := cetypes.ToInteger(extensions[types.ExtensionResourceVersion])
+		clusterName, _ := cetypes.ToString(extensions[types.ExtensionClusterName])
+		deletion := ""
+		if deletionTimestamp, err := cetypes.ToTime(extensions[types.ExtensionDeletionTimestamp]); err == nil {
+			deletion = deletionTimestamp.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", evt.Source(), resourceID, resourceVersion, clusterName,
+			deletion, evt.Type())
+	}
+	return w.Flush()
+}
@@ -0,0 +1,180 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	"github.com/openshift-online/maestro/pkg/constants"
+	"github.com/stretchr/testify/assert"
+	ocmservices "open-cluster-management.io/ocm/pkg/server/services"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"sigs.k8s.io/yaml"
+)
+
+func TestInspectOptionsValidate(t *testing.T) {
+	cases := []struct {
+		name        string
+		sources     []string
+		output      string
+		expectedErr string
+	}{
+		{
+			name:    "default options",
+			sources: supportedSources,
+			output:  OutputTable,
+		},
+		{
+			name:    "single source with yaml output",
+			sources: []string{constants.DefaultSourceID},
+			output:  OutputYAML,
+		},
+		{
+			name:        "no source",
+			output:      OutputJSON,
+			expectedErr: "at least one source is required",
+		},
+		{
+			name:        "unsupported source",
+			sources:     []string{ocmservices.CloudEventsSourceKube, "unknown"},
+			output:      OutputTable,
+			expectedErr: `unsupported source "unknown"`,
+		},
+		{
+			name:        "unsupported output",
+			sources:     supportedSources,
+			output:      "wide",
+			expectedErr: `unsupported output "wide"`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			options := NewInspectOptions()
+			options.Sources = c.sources
+			options.Output = c.output
+			err := options.Validate()
+			if c.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), c.expectedErr)
+			}
+		})
+	}
+}
+
+func TestKubeNamespaceOf(t *testing.T) {
+	kubeID := func(id string) string { return ocmservices.CloudEventsSourceKube + "::" + id }
+	cases := []struct {
+		name        string
+		resourceIDs []string
+		expected    string
+	}{
+		{
+			name:        "single kube resource",
+			resourceIDs: []string{kubeID("cluster1/work1")},
+			expected:    "cluster1",
+		},
+		{
+			name:        "kube resources in the same namespace",
+			resourceIDs: []string{kubeID("cluster1/work1"), constants.DefaultSourceID + "::r1", kubeID("cluster1/work2")},
+			expected:    "cluster1",
+		},
+		{
+			name:        "kube resources in different namespaces",
+			resourceIDs: []string{kubeID("cluster1/work1"), kubeID("cluster2/work1")},
+			expected:    "",
+		},
+		{
+			name:        "no kube resource",
+			resourceIDs: []string{constants.DefaultSourceID + "::r1"},
+			expected:    "",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, kubeNamespaceOf(c.resourceIDs))
+		})
+	}
+}
+
+func TestPrintEvents(t *testing.T) {
+	deletionTimestamp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	evts := []*ce.Event{
+		newSpecEvent("r1", 2, nil),
+		newSpecEvent("r2", 1, &deletionTimestamp),
+	}
+
+	t.Run("table", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		assert.NoError(t, printEvents(out, OutputTable, evts))
+		lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+		if !assert.Len(t, lines, 3) {
+			return
+		}
+		assert.Equal(t, []string{"SOURCE", "RESOURCE", "ID", "VERSION", "CLUSTER", "DELETION", "TYPE"},
+			strings.Fields(lines[0]))
+		assert.Equal(t, []string{"maestro", "r1", "2", "cluster1",
+			"io.open-cluster-management.works.v1alpha1.manifestbundles.spec.update_request"}, strings.Fields(lines[1]))
+		assert.Equal(t, []string{"maestro", "r2", "1", "cluster1", "2026-01-02T03:04:05Z",
+			"io.open-cluster-management.works.v1alpha1.manifestbundles.spec.update_request"}, strings.Fields(lines[2]))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		assert.NoError(t, printEvents(out, OutputJSON, evts))
+		printed := []ce.Event{}
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+		assertPrintedEvents(t, evts, printed)
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		assert.NoError(t, printEvents(out, OutputYAML, evts))
+		data, err := yaml.YAMLToJSON(out.Bytes())
+		assert.NoError(t, err)
+		printed := []ce.Event{}
+		assert.NoError(t, json.Unmarshal(data, &printed))
+		assertPrintedEvents(t, evts, printed)
+	})
+
+	t.Run("no events", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		assert.NoError(t, printEvents(out, OutputJSON, []*ce.Event{}))
+		assert.Equal(t, "[]\n", out.String())
+	})
+}
+
+func newSpecEvent(resourceID string, resourceVersion int64, deletionTimestamp *time.Time) *ce.Event {
+	builder := types.NewEventBuilder("maestro", types.CloudEventsType{
+		CloudEventsDataType: payload.ManifestBundleEventDataType,
+		SubResource:         types.SubResourceSpec,
+		Action:              types.UpdateRequestAction,
+	}).WithResourceID(resourceID).
+		WithResourceVersion(resourceVersion).
+		WithClusterName("cluster1")
+	if deletionTimestamp != nil {
+		builder = builder.WithDeletionTimestamp(*deletionTimestamp)
+	}
+	evt := builder.NewEvent()
+	return &evt
+}
+
+// assertPrintedEvents asserts the printed events are the structured cloudevents of the expected events.
+func assertPrintedEvents(t *testing.T, expected []*ce.Event, printed []ce.Event) {
+	if !assert.Len(t, printed, len(expected)) {
+		return
+	}
+	for i := range expected {
+		assert.Equal(t, expected[i].ID(), printed[i].ID())
+		assert.Equal(t, expected[i].Source(), printed[i].Source())
+		assert.Equal(t, expected[i].Type(), printed[i].Type())
+		assert.Equal(t, expected[i].Extensions()[types.ExtensionResourceID], printed[i].Extensions()[types.ExtensionResourceID])
+		assert.Equal(t, expected[i].Extensions()[types.ExtensionClusterName], printed[i].Extensions()[types.ExtensionClusterName])
+	}
+}
@@ -2,7 +2,10 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
 	"syscall"
 	"time"
 
@@ -13,33 +16,72 @@ import (
 	"github.com/stolostron/cloudevents-conductor/pkg/utils/maestro"
 	kubeapierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	"k8s.io/klog/v2"
+	clusterclientset "open-cluster-management.io/api/client/cluster/clientset/versioned"
 	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions/cluster/v1"
 	clusterlisters "open-cluster-management.io/api/client/cluster/listers/cluster/v1"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 )
 
+// ConsumerCleanupFinalizer is added to the joined managed clusters, it ensures the consumer and its resources are
+// cleaned up from the maestro before the managed cluster is removed.
+const ConsumerCleanupFinalizer = "cloudevents-conductor.open-cluster-management.io/consumer-cleanup"
+
+// ResourceDeletionPolicy defines how the maestro resources of a consumer are handled when its managed cluster is removed.
+type ResourceDeletionPolicy string
+
+const (
+	// ResourceDeletionPolicyDelete deletes the resources from the managed cluster and waits for the agent to confirm
+	// the deletion. If the managed cluster is not available, or the agent does not confirm the deletion within the
+	// grace period, the resources are removed from the maestro directly.
+	ResourceDeletionPolicyDelete ResourceDeletionPolicy = "Delete"
+
+	// ResourceDeletionPolicyOrphan removes the resources from the maestro directly and leaves the applied
+	// resources on the managed cluster.
+	ResourceDeletionPolicyOrphan ResourceDeletionPolicy = "Orphan"
+)
+
+// DefaultResourceDeletionGracePeriod is how long the agent of a removed managed cluster is waited to confirm the
+// deletion of its resources by default.
+const DefaultResourceDeletionGracePeriod = 10 * time.Minute
+
 // ManagedClusterController is a controller that used to create new consumers in the maestro
 // when a new managed cluster is joined, and delete the consumer when the managed cluster is removed.
 // It also manages message queue ACLs for the managed cluster.
 type ManagedClusterController struct {
+	clusterClient            clusterclientset.Interface
 	clusterLister            clusterlisters.ManagedClusterLister
 	rateLimiter              workqueue.TypedRateLimiter[string]
 	messageQueueAuthzCreator mq.MessageQueueAuthzCreator
 	consumerService          services.ConsumerService
+	resourceService          services.ResourceService
+	resourceDeletionPolicy   ResourceDeletionPolicy
+	// resourceDeletionGracePeriod is how long the agent is waited to confirm the deletion since the managed
+	// cluster is deleted
+	resourceDeletionGracePeriod time.Duration
 }
 
-func NewManagedClusterController(clusterInformer clusterinformers.ManagedClusterInformer,
+func NewManagedClusterController(clusterClient clusterclientset.Interface,
+	clusterInformer clusterinformers.ManagedClusterInformer,
 	recorder events.Recorder,
 	messageQueueAuthzCreator mq.MessageQueueAuthzCreator,
-	consumerService services.ConsumerService) factory.Controller {
+	consumerService services.ConsumerService,
+	resourceService services.ResourceService,
+	resourceDeletionPolicy ResourceDeletionPolicy,
+	resourceDeletionGracePeriod time.Duration) factory.Controller {
 	controller := &ManagedClusterController{
-		clusterLister:            clusterInformer.Lister(),
-		rateLimiter:              workqueue.NewTypedItemExponentialFailureRateLimiter[string](5*time.Second, 300*time.Second),
-		messageQueueAuthzCreator: messageQueueAuthzCreator,
-		consumerService:          consumerService,
+		clusterClient:               clusterClient,
+		clusterLister:               clusterInformer.Lister(),
+		rateLimiter:                 workqueue.NewTypedItemExponentialFailureRateLimiter[string](5*time.Second, 300*time.Second),
+		messageQueueAuthzCreator:    messageQueueAuthzCreator,
+		consumerService:             consumerService,
+		resourceService:             resourceService,
+		resourceDeletionPolicy:      resourceDeletionPolicy,
+		resourceDeletionGracePeriod: resourceDeletionGracePeriod,
 	}
 
 	return factory.New().
@@ -65,7 +107,16 @@ func (c *ManagedClusterController) sync(ctx context.Context, controllerContext f
 	}
 
 	if !managedCluster.DeletionTimestamp.IsZero() {
-		// TODO delete this cluster in the maestro
+		if err := c.cleanup(ctx, controllerContext, managedCluster); err != nil {
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				logger.V(4).Info("Consumer service is not available, retrying later", "clusterName", clusterName, "error", err)
+				controllerContext.Queue().AddAfter(clusterName, c.rateLimiter.When(clusterName))
+				return nil
+			}
+
+			return err
+		}
+
 		return nil
 	}
 
@@ -74,6 +125,14 @@ func (c *ManagedClusterController) sync(ctx context.Context, controllerContext f
 		return nil
 	}
 
+	// add the finalizer before the consumer is created, so the consumer will be cleaned up on the cluster removal
+	if !slices.Contains(managedCluster.Finalizers, ConsumerCleanupFinalizer) {
+		finalizers := append(slices.Clone(managedCluster.Finalizers), ConsumerCleanupFinalizer)
+		if err := c.patchFinalizers(ctx, managedCluster, finalizers); err != nil {
+			return err
+		}
+	}
+
 	if err := c.ensureConsumer(ctx, clusterName); err != nil {
 		if errors.Is(err, syscall.ECONNREFUSED) {
 			logger.V(4).Info("Consumer service is not available, retrying later", "clusterName", clusterName, "error", err)
@@ -87,6 +146,109 @@ func (c *ManagedClusterController) sync(ctx context.Context, controllerContext f
 	return c.ensureACLs(ctx, clusterName)
 }
 
+// cleanup removes the consumer resources, the consumer and the message queue ACLs of a deleting managed cluster,
+// and then removes the finalizer from the managed cluster.
+func (c *ManagedClusterController) cleanup(ctx context.Context, controllerContext factory.SyncContext,
+	managedCluster *clusterv1.ManagedCluster) error {
+	logger := klog.FromContext(ctx)
+	clusterName := managedCluster.Name
+
+	if !slices.Contains(managedCluster.Finalizers, ConsumerCleanupFinalizer) {
+		return nil
+	}
+
+	remaining, err := c.cleanupResources(ctx, managedCluster)
+	if err != nil {
+		return err
+	}
+	if remaining > 0 {
+		// the resources are deleting from the managed cluster, wait for the agent to confirm the deletion until
+		// the end of the grace period at the latest
+		logger.V(4).Info("Waiting for the consumer resources to be deleted", "clusterName", clusterName, "remaining", remaining)
+		gracePeriodLeft := c.resourceDeletionGracePeriod - time.Since(managedCluster.DeletionTimestamp.Time)
+		controllerContext.Queue().AddAfter(clusterName, min(c.rateLimiter.When(clusterName), gracePeriodLeft))
+		return nil
+	}
+
+	if err := maestro.DeleteConsumer(ctx, c.consumerService, clusterName); err != nil {
+		return err
+	}
+
+	if c.messageQueueAuthzCreator != nil {
+		if err := c.messageQueueAuthzCreator.DeleteAuthorizations(ctx, clusterName); err != nil {
+			return err
+		}
+	}
+
+	finalizers := slices.DeleteFunc(slices.Clone(managedCluster.Finalizers), func(finalizer string) bool {
+		return finalizer == ConsumerCleanupFinalizer
+	})
+	if err := c.patchFinalizers(ctx, managedCluster, finalizers); err != nil {
+		return err
+	}
+
+	c.rateLimiter.Forget(clusterName)
+	controllerContext.Recorder().Eventf("ConsumerDeleted", "The consumer %s is deleted from the maestro", clusterName)
+	return nil
+}
+
+// cleanupResources handles the maestro resources of the consumer according to the resource deletion policy,
+// it returns the number of resources that are still waiting for the deletion confirmation from the agent.
+func (c *ManagedClusterController) cleanupResources(ctx context.Context, managedCluster *clusterv1.ManagedCluster) (int, error) {
+	if c.resourceService == nil {
+		return 0, nil
+	}
+
+	resources, err := maestro.ListConsumerResources(c.resourceService, managedCluster.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	// the agent cannot confirm the deletion if the cluster is not available, remove the resources directly, and
+	// the resources are removed directly as well if the agent does not confirm the deletion within the grace period
+	waitForAgent := c.resourceDeletionPolicy != ResourceDeletionPolicyOrphan &&
+		meta.IsStatusConditionTrue(managedCluster.Status.Conditions, clusterv1.ManagedClusterConditionAvailable) &&
+		time.Since(managedCluster.DeletionTimestamp.Time) < c.resourceDeletionGracePeriod
+
+	remaining := 0
+	for _, resource := range resources {
+		if !waitForAgent {
+			if svcErr := c.resourceService.Delete(ctx, resource.ID); svcErr != nil && !svcErr.Is404() {
+				return 0, fmt.Errorf("failed to delete resource %s: %w", resource.ID, svcErr)
+			}
+			continue
+		}
+
+		remaining++
+		if !resource.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		if svcErr := c.resourceService.MarkAsDeleting(ctx, resource.ID); svcErr != nil && !svcErr.Is404() {
+			return 0, fmt.Errorf("failed to mark resource %s as deleting: %w", resource.ID, svcErr)
+		}
+	}
+
+	return remaining, nil
+}
+
+func (c *ManagedClusterController) patchFinalizers(ctx context.Context, managedCluster *clusterv1.ManagedCluster, finalizers []string) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"uid":             managedCluster.UID,
+			"resourceVersion": managedCluster.ResourceVersion,
+			"finalizers":      finalizers,
+		},
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.clusterClient.ClusterV1().ManagedClusters().Patch(
+		ctx, managedCluster.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{})
+	return err
+}
+
 // ensureConsumer ensures that a consumer exists for the managed cluster.
 func (c *ManagedClusterController) ensureConsumer(ctx context.Context, managedClusterName string) error {
 	existed, err := maestro.FindConsumerByName(ctx, c.consumerService, managedClusterName)
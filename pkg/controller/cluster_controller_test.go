@@ -2,19 +2,25 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/util/workqueue"
 	fakeclusterclient "open-cluster-management.io/api/client/cluster/clientset/versioned/fake"
 	clusterinformers "open-cluster-management.io/api/client/cluster/informers/externalversions"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
+	cetypes "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
 
 	"github.com/openshift-online/maestro/pkg/api"
 	maestromocks "github.com/openshift-online/maestro/pkg/dao/mocks"
+	maestroerrors "github.com/openshift-online/maestro/pkg/errors"
 	"github.com/openshift-online/maestro/pkg/services"
 	"github.com/stolostron/cloudevents-conductor/pkg/controller/mq"
+	"github.com/stolostron/cloudevents-conductor/pkg/utils/maestro"
 	"github.com/stolostron/cloudevents-conductor/pkg/utils/mock"
 )
 
@@ -103,6 +109,7 @@ func TestClusterSync(t *testing.T) {
 			}
 
 			ctrl := &ManagedClusterController{
+				clusterClient:            clusterClient,
 				clusterLister:            clusterInformerFactory.Cluster().V1().ManagedClusters().Lister(),
 				messageQueueAuthzCreator: c.authz,
 				consumerService:          consumerService,
@@ -113,3 +120,182 @@ func TestClusterSync(t *testing.T) {
 		})
 	}
 }
+
+// fakeResourceService overrides the ResourceService methods that are used by the ManagedClusterController.
+type fakeResourceService struct {
+	services.ResourceService
+	resources        map[string]*api.Resource
+	markedAsDeleting []string
+}
+
+func newFakeResourceService(consumerName string, ids ...string) *fakeResourceService {
+	resources := map[string]*api.Resource{}
+	for _, id := range ids {
+		resources[id] = &api.Resource{Meta: api.Meta{ID: id}, ConsumerName: consumerName}
+	}
+	return &fakeResourceService{resources: resources}
+}
+
+func (s *fakeResourceService) List(listOpts cetypes.ListOptions) ([]*api.Resource, error) {
+	resources := []*api.Resource{}
+	for _, resource := range s.resources {
+		if resource.ConsumerName == listOpts.ClusterName {
+			resources = append(resources, resource)
+		}
+	}
+	return resources, nil
+}
+
+func (s *fakeResourceService) MarkAsDeleting(ctx context.Context, id string) *maestroerrors.ServiceError {
+	s.markedAsDeleting = append(s.markedAsDeleting, id)
+	return nil
+}
+
+func (s *fakeResourceService) Delete(ctx context.Context, id string) *maestroerrors.ServiceError {
+	delete(s.resources, id)
+	return nil
+}
+
+func TestClusterCleanup(t *testing.T) {
+	now := metav1.Now()
+	clusterName := "cluster1"
+
+	expired := metav1.NewTime(now.Add(-2 * time.Hour))
+
+	newDeletingCluster := func(available bool, deletionTimestamp *metav1.Time) *clusterv1.ManagedCluster {
+		cluster := &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              clusterName,
+				DeletionTimestamp: deletionTimestamp,
+				Finalizers:        []string{"test", ConsumerCleanupFinalizer},
+			},
+		}
+		if available {
+			cluster.Status.Conditions = []metav1.Condition{{
+				Type:   clusterv1.ManagedClusterConditionAvailable,
+				Status: metav1.ConditionTrue,
+			}}
+		}
+		return cluster
+	}
+
+	cases := []struct {
+		name                   string
+		cluster                *clusterv1.ManagedCluster
+		policy                 ResourceDeletionPolicy
+		expectedResources      int
+		expectedMarkedDeleting int
+		expectedCleanedUp      bool
+	}{
+		{
+			name:              "orphan the resources",
+			cluster:           newDeletingCluster(true, &now),
+			policy:            ResourceDeletionPolicyOrphan,
+			expectedResources: 0,
+			expectedCleanedUp: true,
+		},
+		{
+			name:                   "delete the resources from an available cluster",
+			cluster:                newDeletingCluster(true, &now),
+			policy:                 ResourceDeletionPolicyDelete,
+			expectedResources:      2,
+			expectedMarkedDeleting: 2,
+			expectedCleanedUp:      false,
+		},
+		{
+			name:              "delete the resources from an unavailable cluster",
+			cluster:           newDeletingCluster(false, &now),
+			policy:            ResourceDeletionPolicyDelete,
+			expectedResources: 0,
+			expectedCleanedUp: true,
+		},
+		{
+			name:              "delete the resources after the grace period",
+			cluster:           newDeletingCluster(true, &expired),
+			policy:            ResourceDeletionPolicyDelete,
+			expectedResources: 0,
+			expectedCleanedUp: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			consumerService := services.NewConsumerService(maestromocks.NewConsumerDao())
+			if _, svcErr := consumerService.Create(context.Background(), &api.Consumer{
+				Name: clusterName,
+			}); svcErr != nil {
+				t.Fatalf("failed to create consumer %s: %v", clusterName, svcErr)
+			}
+			resourceService := newFakeResourceService(clusterName, "r1", "r2")
+			authz := mock.NewMockMessageQueueAuthzCreator()
+
+			clusterClient := fakeclusterclient.NewSimpleClientset(c.cluster)
+			clusterInformerFactory := clusterinformers.NewSharedInformerFactory(clusterClient, time.Minute*10)
+			if err := clusterInformerFactory.Cluster().V1().ManagedClusters().Informer().GetStore().Add(c.cluster); err != nil {
+				t.Fatal(err)
+			}
+
+			ctrl := &ManagedClusterController{
+				clusterClient:               clusterClient,
+				clusterLister:               clusterInformerFactory.Cluster().V1().ManagedClusters().Lister(),
+				rateLimiter:                 workqueue.NewTypedItemExponentialFailureRateLimiter[string](time.Second, time.Second),
+				messageQueueAuthzCreator:    authz,
+				consumerService:             consumerService,
+				resourceService:             resourceService,
+				resourceDeletionPolicy:      c.policy,
+				resourceDeletionGracePeriod: time.Hour,
+			}
+			if err := ctrl.sync(context.Background(), mock.NewMockSyncContext(t, clusterName)); err != nil {
+				t.Errorf("unexpected err: %v", err)
+			}
+
+			if len(resourceService.resources) != c.expectedResources {
+				t.Errorf("expected %d resources, but got %d", c.expectedResources, len(resourceService.resources))
+			}
+			if len(resourceService.markedAsDeleting) != c.expectedMarkedDeleting {
+				t.Errorf("expected %d resources marked as deleting, but got %d",
+					c.expectedMarkedDeleting, len(resourceService.markedAsDeleting))
+			}
+
+			existed, err := maestro.FindConsumerByName(context.Background(), consumerService, clusterName)
+			if c.expectedCleanedUp && existed {
+				t.Errorf("expected the consumer is deleted, but it still exists")
+			}
+			if !c.expectedCleanedUp && (err != nil || !existed) {
+				t.Errorf("expected the consumer exists, but got %v, %v", existed, err)
+			}
+
+			expectedDeletedClusterName := ""
+			if c.expectedCleanedUp {
+				expectedDeletedClusterName = clusterName
+			}
+			if authz.DeletedClusterName() != expectedDeletedClusterName {
+				t.Errorf("expected authorizations of %q are deleted, but got %q",
+					expectedDeletedClusterName, authz.DeletedClusterName())
+			}
+
+			patches := []clienttesting.PatchAction{}
+			for _, action := range clusterClient.Actions() {
+				if patch, ok := action.(clienttesting.PatchAction); ok {
+					patches = append(patches, patch)
+				}
+			}
+			if !c.expectedCleanedUp {
+				if len(patches) != 0 {
+					t.Errorf("expected no patches, but got %v", patches)
+				}
+				return
+			}
+			if len(patches) != 1 {
+				t.Fatalf("expected one patch, but got %v", patches)
+			}
+			patched := &clusterv1.ManagedCluster{}
+			if err := json.Unmarshal(patches[0].GetPatch(), patched); err != nil {
+				t.Fatal(err)
+			}
+			if len(patched.Finalizers) != 1 || patched.Finalizers[0] != "test" {
+				t.Errorf("expected the finalizer is removed, but got %v", patched.Finalizers)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"time"
+
+	k8smetrics "k8s.io/component-base/metrics"
+)
+
+// subsystem used to define the metrics of the spec controller
+const specControllerMetricsSubsystem = "spec_controller"
+
+// Names of the labels added to metrics:
+const (
+	specControllerMetricsSourceLabel    = "source"
+	specControllerMetricsTypeLabel      = "type"
+	specControllerMetricsResultLabel    = "result"
+	specControllerMetricsOperationLabel = "operation"
+)
+
+// Values of the result label:
+const (
+	resultSuccess = "success"
+	resultError   = "error"
+	resultSkipped = "skipped"
+)
+
+// Values of the operation label:
+const (
+	operationPurge   = "purge"
+	operationRequeue = "requeue"
+	operationSweep   = "sweep"
+)
+
+// unknownLabelValue is used for the source and type labels when the event cannot be loaded from the db.
+const unknownLabelValue = "unknown"
+
+// Names of the spec controller metrics:
+const (
+	queueDepthMetric          = "queue_depth"
+	eventsHandledCountMetric  = "events_handled_total"
+	lockContentionCountMetric = "lock_contention_total"
+	handlerDurationMetric     = "handler_duration_seconds"
+	syncRunsCountMetric       = "sync_runs_total"
+	syncEventsCountMetric     = "sync_events_total"
+	eventsPoisonedCountMetric = "events_poisoned_total"
+	eventsNotOwnedCountMetric = "events_not_owned_total"
+)
+
+// specControllerQueueDepth is a gauge metric that tracks the number of events waiting in the queue.
+var specControllerQueueDepth = k8smetrics.NewGauge(&k8smetrics.GaugeOpts{
+	Subsystem:      specControllerMetricsSubsystem,
+	Name:           queueDepthMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Current number of db events waiting in the spec controller queue.",
+})
+
+// specControllerEventsHandled is a counter metric that tracks the number of handled events
+// by the event source, event type and handling result.
+var specControllerEventsHandled = k8smetrics.NewCounterVec(&k8smetrics.CounterOpts{
+	Subsystem:      specControllerMetricsSubsystem,
+	Name:           eventsHandledCountMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Total number of db events handled by the spec controller.",
+}, []string{specControllerMetricsSourceLabel, specControllerMetricsTypeLabel, specControllerMetricsResultLabel})
+
+// specControllerLockContention is a counter metric that tracks the number of events whose advisory lock
+// was held by another worker.
+var specControllerLockContention = k8smetrics.NewCounter(&k8smetrics.CounterOpts{
+	Subsystem:      specControllerMetricsSubsystem,
+	Name:           lockContentionCountMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Total number of db events skipped because the advisory lock was held by another worker.",
+})
+
+// specControllerHandlerDuration is a histogram metric that tracks the duration of the event handlers.
+var specControllerHandlerDuration = k8smetrics.NewHistogramVec(&k8smetrics.HistogramOpts{
+	Subsystem:      specControllerMetricsSubsystem,
+	Name:           handlerDurationMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Duration in seconds of the spec controller event handlers.",
+	Buckets:        k8smetrics.ExponentialBuckets(0.001, 2, 15),
+}, []string{specControllerMetricsSourceLabel, specControllerMetricsTypeLabel})
+
+// specControllerSyncRuns is a counter metric that tracks the number of the periodic events sync operations
+// and the sweeps by the operation and result.
+var specControllerSyncRuns = k8smetrics.NewCounterVec(&k8smetrics.CounterOpts{
+	Subsystem:      specControllerMetricsSubsystem,
+	Name:           syncRunsCountMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Total number of the periodic events sync operations and the sweeps of the unreconciled events.",
+}, []string{specControllerMetricsOperationLabel, specControllerMetricsResultLabel})
+
+// specControllerSyncEvents is a counter metric that tracks the number of events purged or requeued by
+// the periodic events sync, or requeued by the sweeps.
+var specControllerSyncEvents = k8smetrics.NewCounterVec(&k8smetrics.CounterOpts{
+	Subsystem:      specControllerMetricsSubsystem,
+	Name:           syncEventsCountMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Total number of db events purged or requeued by the periodic events sync or the sweeps.",
+}, []string{specControllerMetricsOperationLabel})
+
+// specControllerEventsPoisoned is a counter metric that tracks the number of events that are poisoned
+// after exceeding the max retries.
+var specControllerEventsPoisoned = k8smetrics.NewCounterVec(&k8smetrics.CounterOpts{
+	Subsystem:      specControllerMetricsSubsystem,
+	Name:           eventsPoisonedCountMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Total number of db events poisoned after exceeding the max retries.",
+}, []string{specControllerMetricsSourceLabel, specControllerMetricsTypeLabel})
+
+// specControllerEventsNotOwned is a counter metric that tracks the number of events that are skipped because
+// the agent stream of their cluster is held by another conductor instance.
+var specControllerEventsNotOwned = k8smetrics.NewCounter(&k8smetrics.CounterOpts{
+	Subsystem:      specControllerMetricsSubsystem,
+	Name:           eventsNotOwnedCountMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Total number of db events skipped because they are owned by another conductor instance.",
+})
+
+// SpecControllerMetrics returns the metrics of the spec controller, they measure the queue, the handling, the
+// retries and the periodic sync of the db events.
+func SpecControllerMetrics() []k8smetrics.Registerable {
+	return []k8smetrics.Registerable{
+		specControllerQueueDepth,
+		specControllerEventsHandled,
+		specControllerLockContention,
+		specControllerHandlerDuration,
+		specControllerSyncRuns,
+		specControllerSyncEvents,
+		specControllerEventsPoisoned,
+		specControllerEventsNotOwned,
+	}
+}
+
+func labelValueOrUnknown(value string) string {
+	if len(value) == 0 {
+		return unknownLabelValue
+	}
+	return value
+}
+
+func observeEventHandled(source, eventType, result string) {
+	specControllerEventsHandled.WithLabelValues(source, eventType, result).Inc()
+}
+
+func observeHandlerDuration(source, eventType string, start time.Time) {
+	specControllerHandlerDuration.WithLabelValues(source, eventType).Observe(time.Since(start).Seconds())
+}
+
+func observeSyncRun(operation string, err error) {
+	result := resultSuccess
+	if err != nil {
+		result = resultError
+	}
+	specControllerSyncRuns.WithLabelValues(operation, result).Inc()
+}
@@ -2,21 +2,83 @@ package mq
 
 import (
 	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 const (
-	BrokerGRPC = "grpc"
+	BrokerGRPC  = "grpc"
+	BrokerMQTT  = "mqtt"
+	BrokerKafka = "kafka"
 )
 
+// defaultSourceID is the default source of the resource specs that the conductor publishes on the message
+// queue broker.
+const defaultSourceID = "maestro"
+
+// BrokerOptions defines the message queue broker that the agents connect to.
+type BrokerOptions struct {
+	// Type is the type of the broker, grpc, mqtt or kafka. The gRPC broker is served by the conductor and its
+	// authorizations are checked by the conductor, so no authorization is created for it. The conductor
+	// connects to the mqtt or kafka broker as a source.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+
+	// ConfigFile is the MQTT or Kafka config file to connect the broker, it is required if the type is mqtt or
+	// kafka. The user of the MQTT config must be allowed to publish on the dynamic security control topic of
+	// the broker, and the user of the Kafka config must be allowed to alter the ACLs of the cluster.
+	ConfigFile string `json:"config_file,omitempty" yaml:"config_file,omitempty"`
+
+	// SourceID is the source of the resource specs that the conductor publishes, the agent of a managed cluster
+	// is allowed to subscribe on sources/<source>/consumers/<cluster>/sourceevents and publish on
+	// sources/<source>/consumers/<cluster>/agentevents of the mqtt broker.
+	SourceID string `json:"source_id,omitempty" yaml:"source_id,omitempty"`
+}
+
+func NewBrokerOptions() *BrokerOptions {
+	return &BrokerOptions{
+		Type:     BrokerGRPC,
+		SourceID: defaultSourceID,
+	}
+}
+
+// Validate validates the options, the errors are reported with the given field path.
+func (o *BrokerOptions) Validate(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	switch o.Type {
+	case BrokerGRPC:
+	case BrokerMQTT, BrokerKafka:
+		if o.ConfigFile == "" {
+			errs = append(errs, field.Required(fldPath.Child("config_file"), fmt.Sprintf("required by the %s broker", o.Type)))
+		}
+		if o.SourceID == "" {
+			errs = append(errs, field.Required(fldPath.Child("source_id"), fmt.Sprintf("required by the %s broker", o.Type)))
+		}
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("type"), o.Type,
+			[]string{BrokerGRPC, BrokerMQTT, BrokerKafka}))
+	}
+	return errs
+}
+
 // MessageQueueAuthzCreator defines an interface for creating and deleting message queue authorization rules.
 type MessageQueueAuthzCreator interface {
 	CreateAuthorizations(ctx context.Context, clusterName string) error
 	DeleteAuthorizations(ctx context.Context, clusterName string) error
 }
 
-// NewMessageQueueAuthzCreator creates a new instance of MessageQueueAuthzCreator.
-func NewMessageQueueAuthzCreator() MessageQueueAuthzCreator {
-	return &DumbAuthzCreator{}
+// NewMessageQueueAuthzCreator creates a new instance of MessageQueueAuthzCreator for the broker of the options.
+func NewMessageQueueAuthzCreator(options *BrokerOptions) (MessageQueueAuthzCreator, error) {
+	switch options.Type {
+	case BrokerGRPC:
+		return &DumbAuthzCreator{}, nil
+	case BrokerMQTT:
+		return NewMQTTAuthzCreator(options.ConfigFile, options.SourceID)
+	case BrokerKafka:
+		return NewKafkaAuthzCreator(options.ConfigFile)
+	default:
+		return nil, fmt.Errorf("unsupported message queue broker %q", options.Type)
+	}
 }
 
 // DumbAuthzCreator is a no-op implementation of MessageQueueAuthzCreator.
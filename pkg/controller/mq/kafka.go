@@ -0,0 +1,134 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// kafkaSourceEventsTopic is the topic that the sources publish the resource specs on, see the Kafka
+	// transport of the sdk-go.
+	kafkaSourceEventsTopic = "sourceevents"
+
+	// kafkaAgentEventsTopic is the topic that the agents publish the resource status on.
+	kafkaAgentEventsTopic = "agentevents"
+)
+
+type kafkaResourceType string
+
+const (
+	kafkaResourceTopic kafkaResourceType = "Topic"
+	kafkaResourceGroup kafkaResourceType = "Group"
+)
+
+type kafkaOperation string
+
+const (
+	kafkaOperationRead  kafkaOperation = "Read"
+	kafkaOperationWrite kafkaOperation = "Write"
+)
+
+// kafkaACL allows the principal to do the operation on the resource, the resource name is a prefix of the
+// resources if prefixed is true.
+type kafkaACL struct {
+	resourceType kafkaResourceType
+	name         string
+	prefixed     bool
+	principal    string
+	operation    kafkaOperation
+}
+
+func (a kafkaACL) String() string {
+	pattern := "literal"
+	if a.prefixed {
+		pattern = "prefixed"
+	}
+	return fmt.Sprintf("%s %s %s:%s:%s", a.principal, a.operation, a.resourceType, pattern, a.name)
+}
+
+// kafkaACLAdmin creates and deletes the ACLs of a Kafka cluster, the existing ACLs are kept when they are
+// created and the absent ACLs are ignored when they are deleted.
+type kafkaACLAdmin interface {
+	CreateACLs(ctx context.Context, acls []kafkaACL) error
+	DeleteACLs(ctx context.Context, acls []kafkaACL) error
+}
+
+// KafkaAuthzCreator provisions the ACLs of the managed clusters in the Kafka cluster. The agent of a managed
+// cluster authenticates as the principal User:<cluster>, it is allowed to read the source events topic, write
+// the agent events topic and join the consumer groups that are prefixed with `<cluster>-`, e.g. the default
+// group ID of the agent `<cluster>-work-agent`, the separator keeps the groups of cluster1 from the agent of
+// cluster10.
+//
+// The ACLs do not isolate the clusters from each other on the topics: all of the clusters read the same source
+// events topic, so an agent can read the resource specs of the other clusters, the topic ACLs of Kafka cannot
+// restrict the read by the cluster name of the events.
+type KafkaAuthzCreator struct {
+	admin kafkaACLAdmin
+}
+
+// NewKafkaAuthzCreator creates a KafkaAuthzCreator with the Kafka config file, the group ID of the config
+// file is not used. The conductor must be built with the kafka tag to support it.
+func NewKafkaAuthzCreator(configFile string) (*KafkaAuthzCreator, error) {
+	admin, err := newKafkaACLAdmin(configFile)
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaAuthzCreator{admin: admin}, nil
+}
+
+// CreateAuthorizations creates the ACLs of the managed cluster, it can be called repeatedly. The group ACL that
+// is prefixed with the bare cluster name is deleted, it covers the groups of the other clusters.
+func (k *KafkaAuthzCreator) CreateAuthorizations(ctx context.Context, clusterName string) error {
+	if err := k.admin.CreateACLs(ctx, k.acls(clusterName)); err != nil {
+		return fmt.Errorf("failed to create the Kafka ACLs of cluster %s: %w", clusterName, err)
+	}
+	if err := k.admin.DeleteACLs(ctx, []kafkaACL{legacyGroupACL(clusterName)}); err != nil {
+		return fmt.Errorf("failed to delete the legacy Kafka group ACL of cluster %s: %w", clusterName, err)
+	}
+	return nil
+}
+
+// DeleteAuthorizations deletes the ACLs of the managed cluster, the absent ACLs are ignored.
+func (k *KafkaAuthzCreator) DeleteAuthorizations(ctx context.Context, clusterName string) error {
+	if err := k.admin.DeleteACLs(ctx, append(k.acls(clusterName), legacyGroupACL(clusterName))); err != nil {
+		return fmt.Errorf("failed to delete the Kafka ACLs of cluster %s: %w", clusterName, err)
+	}
+	return nil
+}
+
+func (k *KafkaAuthzCreator) acls(clusterName string) []kafkaACL {
+	principal := fmt.Sprintf("User:%s", clusterName)
+	return []kafkaACL{
+		{
+			resourceType: kafkaResourceTopic,
+			name:         kafkaSourceEventsTopic,
+			principal:    principal,
+			operation:    kafkaOperationRead,
+		},
+		{
+			resourceType: kafkaResourceTopic,
+			name:         kafkaAgentEventsTopic,
+			principal:    principal,
+			operation:    kafkaOperationWrite,
+		},
+		{
+			resourceType: kafkaResourceGroup,
+			name:         clusterName + "-",
+			prefixed:     true,
+			principal:    principal,
+			operation:    kafkaOperationRead,
+		},
+	}
+}
+
+// legacyGroupACL is the group ACL that is prefixed with the bare cluster name, it was created for the clusters
+// before the `<cluster>-` prefix.
+func legacyGroupACL(clusterName string) kafkaACL {
+	return kafkaACL{
+		resourceType: kafkaResourceGroup,
+		name:         clusterName,
+		prefixed:     true,
+		principal:    fmt.Sprintf("User:%s", clusterName),
+		operation:    kafkaOperationRead,
+	}
+}
@@ -0,0 +1,95 @@
+//go:build kafka
+
+package mq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	kafkaoptions "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/kafka"
+)
+
+// confluentACLAdmin manages the ACLs with the admin client of the confluent-kafka-go.
+type confluentACLAdmin struct {
+	client *kafka.AdminClient
+}
+
+func newKafkaACLAdmin(configFile string) (kafkaACLAdmin, error) {
+	options, err := kafkaoptions.BuildKafkaOptionsFromFlags(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kafka config %s: %w", configFile, err)
+	}
+
+	client, err := kafka.NewAdminClient(&options.ConfigMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kafka admin client: %w", err)
+	}
+	return &confluentACLAdmin{client: client}, nil
+}
+
+// CreateACLs creates the ACL bindings, Kafka keeps the existing bindings, so it can be called repeatedly.
+func (c *confluentACLAdmin) CreateACLs(ctx context.Context, acls []kafkaACL) error {
+	bindings := kafka.ACLBindings{}
+	for _, acl := range acls {
+		bindings = append(bindings, toACLBinding(acl))
+	}
+
+	results, err := c.client.CreateACLs(ctx, bindings)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for i, result := range results {
+		if result.Error.Code() != kafka.ErrNoError {
+			errs = append(errs, fmt.Errorf("failed to create ACL %s: %w", acls[i], result.Error))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// DeleteACLs deletes the ACL bindings that match the ACLs exactly, a filter that matches nothing is not
+// an error.
+func (c *confluentACLAdmin) DeleteACLs(ctx context.Context, acls []kafkaACL) error {
+	filters := kafka.ACLBindingFilters{}
+	for _, acl := range acls {
+		filters = append(filters, toACLBinding(acl))
+	}
+
+	results, err := c.client.DeleteACLs(ctx, filters)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{}
+	for i, result := range results {
+		if result.Error.Code() != kafka.ErrNoError {
+			errs = append(errs, fmt.Errorf("failed to delete ACL %s: %w", acls[i], result.Error))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func toACLBinding(acl kafkaACL) kafka.ACLBinding {
+	binding := kafka.ACLBinding{
+		Type:                kafka.ResourceTopic,
+		Name:                acl.name,
+		ResourcePatternType: kafka.ResourcePatternTypeLiteral,
+		Principal:           acl.principal,
+		Host:                "*",
+		Operation:           kafka.ACLOperationRead,
+		PermissionType:      kafka.ACLPermissionTypeAllow,
+	}
+	if acl.resourceType == kafkaResourceGroup {
+		binding.Type = kafka.ResourceGroup
+	}
+	if acl.prefixed {
+		binding.ResourcePatternType = kafka.ResourcePatternTypePrefixed
+	}
+	if acl.operation == kafkaOperationWrite {
+		binding.Operation = kafka.ACLOperationWrite
+	}
+	return binding
+}
@@ -0,0 +1,11 @@
+//go:build !kafka
+
+package mq
+
+import "fmt"
+
+// newKafkaACLAdmin is not supported by default, since confluent-kafka-go does not support the
+// cross-compilation. Try adding -tags=kafka to build when you need Kafka.
+func newKafkaACLAdmin(configFile string) (kafkaACLAdmin, error) {
+	return nil, fmt.Errorf("the kafka broker is not supported, the conductor is not built with the kafka tag")
+}
@@ -0,0 +1,136 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// fakeKafkaACLAdmin keeps the ACLs in memory in the same way as a Kafka cluster, creating an existing ACL
+// and deleting an absent ACL are not errors.
+type fakeKafkaACLAdmin struct {
+	acls map[kafkaACL]bool
+	err  error
+}
+
+func newFakeKafkaACLAdmin(acls ...kafkaACL) *fakeKafkaACLAdmin {
+	admin := &fakeKafkaACLAdmin{acls: map[kafkaACL]bool{}}
+	for _, acl := range acls {
+		admin.acls[acl] = true
+	}
+	return admin
+}
+
+func (f *fakeKafkaACLAdmin) CreateACLs(ctx context.Context, acls []kafkaACL) error {
+	if f.err != nil {
+		return f.err
+	}
+	for _, acl := range acls {
+		f.acls[acl] = true
+	}
+	return nil
+}
+
+func (f *fakeKafkaACLAdmin) DeleteACLs(ctx context.Context, acls []kafkaACL) error {
+	if f.err != nil {
+		return f.err
+	}
+	for _, acl := range acls {
+		delete(f.acls, acl)
+	}
+	return nil
+}
+
+func (f *fakeKafkaACLAdmin) list() []string {
+	acls := []string{}
+	for acl := range f.acls {
+		acls = append(acls, acl.String())
+	}
+	sort.Strings(acls)
+	return acls
+}
+
+func TestKafkaAuthzCreator(t *testing.T) {
+	otherACL := kafkaACL{
+		resourceType: kafkaResourceTopic,
+		name:         kafkaSourceEventsTopic,
+		principal:    "User:cluster2",
+		operation:    kafkaOperationRead,
+	}
+	cluster1ACLs := []string{
+		"User:cluster1 Read Group:prefixed:cluster1-",
+		"User:cluster1 Read Topic:literal:sourceevents",
+		"User:cluster1 Write Topic:literal:agentevents",
+	}
+
+	cases := []struct {
+		name        string
+		admin       *fakeKafkaACLAdmin
+		action      func(ctx context.Context, creator *KafkaAuthzCreator) error
+		expectedErr string
+		expected    []string
+	}{
+		{
+			name:  "create",
+			admin: newFakeKafkaACLAdmin(otherACL),
+			action: func(ctx context.Context, creator *KafkaAuthzCreator) error {
+				return creator.CreateAuthorizations(ctx, "cluster1")
+			},
+			expected: append(cluster1ACLs, "User:cluster2 Read Topic:literal:sourceevents"),
+		},
+		{
+			name:  "create replaces the legacy group ACL",
+			admin: newFakeKafkaACLAdmin(legacyGroupACL("cluster1"), legacyGroupACL("cluster10")),
+			action: func(ctx context.Context, creator *KafkaAuthzCreator) error {
+				return creator.CreateAuthorizations(ctx, "cluster1")
+			},
+			expected: append(cluster1ACLs, "User:cluster10 Read Group:prefixed:cluster10"),
+		},
+		{
+			name:  "create repeatedly",
+			admin: newFakeKafkaACLAdmin(),
+			action: func(ctx context.Context, creator *KafkaAuthzCreator) error {
+				if err := creator.CreateAuthorizations(ctx, "cluster1"); err != nil {
+					return err
+				}
+				return creator.CreateAuthorizations(ctx, "cluster1")
+			},
+			expected: cluster1ACLs,
+		},
+		{
+			name:  "delete",
+			admin: newFakeKafkaACLAdmin(otherACL),
+			action: func(ctx context.Context, creator *KafkaAuthzCreator) error {
+				if err := creator.CreateAuthorizations(ctx, "cluster1"); err != nil {
+					return err
+				}
+				if err := creator.DeleteAuthorizations(ctx, "cluster1"); err != nil {
+					return err
+				}
+				return creator.DeleteAuthorizations(ctx, "cluster1")
+			},
+			expected: []string{"User:cluster2 Read Topic:literal:sourceevents"},
+		},
+		{
+			name:  "failed",
+			admin: &fakeKafkaACLAdmin{acls: map[kafkaACL]bool{}, err: fmt.Errorf("cluster authorization failed")},
+			action: func(ctx context.Context, creator *KafkaAuthzCreator) error {
+				return creator.CreateAuthorizations(ctx, "cluster1")
+			},
+			expectedErr: "failed to create the Kafka ACLs of cluster cluster1: cluster authorization failed",
+			expected:    []string{},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			creator := &KafkaAuthzCreator{admin: c.admin}
+			assertErr(t, c.action(context.Background(), creator), c.expectedErr)
+			if actual := c.admin.list(); !reflect.DeepEqual(actual, c.expected) {
+				t.Errorf("expected ACLs %v, but got %v", c.expected, actual)
+			}
+		})
+	}
+}
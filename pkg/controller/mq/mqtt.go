@@ -0,0 +1,251 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/klog/v2"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/cert"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/mqtt"
+)
+
+const (
+	// dynamicSecurityControlTopic is the topic that the dynamic security commands are published on.
+	dynamicSecurityControlTopic = "$CONTROL/dynamic-security/v1"
+
+	// dynamicSecurityResponseTopic is the topic that the broker responds the dynamic security commands on.
+	dynamicSecurityResponseTopic = "$CONTROL/dynamic-security/v1/response"
+
+	// mqttAuthzClientIDPrefix is the prefix of the client ID that is used to publish the commands.
+	mqttAuthzClientIDPrefix = "cloudevents-conductor-authz"
+
+	// defaultMQTTCommandTimeout is the timeout to wait for the responses of the commands.
+	defaultMQTTCommandTimeout = 30 * time.Second
+)
+
+// dynamicSecurityCommand is a command of the dynamic security plugin of the broker, e.g. the mosquitto
+// dynamic security plugin.
+type dynamicSecurityCommand struct {
+	Command         string `json:"command"`
+	RoleName        string `json:"rolename,omitempty"`
+	Username        string `json:"username,omitempty"`
+	ACLType         string `json:"acltype,omitempty"`
+	Topic           string `json:"topic,omitempty"`
+	Allow           bool   `json:"allow,omitempty"`
+	CorrelationData string `json:"correlationData,omitempty"`
+}
+
+type dynamicSecurityCommands struct {
+	Commands []dynamicSecurityCommand `json:"commands"`
+}
+
+type dynamicSecurityResponse struct {
+	Command         string `json:"command"`
+	Error           string `json:"error,omitempty"`
+	CorrelationData string `json:"correlationData,omitempty"`
+}
+
+type dynamicSecurityResponses struct {
+	Responses []dynamicSecurityResponse `json:"responses"`
+}
+
+// MQTTAuthzCreator provisions the ACLs of the managed clusters in the MQTT broker with the dynamic security
+// control topic. A role is created for each managed cluster to allow its agent to subscribe on the spec topic
+// and publish on the status topic of the cluster, and the role is granted to the client whose username is
+// the cluster name.
+type MQTTAuthzCreator struct {
+	options  *mqtt.MQTTOptions
+	sourceID string
+	timeout  time.Duration
+}
+
+// NewMQTTAuthzCreator creates a MQTTAuthzCreator with the MQTT config file, the topics of the config file
+// are not used.
+func NewMQTTAuthzCreator(configFile, sourceID string) (*MQTTAuthzCreator, error) {
+	config, err := mqtt.LoadConfig(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load MQTT config %s: %w", configFile, err)
+	}
+	if config.BrokerHost == "" {
+		return nil, fmt.Errorf("brokerHost is required in MQTT config %s", configFile)
+	}
+
+	options := &mqtt.MQTTOptions{
+		Username:  config.Username,
+		Password:  config.Password,
+		KeepAlive: 60,
+		PubQoS:    1,
+		SubQoS:    1,
+		Dialer: &mqtt.MQTTDialer{
+			BrokerHost: config.BrokerHost,
+			Timeout:    defaultMQTTCommandTimeout,
+		},
+	}
+	if config.KeepAlive != nil {
+		options.KeepAlive = *config.KeepAlive
+	}
+	if config.DialTimeout != nil {
+		options.Dialer.Timeout = *config.DialTimeout
+	}
+	if config.CertConfig.HasCerts() {
+		options.Dialer.TLSConfig, err = cert.AutoLoadTLSConfig(config.CertConfig,
+			func() (*cert.CertConfig, error) {
+				config, err := mqtt.LoadConfig(configFile)
+				if err != nil {
+					return nil, err
+				}
+				return &config.CertConfig, nil
+			},
+			options.Dialer,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newMQTTAuthzCreator(options, sourceID), nil
+}
+
+func newMQTTAuthzCreator(options *mqtt.MQTTOptions, sourceID string) *MQTTAuthzCreator {
+	return &MQTTAuthzCreator{
+		options:  options,
+		sourceID: sourceID,
+		timeout:  defaultMQTTCommandTimeout,
+	}
+}
+
+// CreateAuthorizations creates the role and the client of the managed cluster, the existing role, ACLs and
+// client are kept, so it can be called repeatedly.
+func (m *MQTTAuthzCreator) CreateAuthorizations(ctx context.Context, clusterName string) error {
+	roleName := m.roleName(clusterName)
+	return m.execute(ctx, []dynamicSecurityCommand{
+		{Command: "createRole", RoleName: roleName},
+		{Command: "addRoleACL", RoleName: roleName, ACLType: "subscribePattern", Topic: m.specTopic(clusterName), Allow: true},
+		{Command: "addRoleACL", RoleName: roleName, ACLType: "publishClientReceive", Topic: m.specTopic(clusterName), Allow: true},
+		{Command: "addRoleACL", RoleName: roleName, ACLType: "publishClientSend", Topic: m.statusTopic(clusterName), Allow: true},
+		{Command: "createClient", Username: clusterName},
+		{Command: "addClientRole", Username: clusterName, RoleName: roleName},
+	}, "already exists")
+}
+
+// DeleteAuthorizations deletes the client and the role of the managed cluster, the absent client and role
+// are ignored.
+func (m *MQTTAuthzCreator) DeleteAuthorizations(ctx context.Context, clusterName string) error {
+	return m.execute(ctx, []dynamicSecurityCommand{
+		{Command: "deleteClient", Username: clusterName},
+		{Command: "deleteRole", RoleName: m.roleName(clusterName)},
+	}, "not found")
+}
+
+func (m *MQTTAuthzCreator) roleName(clusterName string) string {
+	return fmt.Sprintf("cluster-%s", clusterName)
+}
+
+// specTopic is the topic that the agent of the managed cluster receives the resource specs from.
+func (m *MQTTAuthzCreator) specTopic(clusterName string) string {
+	return fmt.Sprintf("sources/%s/consumers/%s/sourceevents", m.sourceID, clusterName)
+}
+
+// statusTopic is the topic that the agent of the managed cluster sends the resource status to.
+func (m *MQTTAuthzCreator) statusTopic(clusterName string) string {
+	return fmt.Sprintf("sources/%s/consumers/%s/agentevents", m.sourceID, clusterName)
+}
+
+// execute publishes the commands on the control topic and waits for their responses, the errors of the
+// responses that contain the ignoredError are ignored.
+func (m *MQTTAuthzCreator) execute(ctx context.Context, commands []dynamicSecurityCommand, ignoredError string) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	correlationID := utilrand.String(8)
+	pending := map[string]string{}
+	for i := range commands {
+		commands[i].CorrelationData = fmt.Sprintf("%s-%d", correlationID, i)
+		pending[commands[i].CorrelationData] = commands[i].Command
+	}
+	payload, err := json.Marshal(&dynamicSecurityCommands{Commands: commands})
+	if err != nil {
+		return err
+	}
+
+	conn, err := m.options.Dialer.Dial()
+	if err != nil {
+		return err
+	}
+
+	responses := make(chan *dynamicSecurityResponses, 1)
+	clientID := fmt.Sprintf("%s-%s", mqttAuthzClientIDPrefix, correlationID)
+	client := paho.NewClient(paho.ClientConfig{
+		ClientID: clientID,
+		Conn:     conn,
+		OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+			func(received paho.PublishReceived) (bool, error) {
+				resp := &dynamicSecurityResponses{}
+				if err := json.Unmarshal(received.Packet.Payload, resp); err != nil {
+					klog.Warningf("failed to decode the dynamic security responses: %v", err)
+					return true, nil
+				}
+				select {
+				case responses <- resp:
+				case <-ctx.Done():
+				}
+				return true, nil
+			},
+		},
+	})
+	defer func() {
+		if err := client.Disconnect(&paho.Disconnect{ReasonCode: 0}); err != nil {
+			klog.V(4).Infof("failed to disconnect MQTT client %s: %v", clientID, err)
+		}
+	}()
+
+	if _, err := client.Connect(ctx, m.options.GetMQTTConnectOption(clientID)); err != nil {
+		return fmt.Errorf("failed to connect MQTT broker %s: %w", m.options.Dialer.BrokerHost, err)
+	}
+	if _, err := client.Subscribe(ctx, &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: dynamicSecurityResponseTopic, QoS: 1}},
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe %s: %w", dynamicSecurityResponseTopic, err)
+	}
+	if _, err := client.Publish(ctx, &paho.Publish{
+		Topic:   dynamicSecurityControlTopic,
+		QoS:     1,
+		Payload: payload,
+	}); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", dynamicSecurityControlTopic, err)
+	}
+
+	errs := []error{}
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to wait for the responses of the commands %v: %w", pendingCommands(pending), ctx.Err())
+		case resp := <-responses:
+			for _, r := range resp.Responses {
+				if _, ok := pending[r.CorrelationData]; !ok {
+					// the response of other clients
+					continue
+				}
+				delete(pending, r.CorrelationData)
+				if r.Error != "" && !strings.Contains(r.Error, ignoredError) {
+					errs = append(errs, fmt.Errorf("failed to %s: %s", r.Command, r.Error))
+				}
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func pendingCommands(pending map[string]string) []string {
+	commands := []string{}
+	for _, command := range pending {
+		commands = append(commands, command)
+	}
+	return commands
+}
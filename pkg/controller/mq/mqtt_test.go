@@ -0,0 +1,205 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mochimqtt "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/mqtt"
+)
+
+// fakeDynamicSecurity responds the dynamic security commands on an in-process broker, the responses of
+// the commands in errs are failed with the given error.
+type fakeDynamicSecurity struct {
+	sync.Mutex
+	broker   *mochimqtt.Server
+	commands []dynamicSecurityCommand
+	errs     map[string]string
+}
+
+func (f *fakeDynamicSecurity) handle(cl *mochimqtt.Client, sub packets.Subscription, pk packets.Packet) {
+	req := &dynamicSecurityCommands{}
+	if err := json.Unmarshal(pk.Payload, req); err != nil {
+		return
+	}
+
+	f.Lock()
+	resp := &dynamicSecurityResponses{}
+	for _, c := range req.Commands {
+		f.commands = append(f.commands, c)
+		resp.Responses = append(resp.Responses, dynamicSecurityResponse{
+			Command:         c.Command,
+			Error:           f.errs[c.Command],
+			CorrelationData: c.CorrelationData,
+		})
+	}
+	f.Unlock()
+
+	payload, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	go func() {
+		_ = f.broker.Publish(dynamicSecurityResponseTopic, payload, false, 1)
+	}()
+}
+
+func (f *fakeDynamicSecurity) received() []string {
+	f.Lock()
+	defer f.Unlock()
+	commands := []string{}
+	for _, c := range f.commands {
+		commands = append(commands, c.Command)
+	}
+	f.commands = nil
+	return commands
+}
+
+func startBroker(t *testing.T, errs map[string]string) (string, *fakeDynamicSecurity) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	addr := listener.Addr().String()
+	if err := listener.Close(); err != nil {
+		t.Fatalf("failed to close listener: %v", err)
+	}
+
+	broker := mochimqtt.New(&mochimqtt.Options{InlineClient: true})
+	if err := broker.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatalf("failed to add auth hook: %v", err)
+	}
+	if err := broker.AddListener(listeners.NewTCP(listeners.Config{ID: "mqtt-authz-test", Address: addr})); err != nil {
+		t.Fatalf("failed to add listener: %v", err)
+	}
+
+	fake := &fakeDynamicSecurity{broker: broker, errs: errs}
+	if err := broker.Subscribe(dynamicSecurityControlTopic, 1, fake.handle); err != nil {
+		t.Fatalf("failed to subscribe %s: %v", dynamicSecurityControlTopic, err)
+	}
+	if err := broker.Serve(); err != nil {
+		t.Fatalf("failed to start broker: %v", err)
+	}
+	t.Cleanup(func() { _ = broker.Close() })
+	return addr, fake
+}
+
+func TestMQTTAuthzCreator(t *testing.T) {
+	cases := []struct {
+		name              string
+		errs              map[string]string
+		expectedCreate    []string
+		expectedDelete    []string
+		expectedCreateErr string
+		expectedDeleteErr string
+	}{
+		{
+			name:           "create and delete",
+			expectedCreate: []string{"createRole", "addRoleACL", "addRoleACL", "addRoleACL", "createClient", "addClientRole"},
+			expectedDelete: []string{"deleteClient", "deleteRole"},
+		},
+		{
+			name: "already created and deleted",
+			errs: map[string]string{
+				"createRole":   "Role already exists",
+				"createClient": "Client already exists",
+				"deleteClient": "Client not found",
+				"deleteRole":   "Role not found",
+			},
+			expectedCreate: []string{"createRole", "addRoleACL", "addRoleACL", "addRoleACL", "createClient", "addClientRole"},
+			expectedDelete: []string{"deleteClient", "deleteRole"},
+		},
+		{
+			name: "failed",
+			errs: map[string]string{
+				"addClientRole": "Internal error",
+				"deleteRole":    "Internal error",
+			},
+			expectedCreate:    []string{"createRole", "addRoleACL", "addRoleACL", "addRoleACL", "createClient", "addClientRole"},
+			expectedDelete:    []string{"deleteClient", "deleteRole"},
+			expectedCreateErr: "failed to addClientRole: Internal error",
+			expectedDeleteErr: "failed to deleteRole: Internal error",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			addr, fake := startBroker(t, c.errs)
+			creator := newMQTTAuthzCreator(&mqtt.MQTTOptions{
+				KeepAlive: 60,
+				Dialer: &mqtt.MQTTDialer{
+					BrokerHost: addr,
+					Timeout:    5 * time.Second,
+				},
+			}, defaultSourceID)
+			creator.timeout = 10 * time.Second
+
+			assertErr(t, creator.CreateAuthorizations(context.Background(), "cluster1"), c.expectedCreateErr)
+			if actual := fake.received(); !reflect.DeepEqual(actual, c.expectedCreate) {
+				t.Errorf("expected create commands %v, but got %v", c.expectedCreate, actual)
+			}
+
+			assertErr(t, creator.DeleteAuthorizations(context.Background(), "cluster1"), c.expectedDeleteErr)
+			if actual := fake.received(); !reflect.DeepEqual(actual, c.expectedDelete) {
+				t.Errorf("expected delete commands %v, but got %v", c.expectedDelete, actual)
+			}
+		})
+	}
+}
+
+func TestMQTTAuthzCreatorACLs(t *testing.T) {
+	addr, fake := startBroker(t, nil)
+	creator := newMQTTAuthzCreator(&mqtt.MQTTOptions{
+		KeepAlive: 60,
+		Dialer:    &mqtt.MQTTDialer{BrokerHost: addr, Timeout: 5 * time.Second},
+	}, "source1")
+
+	if err := creator.CreateAuthorizations(context.Background(), "cluster1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fake.Lock()
+	defer fake.Unlock()
+	acls := []string{}
+	for _, c := range fake.commands {
+		switch c.Command {
+		case "addRoleACL":
+			acls = append(acls, fmt.Sprintf("%s %s %s", c.RoleName, c.ACLType, c.Topic))
+		case "createClient", "addClientRole":
+			if c.Username != "cluster1" {
+				t.Errorf("expected username cluster1 for %s, but got %s", c.Command, c.Username)
+			}
+		}
+	}
+	expected := []string{
+		"cluster-cluster1 subscribePattern sources/source1/consumers/cluster1/sourceevents",
+		"cluster-cluster1 publishClientReceive sources/source1/consumers/cluster1/sourceevents",
+		"cluster-cluster1 publishClientSend sources/source1/consumers/cluster1/agentevents",
+	}
+	if !reflect.DeepEqual(acls, expected) {
+		t.Errorf("expected ACLs %v, but got %v", expected, acls)
+	}
+}
+
+func assertErr(t *testing.T, err error, expected string) {
+	t.Helper()
+	if expected == "" {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected error %q, but got %v", expected, err)
+	}
+}
@@ -3,14 +3,23 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/openshift-online/maestro/pkg/api"
 	"github.com/openshift-online/maestro/pkg/controllers"
 	"github.com/openshift-online/maestro/pkg/db"
 	"github.com/openshift-online/maestro/pkg/services"
+	"github.com/openshift/library-go/pkg/operator/events"
+	dbevent "github.com/stolostron/cloudevents-conductor/pkg/services/db/event"
+	"github.com/stolostron/cloudevents-conductor/pkg/tracing"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/klog/v2"
 
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/workqueue"
 )
@@ -33,30 +42,150 @@ Any successful processing of an Event will remove it from the Events table perma
 A periodic process reads from the Events table and calls pg_notify, ensuring any failed Events are re-processed. Competing
 consumers for the lock will fail fast on redundant messages.
 
+Within one controller manager, the Events can be handled by multiple workers. Each worker gets the ID of the resource
+(the SourceID of the Event) of the Event it takes from the queue, the Events of a resource that are taken while another
+worker is handling an Event of the resource are handed over to that worker, so the Events of the same resource are
+handled in order, and never concurrently.
+
 */
 
 // defaultEventsSyncPeriod is a default events sync period (10 hours)
 // given a long period because we have a queue in the controller, it will help us to handle most expected errors, this
 // events sync will help us to handle unexpected errors (e.g. sever restart), it ensures we will not miss any events
-var defaultEventsSyncPeriod = 10 * time.Hour
+const defaultEventsSyncPeriod = 10 * time.Hour
+
+// defaultReconciledEventsPurgeBatchSize is the default number of reconciled events deleted in one transaction.
+const defaultReconciledEventsPurgeBatchSize = 1000
+
+// SpecControllerOptions defines the options of the SpecControllerManager.
+type SpecControllerOptions struct {
+	// Workers is the number of workers that handle the events concurrently.
+	Workers int `json:"workers,omitempty" yaml:"workers,omitempty"`
+
+	// EventsSyncPeriod is the period to purge the reconciled events and requeue the unreconciled events.
+	EventsSyncPeriod time.Duration `json:"events_sync_period,omitempty" yaml:"events_sync_period,omitempty"`
+
+	// ReconciledEventsRetention is how long the reconciled events are kept before they are purged,
+	// the reconciled events are purged by the next events sync if it is zero.
+	ReconciledEventsRetention time.Duration `json:"reconciled_events_retention,omitempty" yaml:"reconciled_events_retention,omitempty"`
+
+	// ReconciledEventsPurgeBatchSize is the max number of reconciled events deleted in one transaction.
+	ReconciledEventsPurgeBatchSize int `json:"reconciled_events_purge_batch_size,omitempty" yaml:"reconciled_events_purge_batch_size,omitempty"`
+
+	// MaxRetries is the max number of retries of a failed event, the event is poisoned once it exceeds the max
+	// retries and will not be handled until it is re-driven. The failed events are retried forever if it is zero.
+	MaxRetries int `json:"max_retries,omitempty" yaml:"max_retries,omitempty"`
+}
+
+func NewSpecControllerOptions() *SpecControllerOptions {
+	return &SpecControllerOptions{
+		Workers:                        1,
+		EventsSyncPeriod:               defaultEventsSyncPeriod,
+		ReconciledEventsPurgeBatchSize: defaultReconciledEventsPurgeBatchSize,
+	}
+}
+
+// Validate validates the options, the errors are reported with the given field path.
+func (o *SpecControllerOptions) Validate(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if o.Workers < 1 {
+		errs = append(errs, field.Invalid(fldPath.Child("workers"), o.Workers, "must be at least 1"))
+	}
+	if o.EventsSyncPeriod <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("events_sync_period"), o.EventsSyncPeriod.String(),
+			"must be greater than 0"))
+	}
+	if o.ReconciledEventsRetention < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("reconciled_events_retention"), o.ReconciledEventsRetention.String(),
+			"must not be negative"))
+	}
+	if o.ReconciledEventsPurgeBatchSize < 1 {
+		errs = append(errs, field.Invalid(fldPath.Child("reconciled_events_purge_batch_size"), o.ReconciledEventsPurgeBatchSize,
+			"must be at least 1"))
+	}
+	if o.MaxRetries < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("max_retries"), o.MaxRetries, "must not be negative"))
+	}
+	return errs
+}
+
+// PoisonedEventStore persists the events that are failed to be handled after the max retries.
+type PoisonedEventStore interface {
+	Poison(ctx context.Context, event *dbevent.PoisonedEvent) error
+	List(ctx context.Context) ([]*dbevent.PoisonedEvent, error)
+}
+
+// EventOwnership routes the events to the conductor instance that holds the agent stream of their cluster, so
+// the other instances do not compete for the events that they cannot deliver.
+type EventOwnership interface {
+	// OwnsEvent returns false if the cluster of the event is owned by another conductor instance.
+	OwnsEvent(ctx context.Context, eventID string) (bool, error)
+}
+
+// ReconciledEventsPurger purges the reconciled events from the database.
+type ReconciledEventsPurger interface {
+	// PurgeReconciledEvents deletes the events that were reconciled before the given time in batches,
+	// and returns the number of the deleted events.
+	PurgeReconciledEvents(ctx context.Context, reconciledBefore time.Time, batchSize int) (int64, error)
+}
 
 // SpecControllerManager is responsible for managing spec event controllers.
 type SpecControllerManager struct {
 	controllers map[string]map[api.EventType][]controllers.ControllerHandlerFunc
 	lockFactory db.LockFactory
 	events      services.EventService
+	purger      ReconciledEventsPurger
+	options     *SpecControllerOptions
+	// poisonedEvents and recorder are used to dead-letter the events that exceed the max retries
+	poisonedEvents PoisonedEventStore
+	recorder       events.Recorder
+	// ownership skips the events of the clusters whose agent streams are held by the other instances
+	ownership EventOwnership
+	// eventsQueue receives the events from the db notifications and the periodic events sync, the workers take
+	// the events from it
 	eventsQueue workqueue.RateLimitingInterface
+	// inFlight holds the resources whose events are being handled, it is nil if there is only one worker
+	inFlight *inFlightResources
+	// sweepRequests holds a pending sweep of the unreconciled events
+	sweepRequests chan struct{}
+	// lastProgress is the unix nano time when an event was processed by a worker last time
+	lastProgress atomic.Int64
 }
 
-func NewSpecControllerManager(lockFactory db.LockFactory, events services.EventService) *SpecControllerManager {
+func NewSpecControllerManager(lockFactory db.LockFactory, events services.EventService,
+	purger ReconciledEventsPurger, options *SpecControllerOptions) *SpecControllerManager {
+	var inFlight *inFlightResources
+	if options.Workers > 1 {
+		inFlight = newInFlightResources()
+	}
+
 	return &SpecControllerManager{
-		controllers: map[string]map[api.EventType][]controllers.ControllerHandlerFunc{},
-		lockFactory: lockFactory,
-		events:      events,
-		eventsQueue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "event-controller"),
+		controllers:   map[string]map[api.EventType][]controllers.ControllerHandlerFunc{},
+		lockFactory:   lockFactory,
+		events:        events,
+		purger:        purger,
+		options:       options,
+		eventsQueue:   workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "event-controller"),
+		inFlight:      inFlight,
+		sweepRequests: make(chan struct{}, 1),
 	}
 }
 
+// WithDeadLetter enables the dead-letter handling for the events that exceed the max retries, the poisoned events
+// are persisted in the store and reported with the recorder.
+func (cm *SpecControllerManager) WithDeadLetter(store PoisonedEventStore, recorder events.Recorder) *SpecControllerManager {
+	cm.poisonedEvents = store
+	cm.recorder = recorder
+	return cm
+}
+
+// WithEventOwnership skips the events that are owned by the other conductor instances, they are requeued and
+// handled by their owners.
+func (cm *SpecControllerManager) WithEventOwnership(ownership EventOwnership) *SpecControllerManager {
+	cm.ownership = ownership
+	return cm
+}
+
 func (cm *SpecControllerManager) Queue() workqueue.RateLimitingInterface {
 	return cm.eventsQueue
 }
@@ -69,21 +198,28 @@ func (cm *SpecControllerManager) Add(config *controllers.ControllerConfig) {
 
 func (cm *SpecControllerManager) AddEvent(id string) {
 	cm.eventsQueue.Add(id)
+	cm.updateQueueDepth()
 }
 
-// Run starts the controller manager in a goroutine and starts processing events.
+// Run starts the controller manager in a goroutine and starts processing events. The unreconciled events are
+// requeued periodically on every conductor instance, so the events of the agents that are connected to this
+// instance are not missed, but the reconciled events are not purged by Run, see RunEventsPurge.
 func (cm *SpecControllerManager) Run(ctx context.Context) {
 	go func(ctx context.Context) {
-		klog.Infof("Starting event controller")
+		klog.Infof("Starting event controller with %d workers", cm.options.Workers)
+		cm.lastProgress.Store(time.Now().UnixNano())
 		defer cm.eventsQueue.ShutDown()
 
-		// start a goroutine to sync all events periodically
-		// use a jitter to avoid multiple instances syncing the events at the same time
-		go wait.JitterUntil(cm.syncEvents, defaultEventsSyncPeriod, 0.25, true, ctx.Done())
+		// start a goroutine to sync the unreconciled events periodically and to sweep them when it is requested,
+		// use a jitter to spread the syncs of the conductor instances after they are restarted
+		go wait.JitterUntil(cm.syncEvents, cm.options.EventsSyncPeriod, 0.25, true, ctx.Done())
+		go cm.runSweeps(ctx)
 
-		// start a goroutine to handle the event from the event queue
+		// start a goroutine for each worker to handle the events from the queue
 		// the .Until will re-kick the runWorker one second after the runWorker completes
-		go wait.Until(cm.runWorker, time.Second, ctx.Done())
+		for i := 0; i < cm.options.Workers; i++ {
+			go wait.Until(cm.runWorker, time.Second, ctx.Done())
+		}
 
 		// wait until context is done
 		<-ctx.Done()
@@ -91,6 +227,53 @@ func (cm *SpecControllerManager) Run(ctx context.Context) {
 	}(ctx)
 }
 
+// RunEventsPurge purges the reconciled events periodically until ctx is done, it is expected to run on one of
+// the conductor instances, e.g. the leader.
+func (cm *SpecControllerManager) RunEventsPurge(ctx context.Context) {
+	klog.Infof("Starting reconciled events purge every %s", cm.options.EventsSyncPeriod)
+	// use a jitter to spread the purges after the conductor is restarted or the leader is changed
+	wait.JitterUntil(cm.purgeEvents, cm.options.EventsSyncPeriod, 0.25, true, ctx.Done())
+}
+
+// SweepUnreconciledEvents requeues the unreconciled events to this controller manager at once, e.g. after the
+// listener of the events is reconnected, since the events that are notified when it is disconnected are missed.
+// The sweeps that are requested while a sweep is running are coalesced into one sweep after it.
+func (cm *SpecControllerManager) SweepUnreconciledEvents() {
+	select {
+	case cm.sweepRequests <- struct{}{}:
+	default:
+		// a sweep is already pending
+	}
+}
+
+func (cm *SpecControllerManager) runSweeps(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cm.sweepRequests:
+			klog.Infof("sweep all unreconciled events")
+			cm.requeueUnreconciledEvents(operationSweep)
+		}
+	}
+}
+
+// CheckProgress returns an error if there are events waiting in the queues but no event has been processed
+// by the workers within the stall timeout, e.g. the workers are blocked by a hanging handler.
+func (cm *SpecControllerManager) CheckProgress(stallTimeout time.Duration) error {
+	waiting := cm.eventsQueue.Len()
+	// the controller manager is not started or there is no waiting event
+	if cm.lastProgress.Load() == 0 || waiting == 0 {
+		return nil
+	}
+
+	lastProgress := time.Unix(0, cm.lastProgress.Load())
+	if stalled := time.Since(lastProgress); stalled > stallTimeout {
+		return fmt.Errorf("%d events are waiting but no event has been processed for %s", waiting, stalled.Round(time.Second))
+	}
+	return nil
+}
+
 func (cm *SpecControllerManager) add(source string, ev api.EventType, fns []controllers.ControllerHandlerFunc) {
 	if _, exists := cm.controllers[source]; !exists {
 		cm.controllers[source] = map[api.EventType][]controllers.ControllerHandlerFunc{}
@@ -103,8 +286,29 @@ func (cm *SpecControllerManager) add(source string, ev api.EventType, fns []cont
 	cm.controllers[source][ev] = append(cm.controllers[source][ev], fns...)
 }
 
+// handleEvent handles the event in a span, the span is the root of the trace that follows the resource change
+// to the agent.
 func (cm *SpecControllerManager) handleEvent(id string) (bool, error) {
-	ctx := context.Background()
+	ctx, span := tracing.Start(context.Background(), "SpecControllerManager.handleEvent", tracing.EventIDKey.String(id))
+	processed, err := cm.reconcileEvent(ctx, id)
+	tracing.End(span, err)
+	return processed, err
+}
+
+func (cm *SpecControllerManager) reconcileEvent(ctx context.Context, id string) (bool, error) {
+	// the event is left to its owner without taking the lock, it is handled by this instance if the owner
+	// cannot be determined
+	if cm.ownership != nil {
+		owned, err := cm.ownership.OwnsEvent(ctx, id)
+		if err != nil {
+			klog.Warningf("Failed to get the owner of event %s, handle it: %v", id, err)
+		} else if !owned {
+			specControllerEventsNotOwned.Inc()
+			klog.V(4).Infof("Event %s is owned by another instance, continue to process the next", id)
+			return true, nil
+		}
+	}
+
 	// lock the Event with a fail-fast advisory lock context.
 	// this allows concurrent processing of many events by one or many controller managers.
 	// allow the lock to be released by the handler goroutine and allow this function to continue.
@@ -113,10 +317,12 @@ func (cm *SpecControllerManager) handleEvent(id string) (bool, error) {
 	// Ensure that the transaction related to this lock always end.
 	defer cm.lockFactory.Unlock(ctx, lockOwnerID)
 	if err != nil {
+		observeEventHandled(unknownLabelValue, unknownLabelValue, resultError)
 		return false, fmt.Errorf("error obtaining the event lock: %v", err)
 	}
 
 	if !acquired {
+		specControllerLockContention.Inc()
 		klog.Infof("Event %s is processed by another worker, continue to process the next", id)
 		return true, nil
 	}
@@ -127,44 +333,62 @@ func (cm *SpecControllerManager) handleEvent(id string) (bool, error) {
 	if svcErr != nil {
 		if svcErr.Is404() {
 			// the event is already deleted, we can ignore it
+			observeEventHandled(unknownLabelValue, unknownLabelValue, resultSkipped)
 			return true, nil
 		}
+		observeEventHandled(unknownLabelValue, unknownLabelValue, resultError)
 		return false, fmt.Errorf("error getting event with id(%s): %s", id, svcErr)
 	}
 
+	eventType := string(event.EventType)
+	trace.SpanFromContext(ctx).SetAttributes(
+		tracing.ResourceIDKey.String(event.SourceID),
+		tracing.EventSourceKey.String(event.Source),
+		tracing.EventActionKey.String(eventType),
+	)
 	if event.ReconciledDate != nil {
 		// the event is already reconciled, we can ignore it
 		klog.Infof("Event with id (%s) is already reconciled", id)
+		observeEventHandled(event.Source, eventType, resultSkipped)
 		return true, nil
 	}
 
 	source, found := cm.controllers[event.Source]
 	if !found {
 		klog.Infof("No controllers found for '%s'\n", event.Source)
+		observeEventHandled(event.Source, eventType, resultSkipped)
 		return true, nil
 	}
 
 	handlerFns, found := source[event.EventType]
 	if !found {
 		klog.Infof("No handler functions found for '%s-%s'\n", event.Source, event.EventType)
+		observeEventHandled(event.Source, eventType, resultSkipped)
 		return true, nil
 	}
+
+	start := time.Now()
 	for _, fn := range handlerFns {
 		err := fn(reqContext, event.SourceID)
 		if err != nil {
+			observeHandlerDuration(event.Source, eventType, start)
+			observeEventHandled(event.Source, eventType, resultError)
 			return false, fmt.Errorf("error handing event %s, %s, %s: %s", event.Source, event.EventType, id, err)
 		}
 	}
+	observeHandlerDuration(event.Source, eventType, start)
 
 	// all handlers successfully executed
 	now := time.Now()
 	event.ReconciledDate = &now
 	_, svcErr = cm.events.Replace(reqContext, event)
 	if svcErr != nil {
+		observeEventHandled(event.Source, eventType, resultError)
 		return false, fmt.Errorf("error updating event with id (%s): %s", id, svcErr)
 	}
 
 	// the event is reconciled, we can ignore it
+	observeEventHandled(event.Source, eventType, resultSuccess)
 	return true, nil
 }
 
@@ -175,7 +399,7 @@ func (cm *SpecControllerManager) runWorker() {
 	}
 }
 
-// processNextEvent deals with one key off the queue.
+// processNextEvent deals with one key off the events queue.
 func (cm *SpecControllerManager) processNextEvent() bool {
 	// pull the next event item from queue.
 	// events queue blocks until it can return an item to be processed
@@ -185,42 +409,215 @@ func (cm *SpecControllerManager) processNextEvent() bool {
 		return false
 	}
 	defer cm.eventsQueue.Done(key)
+	cm.updateQueueDepth()
 
-	if reconciled, err := cm.handleEvent(key.(string)); !reconciled {
+	if cm.inFlight == nil {
+		cm.processEvent(key.(string))
+		return true
+	}
+
+	// the worker gets the resource of the event, so the events of the same resource are handled one by one
+	event, svcErr := cm.events.Get(context.Background(), key.(string))
+	if svcErr != nil {
+		if svcErr.Is404() {
+			// the event is already deleted, we can ignore it
+			cm.eventsQueue.Forget(key)
+			return true
+		}
+
+		klog.Errorf("Failed to get the event %v, %v", key, svcErr)
+		cm.eventsQueue.AddRateLimited(key)
+		return true
+	}
+
+	if !cm.inFlight.start(event.SourceID, key.(string)) {
+		// the event is handed over to the worker that is handling the resource
+		klog.V(4).Infof("Event %v is handed over to the worker of resource %s", key, event.SourceID)
+		return true
+	}
+	cm.processInFlight(event.SourceID, key.(string))
+	return true
+}
+
+// processInFlight handles the event of the in-flight resource and then the events of the resource that are handed
+// over meanwhile in order, the resource is not in flight after all of its events are handled.
+func (cm *SpecControllerManager) processInFlight(sourceID, id string) {
+	for {
+		cm.processEvent(id)
+
+		next, ok := cm.inFlight.next(sourceID)
+		if !ok {
+			return
+		}
+		id = next
+	}
+}
+
+// processEvent handles the event, the event is requeued with a backoff if it is not reconciled.
+func (cm *SpecControllerManager) processEvent(id string) {
+	defer cm.lastProgress.Store(time.Now().UnixNano())
+
+	if reconciled, err := cm.handleEvent(id); !reconciled {
 		if err != nil {
-			klog.Errorf("Failed to handle the event %v, %v ", key, err)
+			klog.Errorf("Failed to handle the event %v, %v ", id, err)
+		}
+
+		// the event exceeds the max retries, move it out of the queue
+		if cm.exceedsMaxRetries(id) {
+			poisonErr := cm.poisonEvent(id, cm.eventsQueue.NumRequeues(id)+1, err)
+			if poisonErr == nil {
+				cm.eventsQueue.Forget(id)
+				return
+			}
+			klog.Errorf("Failed to poison the event %v, %v", id, poisonErr)
 		}
 
 		// the event is not reconciled, we requeue it to work on later
 		// this method will add a backoff to avoid hotlooping on particular items
-		cm.eventsQueue.AddRateLimited(key)
-		return true
+		cm.eventsQueue.AddRateLimited(id)
+		return
 	}
 
 	// we handle the event successfully, tell the queue to stop tracking history for this event
-	cm.eventsQueue.Forget(key)
-	return true
+	cm.eventsQueue.Forget(id)
 }
 
-func (cm *SpecControllerManager) syncEvents() {
-	klog.Infof("purge all reconciled events")
-	// delete the reconciled events from the database firstly
-	if err := cm.events.DeleteAllReconciledEvents(context.Background()); err != nil {
+func (cm *SpecControllerManager) exceedsMaxRetries(id string) bool {
+	return cm.poisonedEvents != nil && cm.options.MaxRetries > 0 && cm.eventsQueue.NumRequeues(id) >= cm.options.MaxRetries
+}
+
+// poisonEvent persists the failed event as a poisoned event, and reports it with the metrics and a kube event.
+func (cm *SpecControllerManager) poisonEvent(id string, attempts int, handleErr error) error {
+	ctx := context.Background()
+	poisoned := &dbevent.PoisonedEvent{
+		EventID:    id,
+		Attempts:   attempts,
+		PoisonedAt: time.Now(),
+	}
+	if handleErr != nil {
+		poisoned.LastError = handleErr.Error()
+	}
+	// the event details are best effort, the event is poisoned even if it cannot be loaded
+	if event, svcErr := cm.events.Get(ctx, id); svcErr == nil {
+		poisoned.Source = event.Source
+		poisoned.SourceID = event.SourceID
+		poisoned.EventType = string(event.EventType)
+	}
+
+	if err := cm.poisonedEvents.Poison(ctx, poisoned); err != nil {
+		return err
+	}
+
+	specControllerEventsPoisoned.WithLabelValues(labelValueOrUnknown(poisoned.Source),
+		labelValueOrUnknown(poisoned.EventType)).Inc()
+	klog.Warningf("Event %s of resource %s is poisoned after %d attempts", id, poisoned.SourceID, attempts)
+	if cm.recorder != nil {
+		cm.recorder.Warningf("SpecEventPoisoned", "The event %s of resource %s is poisoned after %d attempts: %s",
+			id, poisoned.SourceID, attempts, poisoned.LastError)
+	}
+	return nil
+}
+
+func (cm *SpecControllerManager) updateQueueDepth() {
+	specControllerQueueDepth.Set(float64(cm.eventsQueue.Len()))
+}
+
+func (cm *SpecControllerManager) purgeEvents() {
+	reconciledBefore := time.Now().Add(-cm.options.ReconciledEventsRetention)
+	klog.Infof("purge reconciled events before %s", reconciledBefore.Format(time.RFC3339))
+	purged, err := cm.purger.PurgeReconciledEvents(
+		context.Background(), reconciledBefore, cm.options.ReconciledEventsPurgeBatchSize)
+	specControllerSyncEvents.WithLabelValues(operationPurge).Add(float64(purged))
+	observeSyncRun(operationPurge, err)
+	if err != nil {
 		// this process is called periodically, so if the error happened, we will wait for the next cycle to handle
 		// this again
 		klog.Errorf("Failed to delete reconciled events from db: %v", err)
 		return
 	}
+	klog.Infof("purged %d reconciled events", purged)
+}
 
+func (cm *SpecControllerManager) syncEvents() {
 	klog.Infof("sync all unreconciled events")
-	unreconciledEvents, err := cm.events.FindAllUnreconciledEvents(context.Background())
-	if err != nil {
-		klog.Errorf("Failed to list unreconciled events from db: %v", err)
+	cm.requeueUnreconciledEvents(operationRequeue)
+}
+
+// requeueUnreconciledEvents adds the unreconciled events except the poisoned events back to the controller queue,
+// the requeue is reported with the operation.
+func (cm *SpecControllerManager) requeueUnreconciledEvents(operation string) {
+	unreconciledEvents, svcErr := cm.events.FindAllUnreconciledEvents(context.Background())
+	if svcErr != nil {
+		observeSyncRun(operation, svcErr)
+		klog.Errorf("Failed to list unreconciled events from db: %v", svcErr)
 		return
 	}
 
+	// the poisoned events are kept out of the queue until they are re-driven
+	poisonedIDs := sets.New[string]()
+	if cm.poisonedEvents != nil {
+		poisonedEvents, err := cm.poisonedEvents.List(context.Background())
+		if err != nil {
+			klog.Errorf("Failed to list poisoned events from db: %v", err)
+		}
+		for _, poisoned := range poisonedEvents {
+			poisonedIDs.Insert(poisoned.EventID)
+		}
+	}
+
 	// add the unreconciled events back to the controller queue
+	requeued := 0
 	for _, event := range unreconciledEvents {
-		cm.eventsQueue.Add(event.ID)
+		if poisonedIDs.Has(event.ID) {
+			continue
+		}
+		cm.AddEvent(event.ID)
+		requeued++
+	}
+	observeSyncRun(operation, nil)
+	specControllerSyncEvents.WithLabelValues(operation).Add(float64(requeued))
+}
+
+// inFlightResources holds the resources whose events are being handled by the workers, and the events of them that
+// are taken by the other workers meanwhile. The events are handed over to the worker of their resource, so the
+// events of the same resource are never handled concurrently.
+type inFlightResources struct {
+	sync.Mutex
+	handedOver map[string][]string
+}
+
+func newInFlightResources() *inFlightResources {
+	return &inFlightResources{handedOver: map[string][]string{}}
+}
+
+// start returns true if the resource is not in flight, the resource is in flight then. Otherwise the event is handed
+// over to the worker of the resource and false is returned.
+func (r *inFlightResources) start(sourceID, id string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	handedOver, ok := r.handedOver[sourceID]
+	if !ok {
+		r.handedOver[sourceID] = []string{}
+		return true
+	}
+	if !slices.Contains(handedOver, id) {
+		r.handedOver[sourceID] = append(handedOver, id)
+	}
+	return false
+}
+
+// next returns the next event that is handed over to the worker of the resource, false is returned and the resource
+// is not in flight anymore if there is none.
+func (r *inFlightResources) next(sourceID string) (string, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	handedOver := r.handedOver[sourceID]
+	if len(handedOver) == 0 {
+		delete(r.handedOver, sourceID)
+		return "", false
 	}
+	r.handedOver[sourceID] = handedOver[1:]
+	return handedOver[0], true
 }
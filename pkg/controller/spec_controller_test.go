@@ -2,7 +2,9 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 	"github.com/openshift-online/maestro/pkg/api"
@@ -10,6 +12,10 @@ import (
 	"github.com/openshift-online/maestro/pkg/dao/mocks"
 	dbmocks "github.com/openshift-online/maestro/pkg/db/mocks"
 	"github.com/openshift-online/maestro/pkg/services"
+	"github.com/openshift/library-go/pkg/operator/events/eventstesting"
+	dbevent "github.com/stolostron/cloudevents-conductor/pkg/services/db/event"
+	k8smetrics "k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/testutil"
 )
 
 func newTestSpecControllerConfig(ctrl *testSpecController) *controllers.ControllerConfig {
@@ -44,13 +50,47 @@ func (d *testSpecController) OnDelete(ctx context.Context, id string) error {
 	return nil
 }
 
+type fakeEventsPurger struct {
+	reconciledBefore time.Time
+	batchSize        int
+}
+
+func (p *fakeEventsPurger) PurgeReconciledEvents(ctx context.Context, reconciledBefore time.Time, batchSize int) (int64, error) {
+	p.reconciledBefore = reconciledBefore
+	p.batchSize = batchSize
+	return 0, nil
+}
+
+type fakePoisonedEventStore struct {
+	poisoned []*dbevent.PoisonedEvent
+}
+
+func (s *fakePoisonedEventStore) Poison(ctx context.Context, event *dbevent.PoisonedEvent) error {
+	s.poisoned = append(s.poisoned, event)
+	return nil
+}
+
+func (s *fakePoisonedEventStore) List(ctx context.Context) ([]*dbevent.PoisonedEvent, error) {
+	return s.poisoned, nil
+}
+
+// fakeEventOwnership owns the events that are not in others.
+type fakeEventOwnership struct {
+	others map[string]bool
+	err    error
+}
+
+func (o *fakeEventOwnership) OwnsEvent(ctx context.Context, eventID string) (bool, error) {
+	return !o.others[eventID], o.err
+}
+
 func TestSpecControllerManager(t *testing.T) {
 	RegisterTestingT(t)
 
 	ctx := context.Background()
 	eventsDao := mocks.NewEventDao()
 	events := services.NewEventService(eventsDao)
-	ctlMgr := NewSpecControllerManager(dbmocks.NewMockAdvisoryLockFactory(), events)
+	ctlMgr := NewSpecControllerManager(dbmocks.NewMockAdvisoryLockFactory(), events, &fakeEventsPurger{}, NewSpecControllerOptions())
 
 	ctrl := &testSpecController{}
 	config := newTestSpecControllerConfig(ctrl)
@@ -88,3 +128,317 @@ func TestSpecControllerManager(t *testing.T) {
 	eve, _ := eventsDao.Get(ctx, "1")
 	Expect(eve.ReconciledDate).ToNot(BeNil(), "event reconcile date should be set")
 }
+
+func TestSpecControllerManagerMetrics(t *testing.T) {
+	RegisterTestingT(t)
+
+	registry := k8smetrics.NewKubeRegistry()
+	registry.MustRegister(SpecControllerMetrics()...)
+
+	ctx := context.Background()
+	eventsDao := mocks.NewEventDao()
+	events := services.NewEventService(eventsDao)
+	ctlMgr := NewSpecControllerManager(dbmocks.NewMockAdvisoryLockFactory(), events, &fakeEventsPurger{}, NewSpecControllerOptions())
+
+	ctrl := &testSpecController{}
+	config := newTestSpecControllerConfig(ctrl)
+	ctlMgr.Add(config)
+
+	_, _ = eventsDao.Create(ctx, &api.Event{
+		Meta:      api.Meta{ID: "1"},
+		Source:    config.Source,
+		SourceID:  "any id",
+		EventType: api.CreateEventType,
+	})
+
+	ctlMgr.AddEvent("1")
+	gauge, err := testutil.GetGaugeMetricValue(specControllerQueueDepth)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(gauge).To(Equal(float64(1)))
+
+	Expect(ctlMgr.processNextEvent()).To(BeTrue())
+	gauge, err = testutil.GetGaugeMetricValue(specControllerQueueDepth)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(gauge).To(Equal(float64(0)))
+
+	handled, err := testutil.GetCounterMetricValue(
+		specControllerEventsHandled.WithLabelValues(config.Source, string(api.CreateEventType), resultSuccess))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(handled).To(Equal(float64(1)))
+
+	count, err := testutil.GetHistogramMetricCount(
+		specControllerHandlerDuration.WithLabelValues(config.Source, string(api.CreateEventType)))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(count).To(Equal(uint64(1)))
+
+	// the event is reconciled, handle it again is skipped
+	ctlMgr.handleEvent("1")
+	skipped, err := testutil.GetCounterMetricValue(
+		specControllerEventsHandled.WithLabelValues(config.Source, string(api.CreateEventType), resultSkipped))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(skipped).To(Equal(float64(1)))
+}
+
+func TestSpecControllerManagerWorkers(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := context.Background()
+	eventsDao := mocks.NewEventDao()
+	events := services.NewEventService(eventsDao)
+	ctlMgr := NewSpecControllerManager(dbmocks.NewMockAdvisoryLockFactory(), events, &fakeEventsPurger{}, &SpecControllerOptions{Workers: 4})
+	Expect(ctlMgr.inFlight).NotTo(BeNil())
+
+	ctrl := &testSpecController{}
+	config := newTestSpecControllerConfig(ctrl)
+	ctlMgr.Add(config)
+
+	eventTypes := []api.EventType{api.CreateEventType, api.UpdateEventType, api.DeleteEventType}
+	for i, eventType := range eventTypes {
+		_, _ = eventsDao.Create(ctx, &api.Event{
+			Meta:      api.Meta{ID: fmt.Sprintf("%d", i)},
+			Source:    config.Source,
+			SourceID:  "resource1",
+			EventType: eventType,
+		})
+		ctlMgr.AddEvent(fmt.Sprintf("%d", i))
+	}
+	_, _ = eventsDao.Create(ctx, &api.Event{
+		Meta:      api.Meta{ID: "3"},
+		Source:    config.Source,
+		SourceID:  "resource2",
+		EventType: api.CreateEventType,
+	})
+	ctlMgr.AddEvent("3")
+
+	// another worker is handling an event of resource1
+	Expect(ctlMgr.inFlight.start("resource1", "running")).To(BeTrue())
+
+	// the events of resource1 are handed over to that worker, the event of resource2 is handled at once
+	for range 4 {
+		Expect(ctlMgr.processNextEvent()).To(BeTrue())
+	}
+	Expect(ctlMgr.eventsQueue.Len()).To(Equal(0))
+	Expect(ctrl.addCounter).To(Equal(1))
+	Expect(ctrl.updateCounter).To(Equal(0))
+	Expect(ctrl.deleteCounter).To(Equal(0))
+
+	// the handed over events of resource1 are handled in order after the running event
+	id, ok := ctlMgr.inFlight.next("resource1")
+	Expect(ok).To(BeTrue())
+	Expect(id).To(Equal("0"))
+	ctlMgr.processInFlight("resource1", id)
+	Expect(ctrl.addCounter).To(Equal(2))
+	Expect(ctrl.updateCounter).To(Equal(1))
+	Expect(ctrl.deleteCounter).To(Equal(1))
+
+	// resource1 is not in flight after its events are handled
+	Expect(ctlMgr.inFlight.handedOver).To(BeEmpty())
+}
+
+func TestSpecControllerManagerCheckProgress(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := context.Background()
+	eventsDao := mocks.NewEventDao()
+	events := services.NewEventService(eventsDao)
+	ctlMgr := NewSpecControllerManager(dbmocks.NewMockAdvisoryLockFactory(), events, &fakeEventsPurger{}, NewSpecControllerOptions())
+	config := newTestSpecControllerConfig(&testSpecController{})
+	ctlMgr.Add(config)
+
+	_, _ = eventsDao.Create(ctx, &api.Event{
+		Meta:      api.Meta{ID: "1"},
+		Source:    config.Source,
+		SourceID:  "resource1",
+		EventType: api.CreateEventType,
+	})
+	ctlMgr.AddEvent("1")
+
+	// the controller manager is not started
+	Expect(ctlMgr.CheckProgress(time.Minute)).To(Succeed())
+
+	// the waiting event is not processed within the stall timeout
+	ctlMgr.lastProgress.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	Expect(ctlMgr.CheckProgress(time.Minute)).NotTo(Succeed())
+
+	// the queue is drained
+	Expect(ctlMgr.processNextEvent()).To(BeTrue())
+	Expect(ctlMgr.CheckProgress(time.Minute)).To(Succeed())
+}
+
+func TestSyncEvents(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := context.Background()
+	eventsDao := mocks.NewEventDao()
+	events := services.NewEventService(eventsDao)
+	purger := &fakeEventsPurger{}
+	options := NewSpecControllerOptions()
+	options.ReconciledEventsRetention = time.Hour
+	options.ReconciledEventsPurgeBatchSize = 10
+	ctlMgr := NewSpecControllerManager(dbmocks.NewMockAdvisoryLockFactory(), events, purger, options)
+
+	now := time.Now()
+	_, _ = eventsDao.Create(ctx, &api.Event{
+		Meta:      api.Meta{ID: "1"},
+		Source:    "test-event-source",
+		SourceID:  "any id",
+		EventType: api.CreateEventType,
+	})
+	_, _ = eventsDao.Create(ctx, &api.Event{
+		Meta:           api.Meta{ID: "2"},
+		Source:         "test-event-source",
+		SourceID:       "any id",
+		EventType:      api.UpdateEventType,
+		ReconciledDate: &now,
+	})
+
+	// the events reconciled within the retention are kept
+	ctlMgr.purgeEvents()
+	Expect(purger.reconciledBefore).To(BeTemporally("~", now.Add(-time.Hour), time.Minute))
+	Expect(purger.batchSize).To(Equal(10))
+	Expect(ctlMgr.eventsQueue.Len()).To(Equal(0))
+
+	// the unreconciled events are requeued
+	ctlMgr.syncEvents()
+	Expect(ctlMgr.eventsQueue.Len()).To(Equal(1))
+	key, _ := ctlMgr.eventsQueue.Get()
+	Expect(key).To(Equal("1"))
+}
+
+func TestSweepUnreconciledEvents(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eventsDao := mocks.NewEventDao()
+	events := services.NewEventService(eventsDao)
+	ctlMgr := NewSpecControllerManager(dbmocks.NewMockAdvisoryLockFactory(), events, &fakeEventsPurger{},
+		NewSpecControllerOptions())
+
+	now := time.Now()
+	_, _ = eventsDao.Create(ctx, &api.Event{
+		Meta:      api.Meta{ID: "1"},
+		Source:    "test-event-source",
+		SourceID:  "any id",
+		EventType: api.CreateEventType,
+	})
+	_, _ = eventsDao.Create(ctx, &api.Event{
+		Meta:           api.Meta{ID: "2"},
+		Source:         "test-event-source",
+		SourceID:       "any id",
+		EventType:      api.UpdateEventType,
+		ReconciledDate: &now,
+	})
+
+	// the sweeps requested before the sweep is run are coalesced
+	ctlMgr.SweepUnreconciledEvents()
+	ctlMgr.SweepUnreconciledEvents()
+	Expect(ctlMgr.sweepRequests).To(HaveLen(1))
+
+	go ctlMgr.runSweeps(ctx)
+	Eventually(ctlMgr.eventsQueue.Len).Should(Equal(1))
+	key, _ := ctlMgr.eventsQueue.Get()
+	Expect(key).To(Equal("1"))
+	Expect(ctlMgr.sweepRequests).To(BeEmpty())
+}
+
+func TestSpecControllerManagerDeadLetter(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := context.Background()
+	eventsDao := mocks.NewEventDao()
+	events := services.NewEventService(eventsDao)
+	options := NewSpecControllerOptions()
+	options.MaxRetries = 2
+	store := &fakePoisonedEventStore{}
+	ctlMgr := NewSpecControllerManager(dbmocks.NewMockAdvisoryLockFactory(), events, &fakeEventsPurger{}, options).
+		WithDeadLetter(store, eventstesting.NewTestingEventRecorder(t))
+
+	attempts := 0
+	ctlMgr.Add(&controllers.ControllerConfig{
+		Source: "test-event-source",
+		Handlers: map[api.EventType][]controllers.ControllerHandlerFunc{
+			api.CreateEventType: {func(ctx context.Context, id string) error {
+				attempts++
+				return fmt.Errorf("failed to handle %s", id)
+			}},
+		},
+	})
+
+	_, _ = eventsDao.Create(ctx, &api.Event{
+		Meta:      api.Meta{ID: "1"},
+		Source:    "test-event-source",
+		SourceID:  "resource1",
+		EventType: api.CreateEventType,
+	})
+
+	// the event is handled once and retried twice, then it is poisoned
+	ctlMgr.AddEvent("1")
+	for i := 0; i < 3; i++ {
+		Expect(ctlMgr.processNextEvent()).To(BeTrue())
+	}
+	Expect(attempts).To(Equal(3))
+	Expect(ctlMgr.eventsQueue.Len()).To(Equal(0))
+	Expect(ctlMgr.eventsQueue.NumRequeues("1")).To(Equal(0))
+
+	Expect(store.poisoned).To(HaveLen(1))
+	Expect(store.poisoned[0].EventID).To(Equal("1"))
+	Expect(store.poisoned[0].SourceID).To(Equal("resource1"))
+	Expect(store.poisoned[0].EventType).To(Equal(string(api.CreateEventType)))
+	Expect(store.poisoned[0].Attempts).To(Equal(3))
+	Expect(store.poisoned[0].LastError).To(ContainSubstring("failed to handle resource1"))
+
+	// the poisoned event is not requeued by the events sync
+	ctlMgr.syncEvents()
+	Expect(ctlMgr.eventsQueue.Len()).To(Equal(0))
+}
+
+func TestSpecControllerManagerEventOwnership(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := context.Background()
+	eventsDao := mocks.NewEventDao()
+	events := services.NewEventService(eventsDao)
+	ownership := &fakeEventOwnership{others: map[string]bool{"2": true}}
+	ctlMgr := NewSpecControllerManager(dbmocks.NewMockAdvisoryLockFactory(), events, &fakeEventsPurger{},
+		NewSpecControllerOptions()).WithEventOwnership(ownership)
+
+	ctrl := &testSpecController{}
+	config := newTestSpecControllerConfig(ctrl)
+	ctlMgr.Add(config)
+
+	for _, id := range []string{"1", "2"} {
+		_, _ = eventsDao.Create(ctx, &api.Event{
+			Meta:      api.Meta{ID: id},
+			Source:    config.Source,
+			SourceID:  "resource" + id,
+			EventType: api.CreateEventType,
+		})
+	}
+
+	// the event of the other instance is left to it
+	processed, err := ctlMgr.handleEvent("2")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(processed).To(BeTrue())
+	Expect(ctrl.addCounter).To(Equal(0))
+
+	processed, err = ctlMgr.handleEvent("1")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(processed).To(BeTrue())
+	Expect(ctrl.addCounter).To(Equal(1))
+
+	// the unreconciled events are requeued on every instance, the events of the other instances are skipped when
+	// they are handled
+	ctlMgr.syncEvents()
+	Expect(ctlMgr.eventsQueue.Len()).To(Equal(1))
+	key, _ := ctlMgr.eventsQueue.Get()
+	Expect(key).To(Equal("2"))
+	ctlMgr.eventsQueue.Done(key)
+
+	// the events are handled by this instance if the ownership is unknown
+	ownership.err = fmt.Errorf("db unavailable")
+	processed, err = ctlMgr.handleEvent("2")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(processed).To(BeTrue())
+	Expect(ctrl.addCounter).To(Equal(2))
+}
@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	k8smetrics "k8s.io/component-base/metrics"
+)
+
+// subsystem used to define the metrics of the status update limiter
+const limiterMetricsSubsystem = "status_update_limiter"
+
+// Names of the labels added to metrics:
+const (
+	limiterMetricsReasonLabel = "reason"
+)
+
+// Values of the reason label:
+const (
+	// reasonRateLimit means the token bucket of the cluster is empty
+	reasonRateLimit = "rate_limit"
+	// reasonConcurrency means the pool of the status updates that are being handled is full
+	reasonConcurrency = "concurrency"
+)
+
+// Names of the status update limiter metrics:
+const (
+	throttledCountMetric = "throttled_total"
+	inFlightMetric       = "in_flight"
+)
+
+// statusUpdatesThrottled is a counter metric that tracks the number of the rejected status updates by
+// the reason.
+var statusUpdatesThrottled = k8smetrics.NewCounterVec(&k8smetrics.CounterOpts{
+	Subsystem:      limiterMetricsSubsystem,
+	Name:           throttledCountMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Total number of the manifest bundle status updates rejected by the limiter.",
+}, []string{limiterMetricsReasonLabel})
+
+// statusUpdatesInFlight is a gauge metric that tracks the number of the status updates being handled.
+var statusUpdatesInFlight = k8smetrics.NewGauge(&k8smetrics.GaugeOpts{
+	Subsystem:      limiterMetricsSubsystem,
+	Name:           inFlightMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Current number of the manifest bundle status updates being handled.",
+})
+
+// LimiterMetrics returns the metrics of the status update limiter, they measure the throttled and the in-flight
+// status updates.
+func LimiterMetrics() []k8smetrics.Registerable {
+	return []k8smetrics.Registerable{
+		statusUpdatesThrottled,
+		statusUpdatesInFlight,
+	}
+}
@@ -0,0 +1,175 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+// pruneInterval is the interval that the idle rate limiters of the clusters are removed, a rate limiter is
+// idle if its bucket is full.
+const pruneInterval = 10 * time.Minute
+
+// Options defines the limits of the manifest bundle status updates that are published by the agents.
+type Options struct {
+	// QPS is the number of the status updates that are refilled to the token bucket of a cluster per second.
+	QPS float64 `json:"qps,omitempty" yaml:"qps,omitempty"`
+
+	// Burst is the size of the token bucket of a cluster.
+	Burst int `json:"burst,omitempty" yaml:"burst,omitempty"`
+
+	// MaxConcurrency is the max number of the status updates of all of the clusters that are handled at the
+	// same time.
+	MaxConcurrency int `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		QPS:            10,
+		Burst:          50,
+		MaxConcurrency: 32,
+	}
+}
+
+// Validate validates the options, the errors are reported with the given field path.
+func (o *Options) Validate(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if o.QPS <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("qps"), o.QPS, "must be greater than 0"))
+	}
+	if o.Burst < 1 {
+		errs = append(errs, field.Invalid(fldPath.Child("burst"), o.Burst, "must be at least 1"))
+	}
+	if o.MaxConcurrency < 1 {
+		errs = append(errs, field.Invalid(fldPath.Child("max_concurrency"), o.MaxConcurrency, "must be at least 1"))
+	}
+	return errs
+}
+
+// Limiter limits the status updates with a token bucket for each cluster and a pool of the status updates
+// that are handled at the same time. The exceeded status updates are rejected with the ResourceExhausted code,
+// so the agents retry them later.
+type Limiter struct {
+	sync.Mutex
+	qps       rate.Limit
+	burst     int
+	slots     chan struct{}
+	clusters  map[string]*rate.Limiter
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+func NewLimiter(options *Options) *Limiter {
+	return &Limiter{
+		qps:       rate.Limit(options.QPS),
+		burst:     options.Burst,
+		slots:     make(chan struct{}, options.MaxConcurrency),
+		clusters:  map[string]*rate.Limiter{},
+		lastPrune: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Acquire takes a token of the cluster and a slot of the pool for a status update, the returned function
+// releases the slot after the status update is handled.
+func (l *Limiter) Acquire(clusterName string) (func(), error) {
+	if !l.allow(clusterName) {
+		statusUpdatesThrottled.WithLabelValues(reasonRateLimit).Inc()
+		return nil, status.Errorf(codes.ResourceExhausted,
+			"the status updates of cluster %s exceed the rate limit, retry later", clusterName)
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		statusUpdatesThrottled.WithLabelValues(reasonConcurrency).Inc()
+		return nil, status.Errorf(codes.ResourceExhausted,
+			"too many status updates are being handled, retry the status update of cluster %s later", clusterName)
+	}
+	statusUpdatesInFlight.Inc()
+
+	return func() {
+		statusUpdatesInFlight.Dec()
+		<-l.slots
+	}, nil
+}
+
+func (l *Limiter) allow(clusterName string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastPrune) >= pruneInterval {
+		for name, limiter := range l.clusters {
+			if limiter.TokensAt(now) >= float64(l.burst) {
+				delete(l.clusters, name)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	limiter, ok := l.clusters[clusterName]
+	if !ok {
+		limiter = rate.NewLimiter(l.qps, l.burst)
+		l.clusters[clusterName] = limiter
+	}
+	return limiter.AllowN(now, 1)
+}
+
+// UnaryServerInterceptor limits the manifest bundle status updates that are published to the gRPC broker, the
+// other requests are not limited. It is expected to be chained after the authorizers, so the cluster name of
+// the status update is authorized for the agent.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if info.FullMethod != pbv1.CloudEventService_Publish_FullMethodName {
+			return handler(ctx, req)
+		}
+
+		clusterName, ok := statusUpdateClusterName(req)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		release, err := l.Acquire(clusterName)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// statusUpdateClusterName returns the cluster name of a manifest bundle status update, false is returned for
+// the other requests, the invalid requests are rejected by the broker.
+func statusUpdateClusterName(req any) (string, bool) {
+	pReq, ok := req.(*pbv1.PublishRequest)
+	if !ok || pReq.Event == nil {
+		return "", false
+	}
+
+	eventsType, err := types.ParseCloudEventsType(pReq.Event.Type)
+	if err != nil {
+		return "", false
+	}
+	if eventsType.CloudEventsDataType != payload.ManifestBundleEventDataType ||
+		eventsType.SubResource != types.SubResourceStatus {
+		return "", false
+	}
+
+	// the attributes of the published cloudevent have the `ce-` prefix
+	clusterAttr, ok := pReq.Event.Attributes[fmt.Sprintf("ce-%s", types.ExtensionClusterName)]
+	if !ok {
+		return "", false
+	}
+	return clusterAttr.GetCeString(), true
+}
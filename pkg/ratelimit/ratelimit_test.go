@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	k8smetrics "k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/testutil"
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+)
+
+func TestOptionsValidate(t *testing.T) {
+	assert.Empty(t, NewOptions().Validate(field.NewPath("limit")))
+
+	fields := []string{}
+	for _, err := range (&Options{}).Validate(field.NewPath("limit")) {
+		fields = append(fields, err.Field)
+	}
+	assert.Equal(t, []string{"limit.qps", "limit.burst", "limit.max_concurrency"}, fields)
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	registry := k8smetrics.NewKubeRegistry()
+	registry.MustRegister(LimiterMetrics()...)
+
+	now := time.Now()
+	limiter := NewLimiter(&Options{QPS: 1, Burst: 2, MaxConcurrency: 2})
+	limiter.now = func() time.Time { return now }
+
+	// the bucket of cluster1 is drained by the burst
+	release1, err := limiter.Acquire("cluster1")
+	assert.NoError(t, err)
+	release1()
+	release2, err := limiter.Acquire("cluster1")
+	assert.NoError(t, err)
+	_, err = limiter.Acquire("cluster1")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// the other clusters are not limited by cluster1, but the pool is full
+	release3, err := limiter.Acquire("cluster2")
+	assert.NoError(t, err)
+	_, err = limiter.Acquire("cluster3")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	inFlight, err := testutil.GetGaugeMetricValue(statusUpdatesInFlight)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), inFlight)
+	release2()
+	release3()
+
+	// the bucket of cluster1 is refilled
+	now = now.Add(time.Second)
+	release, err := limiter.Acquire("cluster1")
+	assert.NoError(t, err)
+	release()
+
+	rateLimited, err := testutil.GetCounterMetricValue(statusUpdatesThrottled.WithLabelValues(reasonRateLimit))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), rateLimited)
+	concurrency, err := testutil.GetCounterMetricValue(statusUpdatesThrottled.WithLabelValues(reasonConcurrency))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), concurrency)
+
+	// the idle buckets are pruned
+	now = now.Add(pruneInterval)
+	release, err = limiter.Acquire("cluster4")
+	assert.NoError(t, err)
+	release()
+	assert.Len(t, limiter.clusters, 1)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	limiter := NewLimiter(&Options{QPS: 1, Burst: 1, MaxConcurrency: 1})
+	interceptor := limiter.UnaryServerInterceptor()
+	publish := &grpc.UnaryServerInfo{FullMethod: pbv1.CloudEventService_Publish_FullMethodName}
+	handler := func(ctx context.Context, req any) (any, error) { return "handled", nil }
+
+	newPublishRequest := func(eventType string) *pbv1.PublishRequest {
+		return &pbv1.PublishRequest{Event: &pbv1.CloudEvent{
+			Type: eventType,
+			Attributes: map[string]*pbv1.CloudEventAttributeValue{
+				"ce-clustername": {Attr: &pbv1.CloudEventAttributeValue_CeString{CeString: "cluster1"}},
+			},
+		}}
+	}
+	statusUpdate := newPublishRequest("io.open-cluster-management.works.v1alpha1.manifestbundles.status.update_request")
+	leaseUpdate := newPublishRequest("io.k8s.coordination.v1.leases.status.update_request")
+
+	resp, err := interceptor(context.Background(), statusUpdate, publish, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "handled", resp)
+
+	_, err = interceptor(context.Background(), statusUpdate, publish, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// the other events are not limited
+	resp, err = interceptor(context.Background(), leaseUpdate, publish, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "handled", resp)
+
+	// the pool slot is released after the status update is handled
+	assert.Len(t, limiter.slots, 0)
+}
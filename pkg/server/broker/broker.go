@@ -0,0 +1,370 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options"
+	cepayload "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/server"
+)
+
+var _ server.AgentEventServer = &MessageQueueBroker{}
+
+// MessageQueueBroker serves the registered services over a message queue broker, e.g. MQTT or Kafka, in the
+// same way as the gRPC broker of the sdk-go. The conductor connects to the broker as a source, it publishes
+// the resource specs to the agents and receives the resource status and the spec resync requests from them.
+type MessageQueueBroker struct {
+	sync.RWMutex
+	sourceOptions *options.CloudEventsSourceOptions
+	services      map[types.CloudEventsDataType]server.Service
+	client        cloudevents.Client
+}
+
+// NewMessageQueueBroker creates a MessageQueueBroker with the source options of the broker, see
+// generic.BuildCloudEventsSourceOptions.
+func NewMessageQueueBroker(sourceOptions *options.CloudEventsSourceOptions) *MessageQueueBroker {
+	return &MessageQueueBroker{
+		sourceOptions: sourceOptions,
+		services:      make(map[types.CloudEventsDataType]server.Service),
+	}
+}
+
+func (b *MessageQueueBroker) RegisterService(t types.CloudEventsDataType, service server.Service) {
+	b.services[t] = service
+	service.RegisterHandler(b)
+}
+
+// Subscribers returns an empty set, since the subscriptions of the agents are kept by the message queue
+// broker.
+func (b *MessageQueueBroker) Subscribers() sets.Set[string] {
+	return sets.New[string]()
+}
+
+// Run connects the message queue broker and handles the events from the agents until the context is done,
+// the connection is rebuilt when it is lost.
+func (b *MessageQueueBroker) Run(ctx context.Context) error {
+	logger := klog.FromContext(ctx)
+	for {
+		err := b.receive(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		logger.Error(err, "The message queue broker is disconnected, reconnecting")
+
+		timer := time.NewTimer(generic.DelayFn())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// receive connects the message queue broker and handles the received events until the context is done or
+// the connection is lost.
+func (b *MessageQueueBroker) receive(ctx context.Context) error {
+	// the data type is not used by the message queue protocols, all of the events are sent and received
+	// with the same protocol.
+	protocol, err := b.sourceOptions.CloudEventsOptions.Protocol(ctx, payload.ManifestBundleEventDataType)
+	if err != nil {
+		return fmt.Errorf("failed to connect the message queue broker: %w", err)
+	}
+	defer func() {
+		if err := protocol.Close(context.Background()); err != nil {
+			klog.Errorf("failed to close the message queue protocol: %v", err)
+		}
+	}()
+
+	client, err := cloudevents.NewClient(protocol)
+	if err != nil {
+		return err
+	}
+	b.setClient(client)
+	defer b.setClient(nil)
+
+	receiverCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	receiverErrCh := make(chan error, 1)
+	go func() {
+		receiverErrCh <- client.StartReceiver(receiverCtx, func(ctx context.Context, evt cloudevents.Event) {
+			b.handleEvent(ctx, evt)
+		})
+	}()
+	klog.FromContext(ctx).Info("Connected the message queue broker", "source", b.sourceOptions.SourceID)
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-b.sourceOptions.CloudEventsOptions.ErrorChan():
+		return err
+	case err := <-receiverErrCh:
+		if err == nil {
+			err = fmt.Errorf("the receiver is stopped")
+		}
+		return err
+	}
+}
+
+// handleEvent handles the resource status and the spec resync request from the agents.
+func (b *MessageQueueBroker) handleEvent(ctx context.Context, evt cloudevents.Event) {
+	logger := klog.FromContext(ctx)
+
+	eventType, err := types.ParseCloudEventsType(evt.Type())
+	if err != nil {
+		logger.Error(err, "Failed to parse the cloud event type", "type", evt.Type())
+		return
+	}
+
+	logger.V(4).Info("receive the event with message queue broker", "event", evt.Context)
+
+	service, ok := b.services[eventType.CloudEventsDataType]
+	if !ok {
+		logger.V(4).Info("Ignore the event without service", "type", eventType)
+		return
+	}
+
+	switch {
+	case eventType.Action == types.ResyncRequestAction && eventType.SubResource == types.SubResourceSpec:
+		if err := b.respondResyncSpecRequest(ctx, eventType.CloudEventsDataType, service, &evt); err != nil {
+			logger.Error(err, "Failed to respond resync spec request", "event", evt.Context)
+		}
+	case eventType.SubResource == types.SubResourceStatus:
+		if err := service.HandleStatusUpdate(ctx, &evt); err != nil {
+			logger.Error(err, "Failed to handle the status update", "event", evt.Context)
+		}
+	default:
+		logger.V(4).Info("Ignore the unsupported event", "type", eventType)
+	}
+}
+
+// respondResyncSpecRequest responds the spec resync request of an agent, see respondResync.
+func (b *MessageQueueBroker) respondResyncSpecRequest(ctx context.Context, dataType types.CloudEventsDataType,
+	service server.Service, evt *cloudevents.Event) error {
+	return respondResync(ctx, b.sourceOptions.SourceID, dataType, service, evt, b.publish)
+}
+
+// OnCreate is called by the service when a resource is created.
+func (b *MessageQueueBroker) OnCreate(ctx context.Context, t types.CloudEventsDataType, resourceID string) error {
+	return b.publishResource(ctx, t, resourceID, types.CreateRequestAction)
+}
+
+// OnUpdate is called by the service when a resource is updated.
+func (b *MessageQueueBroker) OnUpdate(ctx context.Context, t types.CloudEventsDataType, resourceID string) error {
+	return b.publishResource(ctx, t, resourceID, types.UpdateRequestAction)
+}
+
+// OnDelete is called by the service when a resource is deleted.
+func (b *MessageQueueBroker) OnDelete(ctx context.Context, t types.CloudEventsDataType, resourceID string) error {
+	return b.publishResource(ctx, t, resourceID, types.DeleteRequestAction)
+}
+
+func (b *MessageQueueBroker) publishResource(ctx context.Context, t types.CloudEventsDataType, resourceID string,
+	action types.EventAction) error {
+	service, ok := b.services[t]
+	if !ok {
+		return fmt.Errorf("failed to find service for event type %s", t)
+	}
+
+	resource, err := service.Get(ctx, resourceID)
+	// if the resource is not found, it indicates the resource has been processed.
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return b.publish(ctx, resource, t, action)
+}
+
+// publish sends the resource spec to the agent of its cluster, an error is returned if the broker is not
+// connected, so the event is requeued by the service.
+func (b *MessageQueueBroker) publish(ctx context.Context, evt *cloudevents.Event, t types.CloudEventsDataType,
+	action types.EventAction) error {
+	evt.SetType(types.CloudEventsType{
+		CloudEventsDataType: t,
+		SubResource:         types.SubResourceSpec,
+		Action:              action,
+	}.String())
+
+	client := b.getClient()
+	if client == nil {
+		return fmt.Errorf("the message queue broker is not connected")
+	}
+
+	sendingCtx, err := b.sourceOptions.CloudEventsOptions.WithContext(ctx, evt.Context)
+	if err != nil {
+		return err
+	}
+
+	klog.FromContext(ctx).V(4).Info("sending the event to the message queue broker", "event", evt.Context)
+	if result := client.Send(sendingCtx, *evt); cloudevents.IsUndelivered(result) {
+		return fmt.Errorf("failed to send the event %s: %w", evt.ID(), result)
+	}
+	return nil
+}
+
+// CheckConnection returns an error if the message queue broker is not connected.
+func (b *MessageQueueBroker) CheckConnection() error {
+	if b.getClient() == nil {
+		return fmt.Errorf("the message queue broker is not connected")
+	}
+	return nil
+}
+
+func (b *MessageQueueBroker) getClient() cloudevents.Client {
+	b.RLock()
+	defer b.RUnlock()
+	return b.client
+}
+
+func (b *MessageQueueBroker) setClient(client cloudevents.Client) {
+	b.Lock()
+	defer b.Unlock()
+	b.client = client
+}
+
+// respondResync responds the spec resync request of an agent in the same way as the gRPC broker of the sdk-go:
+// the resources that are newer than the agent or deleting are sent with publish, and the resources that do not
+// exist on the source are deleted from the agent with the delete markers of the sourceID. The versions are
+// compared by the service if it supports it, see resyncLister.
+func respondResync(ctx context.Context, sourceID string, dataType types.CloudEventsDataType, service server.Service,
+	evt *cloudevents.Event, publish publishFunc) error {
+	logger := klog.FromContext(ctx)
+
+	resourceVersions, err := cepayload.DecodeSpecResyncRequest(*evt)
+	if err != nil {
+		return err
+	}
+
+	clusterName, err := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionClusterName])
+	if err != nil {
+		return fmt.Errorf("failed to get the cluster name of the resync request: %w", err)
+	}
+
+	listOpts := types.ListOptions{ClusterName: clusterName, CloudEventsDataType: dataType}
+	if lister, ok := service.(resyncLister); ok {
+		return lister.ListChanged(ctx, listOpts, resourceVersions, func(objs []*cloudevents.Event) error {
+			for _, obj := range objs {
+				action := types.UpdateRequestAction
+				if _, ok := obj.Extensions()[types.ExtensionDeletionTimestamp]; ok {
+					action = types.DeleteRequestAction
+				}
+				if err := publish(ctx, obj, dataType, action); err != nil {
+					logger.Error(err, "Failed to respond resync spec request", "event", obj.Context)
+				}
+			}
+			return nil
+		})
+	}
+
+	existing := sets.New[string]()
+	if err := listPages(ctx, service, listOpts, func(objs []*cloudevents.Event) error {
+		for _, obj := range objs {
+			resourceID, err := cloudeventstypes.ToString(obj.Extensions()[types.ExtensionResourceID])
+			if err != nil {
+				logger.V(4).Info("Ignore the resource without a resource ID", "event", obj.ID(), "error", err)
+				continue
+			}
+			existing.Insert(resourceID)
+
+			if _, ok := obj.Extensions()[types.ExtensionDeletionTimestamp]; ok {
+				if err := publish(ctx, obj, dataType, types.DeleteRequestAction); err != nil {
+					logger.Error(err, "Failed to respond resync spec request", "resource", resourceID)
+				}
+				continue
+			}
+
+			currentResourceVersion, err := cloudeventstypes.ToInteger(obj.Extensions()[types.ExtensionResourceVersion])
+			if err != nil {
+				logger.V(4).Info("Ignore the resource with an invalid resource version", "resource", resourceID, "error", err)
+				continue
+			}
+
+			if currentResourceVersion == 0 || int64(currentResourceVersion) > lastResourceVersion(resourceID, resourceVersions) {
+				if err := publish(ctx, obj, dataType, types.UpdateRequestAction); err != nil {
+					logger.Error(err, "Failed to respond resync spec request", "resource", resourceID)
+				}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, rv := range resourceVersions.Versions {
+		if existing.Has(rv.ResourceID) {
+			continue
+		}
+
+		obj := types.NewEventBuilder(sourceID, types.CloudEventsType{
+			CloudEventsDataType: dataType,
+			SubResource:         types.SubResourceSpec,
+		}).WithResourceID(rv.ResourceID).
+			WithResourceVersion(rv.ResourceVersion).
+			WithClusterName(clusterName).
+			WithDeletionTimestamp(time.Now()).
+			NewEvent()
+		if err := publish(ctx, &obj, dataType, types.DeleteRequestAction); err != nil {
+			logger.Error(err, "Failed to respond resync spec request", "resource", rv.ResourceID)
+		}
+	}
+
+	return nil
+}
+
+// publishFunc sends the resource spec to the agent of its cluster with the action.
+type publishFunc func(ctx context.Context, evt *cloudevents.Event, t types.CloudEventsDataType,
+	action types.EventAction) error
+
+// pagedLister is implemented by the services that list the resources page by page, e.g. the RouterService,
+// so the resources of a cluster are not loaded into memory at once when responding a resync request.
+type pagedLister interface {
+	ListPages(ctx context.Context, listOpts types.ListOptions, fn func(evts []*cloudevents.Event) error) error
+}
+
+// resyncLister is implemented by the services that compare the resource versions of the agent by themselves,
+// e.g. the RouterService, only the resources that the agent needs and the delete markers of the resources that
+// the agent should remove are listed.
+type resyncLister interface {
+	ListChanged(ctx context.Context, listOpts types.ListOptions, versions *cepayload.ResourceVersionList,
+		fn func(evts []*cloudevents.Event) error) error
+}
+
+// listPages passes the resources of the service to fn page by page if the service supports it, otherwise
+// all of the resources are passed to fn at once.
+func listPages(ctx context.Context, service server.Service, listOpts types.ListOptions,
+	fn func(evts []*cloudevents.Event) error) error {
+	if lister, ok := service.(pagedLister); ok {
+		return lister.ListPages(ctx, listOpts, fn)
+	}
+
+	objs, err := service.List(listOpts)
+	if err != nil {
+		return err
+	}
+	return fn(objs)
+}
+
+// lastResourceVersion returns the resource version of the resource on the agent, 0 is returned if the agent
+// does not have the resource.
+func lastResourceVersion(resourceID string, resourceVersions *cepayload.ResourceVersionList) int64 {
+	for _, version := range resourceVersions.Versions {
+		if version.ResourceID == resourceID {
+			return version.ResourceVersion
+		}
+	}
+	return 0
+}
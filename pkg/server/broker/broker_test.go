@@ -0,0 +1,276 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/stretchr/testify/assert"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/fake"
+	cepayload "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/server"
+)
+
+// fakeProtocol records the sent events and receives the events that are injected by the test.
+type fakeProtocol struct {
+	sync.Mutex
+	sent     []cloudevents.Event
+	incoming chan cloudevents.Event
+}
+
+func (p *fakeProtocol) Send(ctx context.Context, m binding.Message, transformers ...binding.Transformer) error {
+	evt, err := binding.ToEvent(ctx, m, transformers...)
+	if err != nil {
+		return err
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.sent = append(p.sent, *evt)
+	return nil
+}
+
+func (p *fakeProtocol) Receive(ctx context.Context) (binding.Message, error) {
+	select {
+	case <-ctx.Done():
+		return nil, io.EOF
+	case evt := <-p.incoming:
+		return binding.ToMessage(&evt), nil
+	}
+}
+
+func (p *fakeProtocol) Close(ctx context.Context) error {
+	return nil
+}
+
+// sentTypes returns the resource IDs and the types of the sent events.
+func (p *fakeProtocol) sentTypes() map[string]string {
+	p.Lock()
+	defer p.Unlock()
+	sent := map[string]string{}
+	for _, evt := range p.sent {
+		sent[evt.Extensions()[types.ExtensionResourceID].(string)] = evt.Type()
+	}
+	return sent
+}
+
+type fakeService struct {
+	sync.Mutex
+	resources map[string]*cloudevents.Event
+	statuses  []string
+	handler   server.EventHandler
+}
+
+func (s *fakeService) Get(ctx context.Context, resourceID string) (*cloudevents.Event, error) {
+	evt, ok := s.resources[resourceID]
+	if !ok {
+		return nil, kubeerrors.NewNotFound(schema.GroupResource{Resource: "resources"}, resourceID)
+	}
+	return evt, nil
+}
+
+func (s *fakeService) List(listOpts types.ListOptions) ([]*cloudevents.Event, error) {
+	evts := []*cloudevents.Event{}
+	for _, evt := range s.resources {
+		evts = append(evts, evt)
+	}
+	return evts, nil
+}
+
+func (s *fakeService) HandleStatusUpdate(ctx context.Context, evt *cloudevents.Event) error {
+	s.Lock()
+	defer s.Unlock()
+	s.statuses = append(s.statuses, evt.Extensions()[types.ExtensionResourceID].(string))
+	return nil
+}
+
+func (s *fakeService) RegisterHandler(handler server.EventHandler) {
+	s.handler = handler
+}
+
+func (s *fakeService) handledStatuses() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string{}, s.statuses...)
+}
+
+// fakePagedService lists the resources of the fakeService in pages of one resource.
+type fakePagedService struct {
+	*fakeService
+	pages int
+}
+
+func (s *fakePagedService) List(listOpts types.ListOptions) ([]*cloudevents.Event, error) {
+	return nil, fmt.Errorf("the resources should be listed by pages")
+}
+
+func (s *fakePagedService) ListPages(ctx context.Context, listOpts types.ListOptions,
+	fn func(evts []*cloudevents.Event) error) error {
+	for _, evt := range s.resources {
+		s.pages++
+		if err := fn([]*cloudevents.Event{evt}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fakeResyncService lists the changed resources that are prepared by the test, the resource versions of the
+// agent are recorded.
+type fakeResyncService struct {
+	*fakeService
+	changed  []*cloudevents.Event
+	versions *cepayload.ResourceVersionList
+}
+
+func (s *fakeResyncService) ListChanged(ctx context.Context, listOpts types.ListOptions,
+	versions *cepayload.ResourceVersionList, fn func(evts []*cloudevents.Event) error) error {
+	s.versions = versions
+	return fn(s.changed)
+}
+
+func newSpecEvent(resourceID string, resourceVersion int64) *cloudevents.Event {
+	evt := types.NewEventBuilder("maestro", types.CloudEventsType{
+		CloudEventsDataType: payload.ManifestBundleEventDataType,
+		SubResource:         types.SubResourceSpec,
+	}).WithResourceID(resourceID).
+		WithResourceVersion(resourceVersion).
+		WithClusterName("cluster1").
+		NewEvent()
+	return &evt
+}
+
+func newAgentEvent(subResource types.EventSubResource, action types.EventAction) cloudevents.Event {
+	return types.NewEventBuilder("cluster1-work-agent", types.CloudEventsType{
+		CloudEventsDataType: payload.ManifestBundleEventDataType,
+		SubResource:         subResource,
+		Action:              action,
+	}).WithResourceID("r1").
+		WithClusterName("cluster1").
+		NewEvent()
+}
+
+func TestMessageQueueBroker(t *testing.T) {
+	protocol := &fakeProtocol{incoming: make(chan cloudevents.Event)}
+	service := &fakeService{resources: map[string]*cloudevents.Event{
+		"r1": newSpecEvent("r1", 2),
+		"r3": newSpecEvent("r3", 1),
+	}}
+	broker := NewMessageQueueBroker(fake.NewSourceOptions(protocol, "maestro"))
+	broker.RegisterService(payload.ManifestBundleEventDataType, service)
+	assert.Equal(t, 0, broker.Subscribers().Len())
+
+	// the event is not sent before the broker is connected
+	assert.Error(t, service.handler.OnCreate(context.Background(), payload.ManifestBundleEventDataType, "r1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan error)
+	go func() { stopped <- broker.Run(ctx) }()
+	assert.Eventually(t, func() bool { return broker.getClient() != nil }, 5*time.Second, 10*time.Millisecond)
+
+	// publish the resource specs
+	assert.NoError(t, service.handler.OnCreate(ctx, payload.ManifestBundleEventDataType, "r1"))
+	assert.NoError(t, service.handler.OnDelete(ctx, payload.ManifestBundleEventDataType, "r3"))
+	assert.NoError(t, service.handler.OnUpdate(ctx, payload.ManifestBundleEventDataType, "r4"))
+	assert.Equal(t, map[string]string{
+		"r1": "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.create_request",
+		"r3": "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.delete_request",
+	}, protocol.sentTypes())
+
+	// handle the resource status
+	protocol.incoming <- newAgentEvent(types.SubResourceStatus, types.UpdateRequestAction)
+	assert.Eventually(t, func() bool { return len(service.handledStatuses()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"r1"}, service.handledStatuses())
+
+	// respond the spec resync request, r1 is newer on the source, r2 does not exist on the source and r3 is
+	// not on the agent
+	protocol.Lock()
+	protocol.sent = nil
+	protocol.Unlock()
+	resync := newAgentEvent(types.SubResourceSpec, types.ResyncRequestAction)
+	assert.NoError(t, resync.SetData(cloudevents.ApplicationJSON, &cepayload.ResourceVersionList{
+		Versions: []cepayload.ResourceVersion{
+			{ResourceID: "r1", ResourceVersion: 1},
+			{ResourceID: "r2", ResourceVersion: 1},
+		},
+	}))
+	protocol.incoming <- resync
+	assert.Eventually(t, func() bool { return len(protocol.sentTypes()) == 3 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]string{
+		"r1": "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.update_request",
+		"r2": "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.delete_request",
+		"r3": "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.update_request",
+	}, protocol.sentTypes())
+
+	cancel()
+	assert.NoError(t, <-stopped)
+}
+
+func TestMessageQueueBrokerResyncPages(t *testing.T) {
+	protocol := &fakeProtocol{incoming: make(chan cloudevents.Event)}
+	service := &fakePagedService{fakeService: &fakeService{resources: map[string]*cloudevents.Event{
+		"r1": newSpecEvent("r1", 2),
+		"r3": newSpecEvent("r3", 1),
+	}}}
+	broker := NewMessageQueueBroker(fake.NewSourceOptions(protocol, "maestro"))
+	broker.RegisterService(payload.ManifestBundleEventDataType, service)
+
+	client, err := cloudevents.NewClient(protocol)
+	assert.NoError(t, err)
+	broker.setClient(client)
+
+	resync := newAgentEvent(types.SubResourceSpec, types.ResyncRequestAction)
+	assert.NoError(t, resync.SetData(cloudevents.ApplicationJSON, &cepayload.ResourceVersionList{
+		Versions: []cepayload.ResourceVersion{
+			{ResourceID: "r1", ResourceVersion: 2},
+			{ResourceID: "r2", ResourceVersion: 1},
+		},
+	}))
+	assert.NoError(t, broker.respondResyncSpecRequest(context.Background(), payload.ManifestBundleEventDataType,
+		service, &resync))
+	assert.Equal(t, 2, service.pages)
+	assert.Equal(t, map[string]string{
+		"r2": "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.delete_request",
+		"r3": "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.update_request",
+	}, protocol.sentTypes())
+}
+
+func TestMessageQueueBrokerResyncChanged(t *testing.T) {
+	protocol := &fakeProtocol{incoming: make(chan cloudevents.Event)}
+	deleting := types.NewEventBuilder("maestro", types.CloudEventsType{
+		CloudEventsDataType: payload.ManifestBundleEventDataType,
+		SubResource:         types.SubResourceSpec,
+	}).WithResourceID("r2").
+		WithResourceVersion(1).
+		WithClusterName("cluster1").
+		WithDeletionTimestamp(time.Now()).
+		NewEvent()
+	service := &fakeResyncService{
+		fakeService: &fakeService{resources: map[string]*cloudevents.Event{}},
+		changed:     []*cloudevents.Event{newSpecEvent("r1", 2), &deleting},
+	}
+	broker := NewMessageQueueBroker(fake.NewSourceOptions(protocol, "maestro"))
+	broker.RegisterService(payload.ManifestBundleEventDataType, service)
+
+	client, err := cloudevents.NewClient(protocol)
+	assert.NoError(t, err)
+	broker.setClient(client)
+
+	resync := newAgentEvent(types.SubResourceSpec, types.ResyncRequestAction)
+	assert.NoError(t, resync.SetData(cloudevents.ApplicationJSON, &cepayload.ResourceVersionList{}))
+	assert.NoError(t, broker.respondResyncSpecRequest(context.Background(), payload.ManifestBundleEventDataType,
+		service, &resync))
+	assert.Equal(t, map[string]string{
+		"r1": "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.update_request",
+		"r2": "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.delete_request",
+	}, protocol.sentTypes())
+}
@@ -0,0 +1,112 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	grpcprotocol "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protocol"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/server"
+	grpcceserver "open-cluster-management.io/sdk-go/pkg/cloudevents/server/grpc"
+)
+
+// resyncSourceID is the source of the delete markers that are sent by the GRPCBroker, it is the same as the
+// source of the gRPC broker of the sdk-go.
+const resyncSourceID = "source"
+
+var _ server.AgentEventServer = &GRPCBroker{}
+
+// GRPCBroker serves the registered services with the gRPC broker of the sdk-go, except that the spec resync
+// requests of the services that list the resources page by page or compare the resource versions by themselves,
+// e.g. the RouterService, are responded by the GRPCBroker, so the resources of a cluster are not loaded into
+// memory at once.
+type GRPCBroker struct {
+	*grpcceserver.GRPCBroker
+	services map[types.CloudEventsDataType]server.Service
+}
+
+func NewGRPCBroker() *GRPCBroker {
+	return &GRPCBroker{
+		GRPCBroker: grpcceserver.NewGRPCBroker(),
+		services:   make(map[types.CloudEventsDataType]server.Service),
+	}
+}
+
+func (b *GRPCBroker) RegisterService(t types.CloudEventsDataType, service server.Service) {
+	b.services[t] = service
+	b.GRPCBroker.RegisterService(t, &resyncService{Service: service})
+}
+
+// Publish responds the spec resync requests of the services that support it, the other requests are handled by
+// the gRPC broker of the sdk-go.
+func (b *GRPCBroker) Publish(ctx context.Context, pubReq *pbv1.PublishRequest) (*emptypb.Empty, error) {
+	// WARNING: don't use "evt, err := pb.FromProto(pubReq.Event)" to convert protobuf to cloudevent
+	evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pubReq.Event))
+	if err != nil {
+		return b.GRPCBroker.Publish(ctx, pubReq)
+	}
+	eventType, err := types.ParseCloudEventsType(evt.Type())
+	if err != nil || eventType.Action != types.ResyncRequestAction {
+		return b.GRPCBroker.Publish(ctx, pubReq)
+	}
+	service, ok := b.services[eventType.CloudEventsDataType]
+	if !ok || !respondsResync(service) {
+		return b.GRPCBroker.Publish(ctx, pubReq)
+	}
+
+	if err := respondResync(ctx, resyncSourceID, eventType.CloudEventsDataType, service, evt, b.send); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, fmt.Sprintf("failed to respond resync spec request: %v", err))
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// send sends the resource spec to the subscribers of its cluster on this instance. The resource is passed to the
+// gRPC broker of the sdk-go with the context, so it is not got from the service again, see resyncService.
+func (b *GRPCBroker) send(ctx context.Context, evt *cloudevents.Event, t types.CloudEventsDataType,
+	action types.EventAction) error {
+	resourceID, err := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+	if err != nil {
+		return fmt.Errorf("failed to get the resource ID of event %s: %w", evt.ID(), err)
+	}
+
+	ctx = context.WithValue(ctx, resyncEventKey{}, evt)
+	if action == types.DeleteRequestAction {
+		return b.GRPCBroker.OnDelete(ctx, t, resourceID)
+	}
+	return b.GRPCBroker.OnUpdate(ctx, t, resourceID)
+}
+
+// respondsResync returns true if the service lists the resources page by page or compares the resource versions
+// by itself.
+func respondsResync(service server.Service) bool {
+	switch service.(type) {
+	case pagedLister, resyncLister:
+		return true
+	default:
+		return false
+	}
+}
+
+type resyncEventKey struct{}
+
+// resyncService gets the resource that is being sent for a spec resync request from the context, the other
+// resources are got from the service.
+type resyncService struct {
+	server.Service
+}
+
+func (s *resyncService) Get(ctx context.Context, resourceID string) (*cloudevents.Event, error) {
+	if evt, ok := ctx.Value(resyncEventKey{}).(*cloudevents.Event); ok {
+		if id, err := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID]); err == nil && id == resourceID {
+			return evt, nil
+		}
+	}
+	return s.Service.Get(ctx, resourceID)
+}
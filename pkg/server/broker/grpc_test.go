@@ -0,0 +1,129 @@
+package broker
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/cloudevents/sdk-go/v2/binding"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+	grpcprotocol "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protocol"
+	cepayload "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+// toPublishRequest converts the agent event to the publish request of the gRPC broker.
+func toPublishRequest(t *testing.T, evt cloudevents.Event) *pbv1.PublishRequest {
+	pbEvt := &pbv1.CloudEvent{}
+	assert.NoError(t, grpcprotocol.WritePBMessage(context.Background(), binding.ToMessage(&evt), pbEvt))
+	return &pbv1.PublishRequest{Event: pbEvt}
+}
+
+func TestGRPCBroker(t *testing.T) {
+	deleting := types.NewEventBuilder("maestro", types.CloudEventsType{
+		CloudEventsDataType: payload.ManifestBundleEventDataType,
+		SubResource:         types.SubResourceSpec,
+	}).WithResourceID("r2").
+		WithResourceVersion(1).
+		WithClusterName("cluster1").
+		WithDeletionTimestamp(time.Now()).
+		NewEvent()
+	// r3 is only sent if the resources are listed with the List of the service
+	service := &fakeResyncService{
+		fakeService: &fakeService{resources: map[string]*cloudevents.Event{"r3": newSpecEvent("r3", 1)}},
+		changed:     []*cloudevents.Event{newSpecEvent("r1", 2), &deleting},
+	}
+	broker := NewGRPCBroker()
+	broker.RegisterService(payload.ManifestBundleEventDataType, service)
+
+	grpcServer := grpc.NewServer()
+	pbv1.RegisterCloudEventServiceServer(grpcServer, broker)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() { _ = grpcServer.Serve(lis) }()
+	defer grpcServer.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := pbv1.NewCloudEventServiceClient(conn)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Subscribe(ctx, &pbv1.SubscriptionRequest{
+		Source:      "maestro",
+		ClusterName: "cluster1",
+		DataType:    payload.ManifestBundleEventDataType.String(),
+	})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return broker.IsConsumerSubscribed("cluster1") }, 5*time.Second, 10*time.Millisecond)
+
+	// the status update is handled by the gRPC broker of the sdk-go
+	_, err = client.Publish(ctx, toPublishRequest(t, newAgentEvent(types.SubResourceStatus, types.UpdateRequestAction)))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"r1"}, service.handledStatuses())
+
+	// the resync request is responded with the changed resources of the service
+	resync := newAgentEvent(types.SubResourceSpec, types.ResyncRequestAction)
+	assert.NoError(t, resync.SetData(cloudevents.ApplicationJSON, &cepayload.ResourceVersionList{}))
+	_, err = client.Publish(ctx, toPublishRequest(t, resync))
+	assert.NoError(t, err)
+
+	received := map[string]string{}
+	for len(received) < 2 {
+		pbEvt, err := stream.Recv()
+		if !assert.NoError(t, err) {
+			return
+		}
+		evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+		assert.NoError(t, err)
+		if evt.Type() == types.HeartbeatCloudEventsType {
+			continue
+		}
+		received[evt.Extensions()[types.ExtensionResourceID].(string)] = evt.Type()
+	}
+	assert.Equal(t, map[string]string{
+		"r1": "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.update_request",
+		"r2": "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.delete_request",
+	}, received)
+
+	// the spec events of the service are got from the service
+	assert.NoError(t, service.handler.OnCreate(ctx, payload.ManifestBundleEventDataType, "r3"))
+	pbEvt, err := stream.Recv()
+	assert.NoError(t, err)
+	evt, err := binding.ToEvent(ctx, grpcprotocol.NewMessage(pbEvt))
+	assert.NoError(t, err)
+	assert.Equal(t, "r3", evt.Extensions()[types.ExtensionResourceID])
+	assert.Equal(t, "io.open-cluster-management.works.v1alpha1.manifestbundles.spec.create_request", evt.Type())
+}
+
+func TestGRPCBrokerResyncChanged(t *testing.T) {
+	service := &fakeResyncService{
+		fakeService: &fakeService{resources: map[string]*cloudevents.Event{"r1": newSpecEvent("r1", 2)}},
+	}
+	broker := NewGRPCBroker()
+	broker.RegisterService(payload.ManifestBundleEventDataType, service)
+
+	// the resource versions of the agent are compared by the service instead of the gRPC broker of the sdk-go
+	versions := &cepayload.ResourceVersionList{Versions: []cepayload.ResourceVersion{
+		{ResourceID: "r1", ResourceVersion: 2},
+		{ResourceID: "r2", ResourceVersion: 1},
+	}}
+	resync := newAgentEvent(types.SubResourceSpec, types.ResyncRequestAction)
+	assert.NoError(t, resync.SetData(cloudevents.ApplicationJSON, versions))
+	_, err := broker.Publish(context.Background(), toPublishRequest(t, resync))
+	assert.NoError(t, err)
+	assert.Equal(t, versions, service.versions)
+
+	// the resync request of a malformed version list is rejected
+	invalid := newAgentEvent(types.SubResourceSpec, types.ResyncRequestAction)
+	assert.NoError(t, invalid.SetData(cloudevents.ApplicationJSON, "versions"))
+	_, err = broker.Publish(context.Background(), toPublishRequest(t, invalid))
+	assert.Error(t, err)
+}
@@ -2,24 +2,36 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	dbconfig "github.com/openshift-online/maestro/pkg/config"
 	maestrodb "github.com/openshift-online/maestro/pkg/db"
 	"github.com/openshift-online/maestro/pkg/db/db_session"
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	"github.com/spf13/pflag"
+	"github.com/stolostron/cloudevents-conductor/pkg/audit"
 	"github.com/stolostron/cloudevents-conductor/pkg/controller"
 	"github.com/stolostron/cloudevents-conductor/pkg/controller/mq"
+	"github.com/stolostron/cloudevents-conductor/pkg/ratelimit"
+	"github.com/stolostron/cloudevents-conductor/pkg/server/broker"
 	"github.com/stolostron/cloudevents-conductor/pkg/services"
 	"github.com/stolostron/cloudevents-conductor/pkg/services/db"
 	"github.com/stolostron/cloudevents-conductor/pkg/services/db/consumer"
 	dbevent "github.com/stolostron/cloudevents-conductor/pkg/services/db/event"
 	"github.com/stolostron/cloudevents-conductor/pkg/services/db/resource"
 	dbstatusevent "github.com/stolostron/cloudevents-conductor/pkg/services/db/statusevent"
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db/streamowner"
+	"github.com/stolostron/cloudevents-conductor/pkg/tracing"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"gopkg.in/yaml.v2"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 	ocmgrpcserver "open-cluster-management.io/ocm/pkg/server/grpc"
 	"open-cluster-management.io/ocm/pkg/server/services/addon"
@@ -34,8 +46,9 @@ import (
 	eventce "open-cluster-management.io/sdk-go/pkg/cloudevents/clients/event"
 	leasece "open-cluster-management.io/sdk-go/pkg/cloudevents/clients/lease"
 	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic"
 	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
-	grpcceserver "open-cluster-management.io/sdk-go/pkg/cloudevents/server/grpc"
+	ceserver "open-cluster-management.io/sdk-go/pkg/cloudevents/server"
 	grpcauthz "open-cluster-management.io/sdk-go/pkg/cloudevents/server/grpc/authz/kube"
 	grpcserver "open-cluster-management.io/sdk-go/pkg/server/grpc"
 	grpcauthn "open-cluster-management.io/sdk-go/pkg/server/grpc/authn"
@@ -43,6 +56,8 @@ import (
 
 // GRPCServerConfig defines the configuration for the gRPC server.
 // It includes the gRPC server options and the database configuration.
+// The TLS files and the database configuration are reloaded when they are changed, the changes of the
+// other settings take effect after the conductor is restarted.
 // An example of this configuration is like:
 /*
 ```yaml
@@ -50,7 +65,7 @@ grpc_config:
   tls_cert_file: "/path/to/tls.crt"
   tls_key_file: "/path/to/tls.key"
   client_ca_file: "/path/to/ca.crt"
-  serverBindPort: "8090"
+  server_bind_port: "8090"
 db_config:
   host: "localhost"
   port: "5432"
@@ -58,56 +73,290 @@ db_config:
   username: "bar"
   password: "goo"
   sslmode: "disable"
+db_secret_ref:
+  namespace: "maestro"
+  name: "maestro-db-config"
+  keys:
+    host: "host"
+    port: "port"
+    name: "name"
+    username: "user"
+    password: "password"
+spec_controller_config:
+  workers: 4
+  events_sync_period: 10h
+  reconciled_events_retention: 24h
+  reconciled_events_purge_batch_size: 1000
+  max_retries: 10
+broker_config:
+  type: "grpc"
+audit_config:
+  path: "/var/log/conductor/audit.log"
+  max_size: 100
+  max_backups: 10
+  max_age: 30
+tracing_config:
+  endpoint: "otel-collector:4317"
+  insecure: true
+  sampling_ratio: 0.1
+status_update_limit_config:
+  qps: 10
+  burst: 50
+  max_concurrency: 32
+status_coalescing_config:
+  window: 500ms
+leader_election_config:
+  namespace: "open-cluster-management-hub"
+  name: "cloudevents-conductor-lock"
+  lease_duration: 137s
+  renew_deadline: 107s
+  retry_period: 26s
+stream_ownership_config:
+  heartbeat_interval: 10s
+  expiration: 30s
+listener_config:
+  channel: "events"
+  min_reconnect_interval: 1s
+  max_reconnect_interval: 1m
 ```
 */
 type GRPCServerConfig struct {
+	// GRPCConfig is the options of the gRPC broker that is served by the conductor.
 	GRPCConfig *grpcserver.GRPCServerOptions `json:"grpc_config,omitempty" yaml:"grpc_config,omitempty"`
 	DBConfig   *dbconfig.DatabaseConfig      `json:"db_config,omitempty" yaml:"db_config,omitempty"`
+	// DBSecretRef resolves the database credentials from a Secret instead of the inline db_config, the Secret is
+	// watched and the database is reconnected when the credentials are rotated.
+	DBSecretRef          *DBSecretReference                `json:"db_secret_ref,omitempty" yaml:"db_secret_ref,omitempty"`
+	SpecControllerConfig *controller.SpecControllerOptions `json:"spec_controller_config,omitempty" yaml:"spec_controller_config,omitempty"`
+	// BrokerConfig selects the broker that the agents connect to. The gRPC broker is served by the conductor with
+	// the grpc_config, for the mqtt or kafka broker, the conductor connects to the broker as a source with the
+	// config file of the sdk-go and provisions the ACLs of the managed clusters in the broker (the conductor must
+	// be built with the kafka tag to support kafka).
+	BrokerConfig *mq.BrokerOptions `json:"broker_config,omitempty" yaml:"broker_config,omitempty"`
+	// AuditConfig enables the audit log of the manifest bundles that are routed by the conductor, the audit events
+	// are written as JSON lines to the path ("-" for stdout) and the file is rotated by its size.
+	AuditConfig *audit.Options `json:"audit_config,omitempty" yaml:"audit_config,omitempty"`
+	// TracingConfig exports the spans that follow the resource changes from the database to the agents and the
+	// status updates back to the database to an OTLP gRPC receiver.
+	TracingConfig *tracing.Options `json:"tracing_config,omitempty" yaml:"tracing_config,omitempty"`
+	// StatusUpdateLimitConfig limits the manifest bundle status updates that the agents publish to the gRPC broker
+	// with a token bucket for each cluster and a max number of the status updates that are handled at the same
+	// time, the exceeded status updates are rejected with a retriable ResourceExhausted error.
+	StatusUpdateLimitConfig *ratelimit.Options `json:"status_update_limit_config,omitempty" yaml:"status_update_limit_config,omitempty"`
+	// StatusCoalescingConfig writes the first status update of a maestro resource at once and coalesces the status
+	// updates in the window after it, only the latest status of the window is written to the database.
+	StatusCoalescingConfig *db.StatusCoalescingOptions `json:"status_coalescing_config,omitempty" yaml:"status_coalescing_config,omitempty"`
+	// LeaderElectionConfig elects a leader of the conductor instances with a Kubernetes Lease, only the leader runs
+	// the managed cluster controller and purges the reconciled events, all of the instances serve the agents and
+	// requeue and handle the database events. The leader election is enabled by default.
+	LeaderElectionConfig *LeaderElectionOptions `json:"leader_election_config,omitempty" yaml:"leader_election_config,omitempty"`
+	// StreamOwnershipConfig records the conductor instance that holds the manifest bundle stream of each cluster in
+	// the database, the database events of a cluster are only handled by the instance that holds its stream, the
+	// ownership is renewed every heartbeat_interval and is expired after the expiration. It is only supported by
+	// the gRPC broker.
+	StreamOwnershipConfig *streamowner.Options `json:"stream_ownership_config,omitempty" yaml:"stream_ownership_config,omitempty"`
+	// ListenerConfig sets the pg channel that the maestro notifies the database events on, the listener reconnects
+	// the database with a backoff between min_reconnect_interval and max_reconnect_interval when the connection is
+	// broken, and the unreconciled events are swept after each reconnect, so the events that are notified while
+	// the listener is disconnected are not left to the periodic events sync.
+	ListenerConfig *db.ListenerOptions `json:"listener_config,omitempty" yaml:"listener_config,omitempty"`
 }
 
-// loadGRPCServerConfig loads the gRPC server configuration from the specified file.
-func loadGRPCServerConfig(configPath string) (*GRPCServerConfig, error) {
+// LoadGRPCServerConfig loads the gRPC server configuration from the specified file, an error is returned if
+// the file has unknown fields. The loaded configuration is not validated, see GRPCServerConfig.Validate.
+func LoadGRPCServerConfig(configPath string) (*GRPCServerConfig, error) {
 	grpcServerConfigData, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, err
 	}
 
 	grpcServerConfig := &GRPCServerConfig{
-		GRPCConfig: grpcserver.NewGRPCServerOptions(),
-		DBConfig:   dbconfig.NewDatabaseConfig(),
+		GRPCConfig:           grpcserver.NewGRPCServerOptions(),
+		DBConfig:             dbconfig.NewDatabaseConfig(),
+		SpecControllerConfig: controller.NewSpecControllerOptions(),
+		BrokerConfig:         mq.NewBrokerOptions(),
+		LeaderElectionConfig: NewLeaderElectionOptions(),
+		ListenerConfig:       db.NewListenerOptions(),
 	}
-	if err := yaml.Unmarshal(grpcServerConfigData, grpcServerConfig); err != nil {
+	if err := yaml.UnmarshalStrict(grpcServerConfigData, grpcServerConfig); err != nil {
 		return nil, err
 	}
 
+	// the keys of the maestro database secret are used if no key is specified
+	if grpcServerConfig.DBSecretRef != nil && grpcServerConfig.DBSecretRef.Keys == (DBSecretKeys{}) {
+		grpcServerConfig.DBSecretRef.Keys = NewDBSecretKeys()
+	}
+
+	// the default max size of the audit log file is used if it is not set
+	if grpcServerConfig.AuditConfig != nil && grpcServerConfig.AuditConfig.MaxSize == 0 {
+		grpcServerConfig.AuditConfig.MaxSize = audit.NewOptions().MaxSize
+	}
+
+	// all of the traces are sampled if the sampling ratio is not set
+	if grpcServerConfig.TracingConfig != nil && grpcServerConfig.TracingConfig.SamplingRatio == 0 {
+		grpcServerConfig.TracingConfig.SamplingRatio = tracing.NewOptions().SamplingRatio
+	}
+
+	// the default limits are used for the limits that are not set
+	if limitConfig := grpcServerConfig.StatusUpdateLimitConfig; limitConfig != nil {
+		defaults := ratelimit.NewOptions()
+		if limitConfig.QPS == 0 {
+			limitConfig.QPS = defaults.QPS
+		}
+		if limitConfig.Burst == 0 {
+			limitConfig.Burst = defaults.Burst
+		}
+		if limitConfig.MaxConcurrency == 0 {
+			limitConfig.MaxConcurrency = defaults.MaxConcurrency
+		}
+	}
+
+	// the default window is used if it is not set
+	if grpcServerConfig.StatusCoalescingConfig != nil && grpcServerConfig.StatusCoalescingConfig.Window == 0 {
+		grpcServerConfig.StatusCoalescingConfig.Window = db.NewStatusCoalescingOptions().Window
+	}
+
+	// the default heartbeat interval and expiration are used if they are not set
+	if ownershipConfig := grpcServerConfig.StreamOwnershipConfig; ownershipConfig != nil {
+		defaults := streamowner.NewOptions()
+		if ownershipConfig.HeartbeatInterval == 0 {
+			ownershipConfig.HeartbeatInterval = defaults.HeartbeatInterval
+		}
+		if ownershipConfig.Expiration == 0 {
+			ownershipConfig.Expiration = defaults.Expiration
+		}
+	}
+
 	return grpcServerConfig, nil
 }
 
+// servesGRPC returns true if the agents connect to the gRPC broker that is served by the conductor.
+func (c *GRPCServerConfig) servesGRPC() bool {
+	return c.BrokerConfig == nil || c.BrokerConfig.Type == mq.BrokerGRPC
+}
+
+// newMessageQueueBroker creates the message queue broker with the MQTT or Kafka config file of the broker
+// options, the conductor connects to the broker as the source of the options.
+func newMessageQueueBroker(options *mq.BrokerOptions) (*broker.MessageQueueBroker, error) {
+	_, config, err := generic.NewConfigLoader(options.Type, options.ConfigFile).LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s config %s: %w", options.Type, options.ConfigFile, err)
+	}
+
+	// the client ID is unique for each conductor instance
+	clientID := fmt.Sprintf("%s-cloudevents-conductor-%s", options.SourceID, utilrand.String(5))
+	sourceOptions, err := generic.BuildCloudEventsSourceOptions(config, clientID, options.SourceID)
+	if err != nil {
+		return nil, err
+	}
+	return broker.NewMessageQueueBroker(sourceOptions), nil
+}
+
+// newInstanceID returns the ID of the conductor instance, it is unique for each run of the conductor, even if
+// the pod is restarted with the same name.
+func newInstanceID() (string, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("failed to get the hostname: %w", err)
+	}
+	return hostname + "_" + string(uuid.NewUUID()), nil
+}
+
+// defaultHealthProbeBindAddress is the default address that the HTTP health probes are served on.
+const defaultHealthProbeBindAddress = ":8000"
+
 type GRPCServerOptions struct {
-	GRPCServerConfigFile string
+	GRPCServerConfigFile        string
+	ResourceDeletionPolicy      string
+	ResourceDeletionGracePeriod time.Duration
+	HealthProbeBindAddress      string
 }
 
 func NewGRPCServerOptions() *GRPCServerOptions {
-	return &GRPCServerOptions{}
+	return &GRPCServerOptions{
+		ResourceDeletionPolicy:      string(controller.ResourceDeletionPolicyDelete),
+		ResourceDeletionGracePeriod: controller.DefaultResourceDeletionGracePeriod,
+		HealthProbeBindAddress:      defaultHealthProbeBindAddress,
+	}
 }
 
 func (o *GRPCServerOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.GRPCServerConfigFile, "server-config", o.GRPCServerConfigFile, "Location of the server configuration file.")
+	fs.StringVar(&o.ResourceDeletionPolicy, "resource-deletion-policy", o.ResourceDeletionPolicy,
+		"How the maestro resources of a removed managed cluster are handled, Delete or Orphan.")
+	fs.DurationVar(&o.ResourceDeletionGracePeriod, "resource-deletion-grace-period", o.ResourceDeletionGracePeriod,
+		"How long the agent of a removed managed cluster is waited to confirm the deletion of the maestro resources "+
+			"with the Delete policy, the resources are removed from the maestro directly after it.")
+	fs.StringVar(&o.HealthProbeBindAddress, "health-probe-bind-address", o.HealthProbeBindAddress,
+		"The address that the /readyz and /livez probes are served on, the probes are not served if it is empty.")
 }
 
 func (o *GRPCServerOptions) Run(ctx context.Context, controllerContext *controllercmd.ControllerContext) error {
+	// The conductor is stopped with the cause when the leader election is lost
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	resourceDeletionPolicy := controller.ResourceDeletionPolicy(o.ResourceDeletionPolicy)
+	if resourceDeletionPolicy != controller.ResourceDeletionPolicyDelete &&
+		resourceDeletionPolicy != controller.ResourceDeletionPolicyOrphan {
+		return fmt.Errorf("unsupported resource deletion policy %q", o.ResourceDeletionPolicy)
+	}
+	if o.ResourceDeletionGracePeriod < 0 {
+		return fmt.Errorf("the resource deletion grace period %s must not be negative", o.ResourceDeletionGracePeriod)
+	}
+
 	// Load the gRPC server configuration and database configuration
-	grpcServerConfig, err := loadGRPCServerConfig(o.GRPCServerConfigFile)
+	grpcServerConfig, err := LoadGRPCServerConfig(o.GRPCServerConfigFile)
 	if err != nil {
 		return fmt.Errorf("failed to load gRPC server config: %w", err)
 	}
+	if err := grpcServerConfig.Validate(); err != nil {
+		return fmt.Errorf("invalid gRPC server config: %w", err)
+	}
+
+	// Register the metrics of the conductor for every broker type, the gRPC server only registers its own metrics
+	registerMetrics()
+
+	// Export the spans to the OTLP receiver if the tracing is configured
+	if grpcServerConfig.TracingConfig != nil {
+		shutdown, err := tracing.Setup(ctx, grpcServerConfig.TracingConfig)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := shutdown(context.Background()); err != nil {
+				klog.Errorf("failed to shutdown tracing: %v", err)
+			}
+		}()
+	}
 
 	// Retrieve the gRPC server options and database configuration
 	serverOptions := grpcServerConfig.GRPCConfig
 	dbConfig := grpcServerConfig.DBConfig
 
-	// Create a session factory for the database connection
-	sessionFactory := db_session.NewProdFactory(dbConfig)
+	// Load the TLS material of the gRPC server, it is reloaded when the TLS files are changed
+	var certificates *servingCertificates
+	if grpcServerConfig.servesGRPC() {
+		certificates, err = newServingCertificates(serverOptions)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Resolve the database credentials from the database secret if it is referenced
+	kubeClient, err := kubernetes.NewForConfig(controllerContext.KubeConfig)
+	if err != nil {
+		return err
+	}
+	dbConfig, err = grpcServerConfig.ResolveDBConfig(ctx, kubeClient)
+	if err != nil {
+		return err
+	}
+
+	// Create a session factory for the database connection, it is rebuilt when the database config is changed
+	sessionFactory := db.NewReloadableSessionFactory(dbConfig, func(config *dbconfig.DatabaseConfig) maestrodb.SessionFactory {
+		return db_session.NewProdFactory(config)
+	})
 	defer func() {
 		// ensure the session factory is closed when the context is done
 		if err := sessionFactory.Close(); err != nil {
@@ -116,13 +365,52 @@ func (o *GRPCServerOptions) Run(ctx context.Context, controllerContext *controll
 	}()
 
 	// Initialize the database service and controller manager
-	dbService := db.NewDBWorkService(resource.NewResourceService(sessionFactory),
-		dbstatusevent.NewStatusEventService(sessionFactory))
+	resourceService := resource.NewResourceService(sessionFactory)
+	dbService := db.NewDBWorkService(resourceService, dbstatusevent.NewStatusEventService(sessionFactory))
+	if grpcServerConfig.StatusCoalescingConfig != nil {
+		dbService.WithStatusCoalescing(grpcServerConfig.StatusCoalescingConfig)
+	}
 	ctrMgr := controller.NewSpecControllerManager(maestrodb.NewAdvisoryLockFactory(sessionFactory),
-		dbevent.NewEventService(sessionFactory))
+		dbevent.NewEventService(sessionFactory), dbevent.NewReconciledEventsPurger(sessionFactory),
+		grpcServerConfig.SpecControllerConfig)
+
+	// Persist the events that exceed the max retries in the poisoned events table
+	poisonedEventStore := dbevent.NewPoisonedEventStore(sessionFactory)
+	if err := poisonedEventStore.Migrate(ctx); err != nil {
+		return err
+	}
+	ctrMgr.WithDeadLetter(poisonedEventStore, controllerContext.EventRecorder)
 
-	// Listen for db events and add them to the controller manager in a goroutine
-	go sessionFactory.NewListener(ctx, "events", ctrMgr.AddEvent)
+	// The conductor instance is identified by the leader election and the stream ownership
+	instanceID, err := newInstanceID()
+	if err != nil {
+		return err
+	}
+
+	// Handle the db events of a cluster only by the instance that holds the agent stream of the cluster
+	var streamOwners *streamowner.Registry
+	if grpcServerConfig.StreamOwnershipConfig != nil {
+		streamOwners = streamowner.NewRegistry(sessionFactory, instanceID, grpcServerConfig.StreamOwnershipConfig)
+		if err := streamOwners.Migrate(ctx); err != nil {
+			return err
+		}
+		defer func() {
+			// release the clusters before the session factory is closed
+			if err := streamOwners.Close(context.Background()); err != nil {
+				klog.Errorf("failed to release the stream owners: %v", err)
+			}
+		}()
+		ctrMgr.WithEventOwnership(streamOwners)
+		go streamOwners.Run(ctx)
+	}
+
+	listenerConfig := grpcServerConfig.ListenerConfig
+	if listenerConfig == nil {
+		listenerConfig = db.NewListenerOptions()
+	}
+	// Listen for db events and add them to the controller manager, the unreconciled events are swept after the
+	// listener is (re)connected to cover the events that are notified while it is disconnected
+	sessionFactory.Listen(ctx, listenerConfig, ctrMgr.AddEvent, ctrMgr.SweepUnreconciledEvents)
 
 	clients, err := ocmgrpcserver.NewClients(controllerContext)
 	if err != nil {
@@ -131,40 +419,158 @@ func (o *GRPCServerOptions) Run(ctx context.Context, controllerContext *controll
 
 	workService := work.NewWorkService(clients.WorkClient, clients.WorkInformers.Work().V1().ManifestWorks())
 
-	grpcEventServer := grpcceserver.NewGRPCBroker()
-	grpcEventServer.RegisterService(clusterce.ManagedClusterEventDataType,
+	// The services are served over the gRPC broker of the conductor, or the message queue broker that the
+	// conductor connects to as a source
+	grpcEventServer := broker.NewGRPCBroker()
+	var eventServer ceserver.AgentEventServer = grpcEventServer
+	var mqBroker *broker.MessageQueueBroker
+	if !grpcServerConfig.servesGRPC() {
+		mqBroker, err = newMessageQueueBroker(grpcServerConfig.BrokerConfig)
+		if err != nil {
+			return err
+		}
+		eventServer = mqBroker
+	}
+
+	eventServer.RegisterService(clusterce.ManagedClusterEventDataType,
 		cluster.NewClusterService(clients.ClusterClient, clients.ClusterInformers.Cluster().V1().ManagedClusters()))
-	grpcEventServer.RegisterService(csrce.CSREventDataType,
+	eventServer.RegisterService(csrce.CSREventDataType,
 		csr.NewCSRService(clients.KubeClient, clients.KubeInformers.Certificates().V1().CertificateSigningRequests()))
-	grpcEventServer.RegisterService(
+	eventServer.RegisterService(
 		addonce.ManagedClusterAddOnEventDataType,
 		addon.NewAddonService(clients.AddOnClient, clients.AddOnInformers.Addon().V1alpha1().ManagedClusterAddOns()))
-	grpcEventServer.RegisterService(eventce.EventEventDataType,
+	eventServer.RegisterService(eventce.EventEventDataType,
 		event.NewEventService(clients.KubeClient))
-	grpcEventServer.RegisterService(leasece.LeaseEventDataType,
+	eventServer.RegisterService(leasece.LeaseEventDataType,
 		lease.NewLeaseService(clients.KubeClient, clients.KubeInformers.Coordination().V1().Leases()))
-	grpcEventServer.RegisterService(payload.ManifestBundleEventDataType,
-		services.NewRouterService(dbService, ctrMgr, workService, clients.WorkInformers.Work().V1().ManifestWorks()))
+
+	// Register the manifest bundle backends to the router service, the resource IDs are routed to the
+	// backends by their source prefix
+	routerService := services.NewRouterService()
+	if grpcServerConfig.AuditConfig != nil {
+		auditLogger := audit.NewLogger(grpcServerConfig.AuditConfig)
+		defer func() {
+			if err := auditLogger.Close(); err != nil {
+				klog.Errorf("failed to close audit logger: %v", err)
+			}
+		}()
+		routerService.WithAuditLogger(auditLogger)
+	}
+	if err := routerService.Register(
+		services.NewKubeBackend(workService, clients.WorkInformers.Work().V1().ManifestWorks())); err != nil {
+		return err
+	}
+	if err := routerService.Register(services.NewDBBackend(dbService, ctrMgr)); err != nil {
+		return err
+	}
+	eventServer.RegisterService(payload.ManifestBundleEventDataType, routerService)
+
+	// Provision the ACLs of the managed clusters in the message queue broker that the agents connect to
+	mqAuthzCreator, err := mq.NewMessageQueueAuthzCreator(grpcServerConfig.BrokerConfig)
+	if err != nil {
+		return err
+	}
 
 	managedClusterController := controller.NewManagedClusterController(
+		clients.ClusterClient,
 		clients.ClusterInformers.Cluster().V1().ManagedClusters(),
 		controllerContext.EventRecorder,
-		mq.NewMessageQueueAuthzCreator(),
+		mqAuthzCreator,
 		consumer.NewConsumerService(sessionFactory),
+		resourceService,
+		resourceDeletionPolicy,
+		o.ResourceDeletionGracePeriod,
+	)
+
+	// Watch the config file, the TLS files and the database secret to reload them without restarting the gRPC server
+	reloader, err := newConfigReloader(o.GRPCServerConfigFile, grpcServerConfig, certificates, sessionFactory, kubeClient)
+	if err != nil {
+		return err
+	}
+	go reloader.Run(ctx)
+
+	// Check the health of the database, the events listener, the informers, the message queue broker and the
+	// spec controller, the readiness is also reported by the gRPC health service
+	readiness := []healthCheck{
+		{name: "database", check: sessionFactory.CheckConnectionContext},
+		{name: "events-listener", check: sessionFactory.PingListeners},
+		newInformersSyncedCheck(map[string]cache.InformerSynced{
+			"managedclusters":      clients.ClusterInformers.Cluster().V1().ManagedClusters().Informer().HasSynced,
+			"manifestworks":        clients.WorkInformers.Work().V1().ManifestWorks().Informer().HasSynced,
+			"managedclusteraddons": clients.AddOnInformers.Addon().V1alpha1().ManagedClusterAddOns().Informer().HasSynced,
+			"csrs":                 clients.KubeInformers.Certificates().V1().CertificateSigningRequests().Informer().HasSynced,
+			"leases":               clients.KubeInformers.Coordination().V1().Leases().Informer().HasSynced,
+		}),
+	}
+	if mqBroker != nil {
+		readiness = append(readiness, healthCheck{name: "message-queue-broker", check: func(ctx context.Context) error {
+			return mqBroker.CheckConnection()
+		}})
+	}
+	healthProbes := newHealthProbes(
+		readiness,
+		[]healthCheck{
+			{name: "spec-controller", check: func(ctx context.Context) error {
+				return ctrMgr.CheckProgress(specControllerStallTimeout)
+			}},
+		},
 	)
+	go func() {
+		if err := healthProbes.Run(ctx, o.HealthProbeBindAddress); err != nil {
+			klog.Errorf("failed to run health probes: %v", err)
+		}
+	}()
 
 	// TODO: start the controller as a prehook of grpc server
-	go managedClusterController.Run(ctx, 1)
 	go clients.Run(ctx)
 	go ctrMgr.Run(ctx)
 
+	// Only the leader reconciles the managed clusters and purges the reconciled events periodically, the events
+	// are still requeued and handled by all of the instances
+	leaderElectionConfig := grpcServerConfig.LeaderElectionConfig
+	if leaderElectionConfig == nil {
+		leaderElectionConfig = NewLeaderElectionOptions()
+	}
+	if err := runLeaderTasks(ctx, kubeClient, leaderElectionConfig, instanceID, controllerContext.OperatorNamespace,
+		func(ctx context.Context) {
+			go managedClusterController.Run(ctx, 1)
+			ctrMgr.RunEventsPurge(ctx)
+		},
+		func() { cancel(errLeaderElectionLost) },
+	); err != nil {
+		return err
+	}
+
+	if mqBroker != nil {
+		return leaderElectionLost(ctx, mqBroker.Run(ctx))
+	}
+
 	authorizer := grpcauthz.NewSARAuthorizer(clients.KubeClient)
-	return grpcserver.NewGRPCServer(serverOptions).
+	grpcServer := newServer(serverOptions, certificates).
 		WithAuthenticator(grpcauthn.NewTokenAuthenticator(clients.KubeClient)).
 		WithAuthenticator(grpcauthn.NewMtlsAuthenticator()).
 		WithUnaryAuthorizer(authorizer).
 		WithStreamAuthorizer(authorizer).
 		WithRegisterFunc(func(s *grpc.Server) {
 			pbv1.RegisterCloudEventServiceServer(s, grpcEventServer)
-		}).Run(ctx)
+			healthpb.RegisterHealthServer(s, healthProbes.grpcHealth)
+		})
+	// Limit the status updates of the clusters after they are authorized
+	if grpcServerConfig.StatusUpdateLimitConfig != nil {
+		grpcServer.WithUnaryInterceptor(ratelimit.NewLimiter(grpcServerConfig.StatusUpdateLimitConfig).UnaryServerInterceptor())
+	}
+	// Record the owner of the clusters after their streams are authorized
+	if streamOwners != nil {
+		grpcServer.WithStreamInterceptor(streamOwners.StreamServerInterceptor())
+	}
+	return leaderElectionLost(ctx, grpcServer.Run(ctx))
+}
+
+// leaderElectionLost returns the lost leader election as the error of the conductor if the server is stopped
+// by it, so the conductor is restarted instead of exiting successfully.
+func leaderElectionLost(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, errLeaderElectionLost) {
+		return cause
+	}
+	return err
 }
@@ -3,19 +3,28 @@ package grpc
 import (
 	"math"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	dbconfig "github.com/openshift-online/maestro/pkg/config"
 	"github.com/openshift-online/maestro/pkg/constants"
+	"github.com/stolostron/cloudevents-conductor/pkg/audit"
+	"github.com/stolostron/cloudevents-conductor/pkg/controller"
+	"github.com/stolostron/cloudevents-conductor/pkg/controller/mq"
+	"github.com/stolostron/cloudevents-conductor/pkg/ratelimit"
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db"
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db/streamowner"
+	"github.com/stolostron/cloudevents-conductor/pkg/tracing"
 	"github.com/stretchr/testify/assert"
 	grpcserver "open-cluster-management.io/sdk-go/pkg/server/grpc"
 )
 
-// Test loadGRPCServerConfig with valid config file
+// Test LoadGRPCServerConfig with valid config file
 func TestLoadGRPCServerConfig(t *testing.T) {
-	// table driven tests for loadGRPCServerConfig
+	// table driven tests for LoadGRPCServerConfig
 	cases := []struct {
 		name          string
 		configContent string
@@ -36,6 +45,10 @@ db_config:
   username: "bar"
   password: "goo"
   sslmode: "disable"
+spec_controller_config:
+  workers: 4
+  events_sync_period: 1h
+  reconciled_events_retention: 24h
 `,
 			expected: &GRPCServerConfig{
 				GRPCConfig: &grpcserver.GRPCServerOptions{
@@ -75,6 +88,12 @@ db_config:
 					Password:           "goo",
 					SSLMode:            "disable",
 				},
+				SpecControllerConfig: &controller.SpecControllerOptions{
+					Workers:                        4,
+					EventsSyncPeriod:               time.Hour,
+					ReconciledEventsRetention:      24 * time.Hour,
+					ReconciledEventsPurgeBatchSize: 1000,
+				},
 			},
 			expectError: false,
 		},
@@ -84,6 +103,15 @@ db_config:
 			expected:      nil,
 			expectError:   true,
 		},
+		{
+			name: "UnknownField",
+			configContent: `
+grpc_config:
+  serverBindPort: "8090"
+`,
+			expected:    nil,
+			expectError: true,
+		},
 		{
 			name:          "EmptyConfig",
 			configContent: ``,
@@ -123,6 +151,27 @@ db_config:
 			},
 			expectError: false,
 		},
+		{
+			name: "MissingSpecControllerConfig",
+			configContent: `
+db_config:
+  host: "localhost"
+`,
+			expected: &GRPCServerConfig{
+				GRPCConfig: grpcserver.NewGRPCServerOptions(),
+				DBConfig: func() *dbconfig.DatabaseConfig {
+					config := dbconfig.NewDatabaseConfig()
+					config.Host = "localhost"
+					return config
+				}(),
+				SpecControllerConfig: &controller.SpecControllerOptions{
+					Workers:                        1,
+					EventsSyncPeriod:               10 * time.Hour,
+					ReconciledEventsPurgeBatchSize: 1000,
+				},
+			},
+			expectError: false,
+		},
 		{
 			name: "MissingGRPCConfig",
 			configContent: `
@@ -235,7 +284,7 @@ grpc_config:
 			tmpFile.Close()
 
 			// Load the config
-			config, err := loadGRPCServerConfig(tmpFile.Name())
+			config, err := LoadGRPCServerConfig(tmpFile.Name())
 			if tc.expectError {
 				assert.NotNil(t, err, "Expected error but got none")
 				return
@@ -257,8 +306,234 @@ grpc_config:
 					if !reflect.DeepEqual(*tc.expected.DBConfig, *config.DBConfig) {
 						t.Errorf("Loaded DBConfig does not match expected:\nExpected: %+v\nGot: %+v", *tc.expected.DBConfig, *config.DBConfig)
 					}
+					if tc.expected.SpecControllerConfig != nil &&
+						!reflect.DeepEqual(*tc.expected.SpecControllerConfig, *config.SpecControllerConfig) {
+						t.Errorf("Loaded SpecControllerConfig does not match expected:\nExpected: %+v\nGot: %+v",
+							*tc.expected.SpecControllerConfig, *config.SpecControllerConfig)
+					}
 				}
 			}
 		})
 	}
 }
+
+func TestGRPCServerConfigValidate(t *testing.T) {
+	newConfig := func() *GRPCServerConfig {
+		dbConfig := dbconfig.NewDatabaseConfig()
+		dbConfig.Host = "localhost"
+		dbConfig.Port = 5432
+		dbConfig.Name = "foo"
+		dbConfig.Username = "bar"
+		return &GRPCServerConfig{
+			GRPCConfig:           grpcserver.NewGRPCServerOptions(),
+			DBConfig:             dbConfig,
+			SpecControllerConfig: controller.NewSpecControllerOptions(),
+			BrokerConfig:         mq.NewBrokerOptions(),
+			LeaderElectionConfig: NewLeaderElectionOptions(),
+		}
+	}
+
+	cases := []struct {
+		name           string
+		mutate         func(config *GRPCServerConfig)
+		expectedFields []string
+	}{
+		{
+			name:   "ValidConfig",
+			mutate: func(config *GRPCServerConfig) {},
+		},
+		{
+			name: "InvalidGRPCConfig",
+			mutate: func(config *GRPCServerConfig) {
+				config.GRPCConfig.ServerBindPort = "grpc"
+				config.GRPCConfig.TLSKeyFile = ""
+				config.GRPCConfig.TLSMinVersion = 770
+			},
+			expectedFields: []string{
+				"grpc_config.tls_key_file",
+				"grpc_config.tls_min_version",
+				"grpc_config.server_bind_port",
+			},
+		},
+		{
+			name: "InvalidDBConfig",
+			mutate: func(config *GRPCServerConfig) {
+				config.DBConfig.Host = ""
+				config.DBConfig.Port = 0
+				config.DBConfig.SSLMode = "on"
+			},
+			expectedFields: []string{
+				"db_config.host",
+				"db_config.port",
+				"db_config.sslmode",
+			},
+		},
+		{
+			name: "InvalidSpecControllerConfig",
+			mutate: func(config *GRPCServerConfig) {
+				config.SpecControllerConfig.Workers = 0
+				config.SpecControllerConfig.MaxRetries = -1
+			},
+			expectedFields: []string{
+				"spec_controller_config.workers",
+				"spec_controller_config.max_retries",
+			},
+		},
+		{
+			name: "InvalidBrokerConfig",
+			mutate: func(config *GRPCServerConfig) {
+				config.BrokerConfig.Type = mq.BrokerMQTT
+				config.BrokerConfig.SourceID = ""
+			},
+			expectedFields: []string{
+				"broker_config.config_file",
+				"broker_config.source_id",
+			},
+		},
+		{
+			name: "MQTTBrokerWithoutTLSFiles",
+			mutate: func(config *GRPCServerConfig) {
+				config.BrokerConfig.Type = mq.BrokerMQTT
+				config.BrokerConfig.ConfigFile = "/path/to/mqtt-config.yaml"
+				config.GRPCConfig.TLSCertFile = ""
+				config.GRPCConfig.TLSKeyFile = ""
+			},
+		},
+		{
+			name: "UnsupportedBroker",
+			mutate: func(config *GRPCServerConfig) {
+				config.BrokerConfig.Type = "amqp"
+			},
+			expectedFields: []string{
+				"broker_config.type",
+			},
+		},
+		{
+			name: "InvalidAuditConfig",
+			mutate: func(config *GRPCServerConfig) {
+				config.AuditConfig = audit.NewOptions()
+				config.AuditConfig.Path = ""
+				config.AuditConfig.MaxAge = -1
+			},
+			expectedFields: []string{
+				"audit_config.path",
+				"audit_config.max_age",
+			},
+		},
+		{
+			name: "InvalidTracingConfig",
+			mutate: func(config *GRPCServerConfig) {
+				config.TracingConfig = tracing.NewOptions()
+				config.TracingConfig.SamplingRatio = 1.5
+			},
+			expectedFields: []string{
+				"tracing_config.endpoint",
+				"tracing_config.sampling_ratio",
+			},
+		},
+		{
+			name: "InvalidStatusUpdateLimitConfig",
+			mutate: func(config *GRPCServerConfig) {
+				config.StatusUpdateLimitConfig = &ratelimit.Options{QPS: -1, Burst: 10, MaxConcurrency: -1}
+			},
+			expectedFields: []string{
+				"status_update_limit_config.qps",
+				"status_update_limit_config.max_concurrency",
+			},
+		},
+		{
+			name: "InvalidStatusCoalescingConfig",
+			mutate: func(config *GRPCServerConfig) {
+				config.StatusCoalescingConfig = &db.StatusCoalescingOptions{Window: -time.Second}
+			},
+			expectedFields: []string{
+				"status_coalescing_config.window",
+			},
+		},
+		{
+			name: "InvalidLeaderElectionConfig",
+			mutate: func(config *GRPCServerConfig) {
+				config.LeaderElectionConfig = &LeaderElectionOptions{
+					LeaseDuration: 10 * time.Second,
+					RenewDeadline: 15 * time.Second,
+					RetryPeriod:   20 * time.Second,
+				}
+			},
+			expectedFields: []string{
+				"leader_election_config.name",
+				"leader_election_config.lease_duration",
+				"leader_election_config.renew_deadline",
+			},
+		},
+		{
+			name: "InvalidStreamOwnershipConfig",
+			mutate: func(config *GRPCServerConfig) {
+				config.StreamOwnershipConfig = &streamowner.Options{HeartbeatInterval: 0, Expiration: 0}
+			},
+			expectedFields: []string{
+				"stream_ownership_config.heartbeat_interval",
+				"stream_ownership_config.expiration",
+			},
+		},
+		{
+			name: "StreamOwnershipWithMQTTBroker",
+			mutate: func(config *GRPCServerConfig) {
+				config.BrokerConfig.Type = mq.BrokerMQTT
+				config.BrokerConfig.ConfigFile = "/path/to/mqtt-config.yaml"
+				config.StreamOwnershipConfig = streamowner.NewOptions()
+			},
+			expectedFields: []string{
+				"stream_ownership_config",
+			},
+		},
+		{
+			name: "InvalidListenerConfig",
+			mutate: func(config *GRPCServerConfig) {
+				config.ListenerConfig = &db.ListenerOptions{
+					MinReconnectInterval: 10 * time.Second,
+					MaxReconnectInterval: time.Second,
+				}
+			},
+			expectedFields: []string{
+				"listener_config.channel",
+				"listener_config.max_reconnect_interval",
+			},
+		},
+		{
+			name: "DisabledLeaderElection",
+			mutate: func(config *GRPCServerConfig) {
+				config.LeaderElectionConfig = &LeaderElectionOptions{Disable: true}
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := newConfig()
+			tc.mutate(config)
+
+			err := config.Validate()
+			if len(tc.expectedFields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.Error(t, err)
+			for _, field := range tc.expectedFields {
+				assert.True(t, strings.Contains(err.Error(), field), "expected %s in %v", field, err)
+			}
+		})
+	}
+}
+
+func TestGRPCServerConfigProbeTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	config := &GRPCServerConfig{GRPCConfig: grpcserver.NewGRPCServerOptions()}
+	config.GRPCConfig.TLSCertFile = filepath.Join(dir, "tls.crt")
+	config.GRPCConfig.TLSKeyFile = filepath.Join(dir, "tls.key")
+	config.GRPCConfig.ClientCAFile = ""
+
+	assert.Error(t, config.ProbeTLSFiles())
+
+	writeServingCertificate(t, config.GRPCConfig, "server")
+	assert.NoError(t, config.ProbeTLSFiles())
+}
@@ -0,0 +1,162 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// healthCheckTimeout is the timeout of each health check.
+	healthCheckTimeout = 5 * time.Second
+
+	// grpcHealthUpdatePeriod is the period to update the serving status of the gRPC health service.
+	grpcHealthUpdatePeriod = 10 * time.Second
+
+	// specControllerStallTimeout is how long the spec controller can make no progress with waiting events
+	// before it is considered unhealthy.
+	specControllerStallTimeout = 5 * time.Minute
+)
+
+// healthCheck is a named check of a component of the conductor.
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// newInformersSyncedCheck checks the caches of the informers are synced.
+func newInformersSyncedCheck(informers map[string]cache.InformerSynced) healthCheck {
+	return healthCheck{
+		name: "informer-sync",
+		check: func(ctx context.Context) error {
+			notSynced := []string{}
+			for name, hasSynced := range informers {
+				if !hasSynced() {
+					notSynced = append(notSynced, name)
+				}
+			}
+			if len(notSynced) > 0 {
+				return fmt.Errorf("informers are not synced: %s", strings.Join(notSynced, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// healthProbes serves the readiness and liveness of the conductor over HTTP and with the standard gRPC
+// health service. The gRPC health service reports the readiness, since a gRPC client only needs to know
+// whether it can be served.
+type healthProbes struct {
+	readiness  []healthCheck
+	liveness   []healthCheck
+	grpcHealth *health.Server
+}
+
+func newHealthProbes(readiness, liveness []healthCheck) *healthProbes {
+	grpcHealth := health.NewServer()
+	grpcHealth.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	return &healthProbes{
+		readiness:  readiness,
+		liveness:   liveness,
+		grpcHealth: grpcHealth,
+	}
+}
+
+// Run serves the HTTP probes on the bind address and updates the gRPC health service until the context is
+// done, the HTTP probes are not served if the bind address is empty.
+func (p *healthProbes) Run(ctx context.Context, bindAddress string) error {
+	go wait.UntilWithContext(ctx, p.updateGRPCHealth, grpcHealthUpdatePeriod)
+
+	if len(bindAddress) == 0 {
+		<-ctx.Done()
+		p.grpcHealth.Shutdown()
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/readyz", p.handler(p.readiness))
+	mux.Handle("/livez", p.handler(p.liveness))
+	server := &http.Server{
+		Addr:              bindAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: healthCheckTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		p.grpcHealth.Shutdown()
+		if err := server.Close(); err != nil {
+			klog.Errorf("failed to close health probe server: %v", err)
+		}
+	}()
+
+	klog.FromContext(ctx).Info("Starting health probe server", "addr", bindAddress)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("failed to serve health probes: %w", err)
+	}
+	return nil
+}
+
+func (p *healthProbes) updateGRPCHealth(ctx context.Context) {
+	status := healthpb.HealthCheckResponse_SERVING
+	if failed := runHealthChecks(ctx, p.readiness); len(failed) > 0 {
+		klog.FromContext(ctx).Info("The conductor is not ready", "failed", failed)
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	p.grpcHealth.SetServingStatus("", status)
+}
+
+// handler responds 200 if all of the checks pass, otherwise 503, the result of each check is listed in the
+// response body in the same format as the kube-apiserver health endpoints.
+func (p *healthProbes) handler(checks []healthCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failed := runHealthChecks(r.Context(), checks)
+
+		body := &strings.Builder{}
+		for _, c := range checks {
+			if err, ok := failed[c.name]; ok {
+				fmt.Fprintf(body, "[-]%s failed: %v\n", c.name, err)
+				continue
+			}
+			fmt.Fprintf(body, "[+]%s ok\n", c.name)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if len(failed) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(body, "health check failed\n")
+		} else {
+			fmt.Fprint(body, "ok\n")
+		}
+		_, _ = w.Write([]byte(body.String()))
+	})
+}
+
+// runHealthChecks runs the checks and returns the errors of the failed checks by their names.
+func runHealthChecks(ctx context.Context, checks []healthCheck) map[string]error {
+	failed := map[string]error{}
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		if err := c.check(checkCtx); err != nil {
+			failed[c.name] = err
+		}
+		cancel()
+	}
+	return failed
+}
+
+// isHealthMethod returns true if the method is of the gRPC health service, which is served without the
+// authentication and authorization, so it can be probed by the load balancers.
+func isHealthMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
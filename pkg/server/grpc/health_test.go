@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestHealthProbes(t *testing.T) {
+	dbErr := fmt.Errorf("connection refused")
+	synced := false
+	probes := newHealthProbes(
+		[]healthCheck{
+			{name: "database", check: func(ctx context.Context) error { return dbErr }},
+			newInformersSyncedCheck(map[string]cache.InformerSynced{
+				"managedclusters": func() bool { return synced },
+			}),
+		},
+		[]healthCheck{
+			{name: "spec-controller", check: func(ctx context.Context) error { return nil }},
+		},
+	)
+
+	probe := func(checks []healthCheck) (int, string) {
+		recorder := httptest.NewRecorder()
+		probes.handler(checks).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		return recorder.Code, recorder.Body.String()
+	}
+	grpcStatus := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := probes.grpcHealth.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		assert.NoError(t, err)
+		return resp.Status
+	}
+
+	code, body := probe(probes.readiness)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Contains(t, body, "[-]database failed: connection refused")
+	assert.Contains(t, body, "[-]informer-sync failed: informers are not synced: managedclusters")
+	probes.updateGRPCHealth(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, grpcStatus())
+
+	code, body = probe(probes.liveness)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "[+]spec-controller ok")
+
+	dbErr = nil
+	synced = true
+	code, body = probe(probes.readiness)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "[+]database ok")
+	assert.Contains(t, body, "[+]informer-sync ok")
+	probes.updateGRPCHealth(context.Background())
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, grpcStatus())
+}
+
+func TestIsHealthMethod(t *testing.T) {
+	assert.True(t, isHealthMethod("/grpc.health.v1.Health/Check"))
+	assert.True(t, isHealthMethod("/grpc.health.v1.Health/Watch"))
+	assert.False(t, isHealthMethod("/io.cloudevents.v1.CloudEventService/Publish"))
+}
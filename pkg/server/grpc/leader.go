@@ -0,0 +1,138 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+// errLeaderElectionLost is the cause of cancelling the conductor when the leader cannot renew its lease, the
+// conductor is restarted to run for the leader again.
+var errLeaderElectionLost = errors.New("the leader election is lost")
+
+// LeaderElectionOptions defines the leader election of the conductor instances with a Kubernetes Lease. The
+// leader runs the managed cluster controller and purges the reconciled events, all of the instances serve the
+// agents and requeue and handle the database events.
+type LeaderElectionOptions struct {
+	// Disable runs the leader tasks on every instance, it is only expected for a single instance.
+	Disable bool `json:"disable,omitempty" yaml:"disable,omitempty"`
+
+	// Namespace is the namespace of the Lease, the namespace of the conductor is used if it is empty.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+
+	// Name is the name of the Lease.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// LeaseDuration is how long the other instances wait before they take over the leadership after the
+	// leader stops renewing the Lease.
+	LeaseDuration time.Duration `json:"lease_duration,omitempty" yaml:"lease_duration,omitempty"`
+
+	// RenewDeadline is how long the leader retries to renew the Lease before it gives up the leadership.
+	RenewDeadline time.Duration `json:"renew_deadline,omitempty" yaml:"renew_deadline,omitempty"`
+
+	// RetryPeriod is how long the instances wait between the attempts to acquire or renew the Lease.
+	RetryPeriod time.Duration `json:"retry_period,omitempty" yaml:"retry_period,omitempty"`
+}
+
+// NewLeaderElectionOptions returns the default leader election options, the durations are the same as the
+// defaults of the openshift controllers, which tolerate a kube-apiserver outage of about 1 minute.
+func NewLeaderElectionOptions() *LeaderElectionOptions {
+	return &LeaderElectionOptions{
+		Name:          "cloudevents-conductor-lock",
+		LeaseDuration: 137 * time.Second,
+		RenewDeadline: 107 * time.Second,
+		RetryPeriod:   26 * time.Second,
+	}
+}
+
+// Validate validates the options, the errors are reported with the given field path.
+func (o *LeaderElectionOptions) Validate(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if o.Disable {
+		return errs
+	}
+
+	if o.Name == "" {
+		errs = append(errs, field.Required(fldPath.Child("name"), ""))
+	}
+	if o.LeaseDuration <= o.RenewDeadline {
+		errs = append(errs, field.Invalid(fldPath.Child("lease_duration"), o.LeaseDuration.String(),
+			fmt.Sprintf("must be greater than renew_deadline (%s)", o.RenewDeadline)))
+	}
+	if o.RenewDeadline <= time.Duration(leaderelection.JitterFactor*float64(o.RetryPeriod)) {
+		errs = append(errs, field.Invalid(fldPath.Child("renew_deadline"), o.RenewDeadline.String(),
+			fmt.Sprintf("must be greater than %.1f times retry_period (%s)", leaderelection.JitterFactor, o.RetryPeriod)))
+	}
+	if o.RetryPeriod <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("retry_period"), o.RetryPeriod.String(),
+			"must be greater than 0"))
+	}
+	return errs
+}
+
+// runLeaderTasks runs the tasks after the conductor instance of the identity is elected as the leader, the tasks
+// are stopped when the context is done. The lost callback is called when the leader cannot renew the Lease, so the tasks are not
+// run by two instances at the same time. The tasks are run at once if the leader election is disabled.
+func runLeaderTasks(ctx context.Context, kubeClient kubernetes.Interface, options *LeaderElectionOptions,
+	identity, defaultNamespace string, tasks func(ctx context.Context), lost func()) error {
+	if options.Disable {
+		klog.Infof("Leader election is disabled, running the leader tasks")
+		go tasks(ctx)
+		return nil
+	}
+
+	namespace := options.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	if namespace == "" {
+		return fmt.Errorf("the namespace of the leader election lease is required")
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Namespace: namespace, Name: options.Name},
+			Client:     kubeClient.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		LeaseDuration: options.LeaseDuration,
+		RenewDeadline: options.RenewDeadline,
+		RetryPeriod:   options.RetryPeriod,
+		// the next leader is elected without waiting for the lease to expire when the conductor is stopped
+		ReleaseOnCancel: true,
+		Name:            options.Name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Infof("%s is elected as the leader of %s/%s, running the leader tasks", identity, namespace, options.Name)
+				tasks(ctx)
+			},
+			OnStoppedLeading: func() {
+				// the leader election is also stopped when the conductor is stopped
+				if ctx.Err() != nil {
+					return
+				}
+				klog.Errorf("%s is no longer the leader of %s/%s", identity, namespace, options.Name)
+				lost()
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					klog.Infof("%s is the leader of %s/%s", leader, namespace, options.Name)
+				}
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create the leader elector: %w", err)
+	}
+
+	go elector.Run(ctx)
+	return nil
+}
@@ -0,0 +1,69 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunLeaderTasks(t *testing.T) {
+	cases := []struct {
+		name          string
+		options       *LeaderElectionOptions
+		namespace     string
+		expectedLease bool
+		expectError   bool
+	}{
+		{
+			name: "elected",
+			options: &LeaderElectionOptions{
+				Name:          "conductor-lock",
+				LeaseDuration: 3 * time.Second,
+				RenewDeadline: 2 * time.Second,
+				RetryPeriod:   500 * time.Millisecond,
+			},
+			namespace:     "open-cluster-management-hub",
+			expectedLease: true,
+		},
+		{
+			name:    "disabled",
+			options: &LeaderElectionOptions{Disable: true},
+		},
+		{
+			name:        "no namespace",
+			options:     NewLeaderElectionOptions(),
+			expectError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			kubeClient := kubefake.NewSimpleClientset()
+			started := make(chan struct{})
+			err := runLeaderTasks(ctx, kubeClient, c.options, "conductor-0_"+c.name, c.namespace,
+				func(ctx context.Context) { close(started) },
+				func() { t.Errorf("unexpected lost leader election") })
+			if c.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			select {
+			case <-started:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("the leader tasks are not started")
+			}
+
+			_, err = kubeClient.CoordinationV1().Leases(c.namespace).Get(ctx, c.options.Name, metav1.GetOptions{})
+			assert.Equal(t, c.expectedLease, err == nil)
+		})
+	}
+}
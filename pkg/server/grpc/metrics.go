@@ -0,0 +1,36 @@
+package grpc
+
+import (
+	"slices"
+	"sync"
+
+	k8smetrics "k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+
+	"github.com/stolostron/cloudevents-conductor/pkg/controller"
+	"github.com/stolostron/cloudevents-conductor/pkg/ratelimit"
+	"github.com/stolostron/cloudevents-conductor/pkg/services"
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db/streamowner"
+)
+
+var registerMetricsOnce sync.Once
+
+// conductorMetrics returns the metrics of the spec controller, the router, the status update limiter and the
+// stream ownership.
+func conductorMetrics() []k8smetrics.Registerable {
+	return slices.Concat(
+		controller.SpecControllerMetrics(),
+		services.RouterMetrics(),
+		ratelimit.LimiterMetrics(),
+		streamowner.StreamOwnershipMetrics(),
+	)
+}
+
+// registerMetrics registers the conductor metrics to the legacy registry, which is served on the metrics endpoint
+// of the controller command. It does not depend on the gRPC server, so the metrics are also served when the agents
+// connect to a message queue broker.
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(conductorMetrics()...)
+	})
+}
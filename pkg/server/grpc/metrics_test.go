@@ -0,0 +1,33 @@
+package grpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+func TestRegisterMetrics(t *testing.T) {
+	// the metrics are registered without running the gRPC server, as the conductor does for the mqtt and kafka
+	// brokers, and they are only registered once
+	registerMetrics()
+	assert.NotPanics(t, registerMetrics)
+
+	for _, m := range conductorMetrics() {
+		assert.Error(t, legacyregistry.Register(m), "the metric %s is not registered", m.FQName())
+	}
+
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	gathered := map[string]bool{}
+	for _, family := range families {
+		gathered[family.GetName()] = true
+	}
+	for _, name := range []string{
+		"spec_controller_queue_depth",
+		"status_update_limiter_in_flight",
+		"stream_ownership_owned_clusters",
+	} {
+		assert.True(t, gathered[name], "the metric %s is not gathered", name)
+	}
+}
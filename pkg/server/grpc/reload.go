@@ -0,0 +1,190 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	grpcserver "open-cluster-management.io/sdk-go/pkg/server/grpc"
+
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db"
+)
+
+// configReloader reloads the gRPC server config when the config file, the TLS files or the database Secret
+// are changed. The TLS material is reloaded in place and the database session factory is rebuilt on the
+// database config change, the other settings are applied after the conductor is restarted.
+type configReloader struct {
+	sync.Mutex
+	configFile     string
+	config         *GRPCServerConfig
+	certificates   *servingCertificates
+	sessionFactory *db.ReloadableSessionFactory
+	kubeClient     kubernetes.Interface
+	watcher        *fileWatcher
+	// secretInformer watches the Secret of the db_secret_ref, it is nil if the db_secret_ref is not set
+	secretInformer cache.SharedIndexInformer
+	secretChanged  chan struct{}
+}
+
+func newConfigReloader(configFile string, config *GRPCServerConfig, certificates *servingCertificates,
+	sessionFactory *db.ReloadableSessionFactory, kubeClient kubernetes.Interface) (*configReloader, error) {
+	r := &configReloader{
+		configFile:     configFile,
+		config:         config,
+		certificates:   certificates,
+		sessionFactory: sessionFactory,
+		kubeClient:     kubeClient,
+		secretChanged:  make(chan struct{}, 1),
+	}
+
+	watcher, err := newFileWatcher(r.reload, watchedFiles(configFile, config.GRPCConfig)...)
+	if err != nil {
+		return nil, err
+	}
+	r.watcher = watcher
+
+	if config.DBSecretRef != nil {
+		informer, err := newDBSecretInformer(kubeClient, config.DBSecretRef, func() {
+			select {
+			case r.secretChanged <- struct{}{}:
+			default:
+				// a reload is already pending
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		r.secretInformer = informer
+	}
+	return r, nil
+}
+
+// Run watches the files and the database Secret until the context is done.
+func (r *configReloader) Run(ctx context.Context) {
+	if r.secretInformer != nil {
+		go r.secretInformer.Run(ctx.Done())
+		go r.watchDBSecret(ctx)
+	}
+	r.watcher.Run(ctx)
+}
+
+// watchDBSecret rebuilds the session factory with the rotated credentials when the database Secret is changed.
+// The lock is held for the whole reload, so a reload of the config file does not interleave with it and the
+// session factory is not rebuilt with a stale config after a newer one.
+func (r *configReloader) watchDBSecret(ctx context.Context) {
+	logger := klog.FromContext(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.secretChanged:
+			r.Lock()
+			if err := r.reloadDBConfig(ctx, r.config); err != nil {
+				logger.Error(err, "failed to reload the changed database secret")
+			}
+			r.Unlock()
+		}
+	}
+}
+
+func (r *configReloader) reloadDBConfig(ctx context.Context, config *GRPCServerConfig) error {
+	dbConfig, err := config.ResolveDBConfig(ctx, r.kubeClient)
+	if err != nil {
+		return err
+	}
+	_, err = r.sessionFactory.Reload(ctx, dbConfig)
+	return err
+}
+
+func (r *configReloader) reload(ctx context.Context) error {
+	logger := klog.FromContext(ctx)
+	r.Lock()
+	defer r.Unlock()
+
+	config, err := LoadGRPCServerConfig(r.configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load gRPC server config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid gRPC server config: %w", err)
+	}
+
+	// the database secret is watched by the reference of the startup
+	if !reflect.DeepEqual(r.config.DBSecretRef, config.DBSecretRef) {
+		logger.Info("The database secret reference is changed, the change takes effect after the conductor is restarted")
+		config.DBSecretRef = r.config.DBSecretRef
+	}
+
+	errs := []error{}
+	// the certificates are nil if the gRPC server is not served
+	if r.certificates != nil {
+		if err := r.certificates.Reload(config.GRPCConfig); err != nil {
+			errs = append(errs, err)
+		} else {
+			logger.Info("TLS material of the gRPC server is reloaded")
+		}
+	}
+
+	if err := r.reloadDBConfig(ctx, config); err != nil {
+		errs = append(errs, err)
+	}
+
+	// the TLS files may be moved to other paths
+	if err := r.watcher.Watch(watchedFiles(r.configFile, config.GRPCConfig)...); err != nil {
+		errs = append(errs, err)
+	}
+
+	if changed := restartRequiredChanges(r.config, config); len(changed) > 0 {
+		logger.Info("The configs are changed, the changes take effect after the conductor is restarted",
+			"configs", changed)
+	}
+	r.config = config
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// restartRequiredChanges returns the names of the configs that are changed from the old config to the updated
+// config and are only applied after the conductor is restarted.
+func restartRequiredChanges(old, updated *GRPCServerConfig) []string {
+	configs := []struct {
+		name         string
+		old, updated any
+	}{
+		{"grpc_config", withoutTLSFiles(old.GRPCConfig), withoutTLSFiles(updated.GRPCConfig)},
+		{"spec_controller_config", old.SpecControllerConfig, updated.SpecControllerConfig},
+		{"broker_config", old.BrokerConfig, updated.BrokerConfig},
+		{"audit_config", old.AuditConfig, updated.AuditConfig},
+		{"tracing_config", old.TracingConfig, updated.TracingConfig},
+		{"status_update_limit_config", old.StatusUpdateLimitConfig, updated.StatusUpdateLimitConfig},
+		{"status_coalescing_config", old.StatusCoalescingConfig, updated.StatusCoalescingConfig},
+		{"leader_election_config", old.LeaderElectionConfig, updated.LeaderElectionConfig},
+		{"stream_ownership_config", old.StreamOwnershipConfig, updated.StreamOwnershipConfig},
+		{"listener_config", old.ListenerConfig, updated.ListenerConfig},
+	}
+
+	changed := []string{}
+	for _, c := range configs {
+		if !reflect.DeepEqual(c.old, c.updated) {
+			changed = append(changed, c.name)
+		}
+	}
+	return changed
+}
+
+// watchedFiles returns the config file and the TLS files that are referenced by the gRPC server options.
+func watchedFiles(configFile string, options *grpcserver.GRPCServerOptions) []string {
+	return []string{configFile, options.TLSCertFile, options.TLSKeyFile, options.ClientCAFile}
+}
+
+func withoutTLSFiles(options *grpcserver.GRPCServerOptions) grpcserver.GRPCServerOptions {
+	copied := *options
+	copied.TLSCertFile = ""
+	copied.TLSKeyFile = ""
+	copied.ClientCAFile = ""
+	return copied
+}
@@ -0,0 +1,163 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	grpcserver "open-cluster-management.io/sdk-go/pkg/server/grpc"
+
+	"github.com/stolostron/cloudevents-conductor/pkg/controller"
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db"
+)
+
+func TestServingCertificatesReload(t *testing.T) {
+	dir := t.TempDir()
+	options := grpcserver.NewGRPCServerOptions()
+	options.TLSCertFile = filepath.Join(dir, "tls.crt")
+	options.TLSKeyFile = filepath.Join(dir, "tls.key")
+	options.ClientCAFile = ""
+
+	writeServingCertificate(t, options, "server-1")
+	certificates, err := newServingCertificates(options)
+	assert.NoError(t, err)
+	assert.Equal(t, "server-1", servingCommonName(t, certificates))
+
+	// the rotated certificate is used by the new handshakes
+	writeServingCertificate(t, options, "server-2")
+	assert.NoError(t, certificates.Reload(options))
+	assert.Equal(t, "server-2", servingCommonName(t, certificates))
+
+	// the current certificate is kept if the files are broken
+	assert.NoError(t, os.WriteFile(options.TLSKeyFile, []byte("broken"), 0600))
+	assert.Error(t, certificates.Reload(options))
+	assert.Equal(t, "server-2", servingCommonName(t, certificates))
+
+	// the client CA is required if it is configured
+	options.ClientCAFile = filepath.Join(dir, "ca.crt")
+	writeServingCertificate(t, options, "server-3")
+	assert.Error(t, certificates.Reload(options))
+	assert.Equal(t, "server-2", servingCommonName(t, certificates))
+}
+
+func TestFileWatcher(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(t *testing.T, dir string)
+		reload bool
+	}{
+		{
+			name: "watched file is written",
+			change: func(t *testing.T, dir string) {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("changed"), 0600))
+			},
+			reload: true,
+		},
+		{
+			name: "data directory symlink is swapped",
+			change: func(t *testing.T, dir string) {
+				assert.NoError(t, os.Symlink(dir, filepath.Join(dir, "..data_tmp")))
+				assert.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+			},
+			reload: true,
+		},
+		{
+			name: "unwatched file is written",
+			change: func(t *testing.T, dir string) {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("changed"), 0600))
+			},
+			reload: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configFile := filepath.Join(dir, "config.yaml")
+			assert.NoError(t, os.WriteFile(configFile, []byte("initial"), 0600))
+
+			var reloaded atomic.Int32
+			watcher, err := newFileWatcher(func(ctx context.Context) error {
+				reloaded.Add(1)
+				return nil
+			}, configFile, "")
+			assert.NoError(t, err)
+			watcher.reloadDelay = 10 * time.Millisecond
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go watcher.Run(ctx)
+
+			c.change(t, dir)
+			if c.reload {
+				assert.Eventually(t, func() bool { return reloaded.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+				return
+			}
+			assert.Never(t, func() bool { return reloaded.Load() > 0 }, 200*time.Millisecond, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestRestartRequiredChanges(t *testing.T) {
+	newConfig := func() *GRPCServerConfig {
+		return &GRPCServerConfig{
+			GRPCConfig:           grpcserver.NewGRPCServerOptions(),
+			SpecControllerConfig: controller.NewSpecControllerOptions(),
+			ListenerConfig:       db.NewListenerOptions(),
+		}
+	}
+
+	// the TLS files are reloaded without a restart
+	updated := newConfig()
+	updated.GRPCConfig.TLSCertFile = "/path/to/rotated/tls.crt"
+	assert.Empty(t, restartRequiredChanges(newConfig(), updated))
+
+	updated.GRPCConfig.ServerBindPort = "9090"
+	updated.ListenerConfig.Channel = "conductor_events"
+	updated.StatusCoalescingConfig = db.NewStatusCoalescingOptions()
+	assert.Equal(t, []string{"grpc_config", "status_coalescing_config", "listener_config"},
+		restartRequiredChanges(newConfig(), updated))
+}
+
+func writeServingCertificate(t *testing.T, options *grpcserver.GRPCServerOptions, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(options.TLSCertFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0600))
+	assert.NoError(t, os.WriteFile(options.TLSKeyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+}
+
+func servingCommonName(t *testing.T, certificates *servingCertificates) string {
+	config, err := certificates.TLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	assert.Len(t, config.Certificates, 1)
+
+	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	assert.NoError(t, err)
+	return cert.Subject.CommonName
+}
@@ -0,0 +1,127 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	dbconfig "github.com/openshift-online/maestro/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DBSecretReference references a Secret that holds the database credentials. The values of the keys that are
+// present in the Secret override the ones of the db_config, the keys are mapped to the keys of the maestro
+// database Secret by default.
+type DBSecretReference struct {
+	Namespace string       `json:"namespace" yaml:"namespace"`
+	Name      string       `json:"name" yaml:"name"`
+	Keys      DBSecretKeys `json:"keys,omitempty" yaml:"keys,omitempty"`
+}
+
+// DBSecretKeys defines the keys of the database credentials in the Secret, an empty key is not resolved from
+// the Secret.
+type DBSecretKeys struct {
+	Host     string `json:"host,omitempty" yaml:"host,omitempty"`
+	Port     string `json:"port,omitempty" yaml:"port,omitempty"`
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+// NewDBSecretKeys returns the keys of the maestro database Secret.
+func NewDBSecretKeys() DBSecretKeys {
+	return DBSecretKeys{
+		Host:     "host",
+		Port:     "port",
+		Name:     "name",
+		Username: "user",
+		Password: "password",
+	}
+}
+
+// ResolveDBConfig returns the database config that the values of the Secret referenced by the db_secret_ref
+// are applied to, the db_config is returned if the db_secret_ref is not set. The kube client is only
+// required if the db_secret_ref is set.
+func (c *GRPCServerConfig) ResolveDBConfig(ctx context.Context,
+	kubeClient kubernetes.Interface) (*dbconfig.DatabaseConfig, error) {
+	secretRef := c.DBSecretRef
+	if secretRef == nil {
+		return c.DBConfig, nil
+	}
+
+	secret, err := kubeClient.CoreV1().Secrets(secretRef.Namespace).Get(ctx, secretRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database secret %s/%s: %w", secretRef.Namespace, secretRef.Name, err)
+	}
+	return applyDBSecret(c.DBConfig, secretRef, secret)
+}
+
+func applyDBSecret(config *dbconfig.DatabaseConfig, secretRef *DBSecretReference,
+	secret *corev1.Secret) (*dbconfig.DatabaseConfig, error) {
+	resolved := *config
+	for _, item := range []struct {
+		key   string
+		value *string
+	}{
+		{key: secretRef.Keys.Host, value: &resolved.Host},
+		{key: secretRef.Keys.Name, value: &resolved.Name},
+		{key: secretRef.Keys.Username, value: &resolved.Username},
+		{key: secretRef.Keys.Password, value: &resolved.Password},
+	} {
+		if data, ok := secret.Data[item.key]; ok && item.key != "" {
+			*item.value = string(data)
+		}
+	}
+
+	if data, ok := secret.Data[secretRef.Keys.Port]; ok && secretRef.Keys.Port != "" {
+		port, err := strconv.Atoi(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q in database secret %s/%s: %w",
+				string(data), secretRef.Namespace, secretRef.Name, err)
+		}
+		resolved.Port = port
+	}
+	return &resolved, nil
+}
+
+// newDBSecretInformer creates an informer that only watches the referenced Secret, the onChange is called
+// when the Secret is changed.
+func newDBSecretInformer(kubeClient kubernetes.Interface, secretRef *DBSecretReference,
+	onChange func()) (cache.SharedIndexInformer, error) {
+	informer := informers.NewSharedInformerFactoryWithOptions(kubeClient, 0,
+		informers.WithNamespace(secretRef.Namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", secretRef.Name).String()
+		}),
+	).Core().V1().Secrets().Informer()
+
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		// the Secret may be recreated
+		AddFunc: func(obj interface{}) {
+			onChange()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldSecret, ok := oldObj.(*corev1.Secret)
+			if !ok {
+				return
+			}
+			newSecret, ok := newObj.(*corev1.Secret)
+			if !ok {
+				return
+			}
+			// ignore the resync
+			if oldSecret.ResourceVersion == newSecret.ResourceVersion {
+				return
+			}
+			onChange()
+		},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to watch database secret %s/%s: %w", secretRef.Namespace, secretRef.Name, err)
+	}
+	return informer, nil
+}
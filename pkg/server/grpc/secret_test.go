@@ -0,0 +1,124 @@
+package grpc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	dbconfig "github.com/openshift-online/maestro/pkg/config"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResolveDBConfig(t *testing.T) {
+	newDBConfig := func() *dbconfig.DatabaseConfig {
+		config := dbconfig.NewDatabaseConfig()
+		config.Host = "localhost"
+		config.Port = 5432
+		config.Name = "foo"
+		config.Username = "bar"
+		config.Password = "goo"
+		return config
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "maestro", Name: "maestro-db-config"},
+		Data: map[string][]byte{
+			"host":     []byte("maestro-db.maestro"),
+			"port":     []byte("5433"),
+			"password": []byte("rotated"),
+		},
+	}
+
+	cases := []struct {
+		name      string
+		secretRef *DBSecretReference
+		objects   []runtime.Object
+		validate  func(t *testing.T, config *dbconfig.DatabaseConfig)
+		expectErr bool
+	}{
+		{
+			name: "no secret reference",
+			validate: func(t *testing.T, config *dbconfig.DatabaseConfig) {
+				assert.Equal(t, newDBConfig(), config)
+			},
+		},
+		{
+			name:      "secret values override the db config",
+			secretRef: &DBSecretReference{Namespace: "maestro", Name: "maestro-db-config", Keys: NewDBSecretKeys()},
+			objects:   []runtime.Object{secret},
+			validate: func(t *testing.T, config *dbconfig.DatabaseConfig) {
+				assert.Equal(t, "maestro-db.maestro", config.Host)
+				assert.Equal(t, 5433, config.Port)
+				assert.Equal(t, "rotated", config.Password)
+				// the values that are absent in the secret are kept
+				assert.Equal(t, "foo", config.Name)
+				assert.Equal(t, "bar", config.Username)
+			},
+		},
+		{
+			name:      "empty keys are not resolved",
+			secretRef: &DBSecretReference{Namespace: "maestro", Name: "maestro-db-config", Keys: DBSecretKeys{Password: "password"}},
+			objects:   []runtime.Object{secret},
+			validate: func(t *testing.T, config *dbconfig.DatabaseConfig) {
+				assert.Equal(t, "localhost", config.Host)
+				assert.Equal(t, 5432, config.Port)
+				assert.Equal(t, "rotated", config.Password)
+			},
+		},
+		{
+			name:      "invalid port",
+			secretRef: &DBSecretReference{Namespace: "maestro", Name: "maestro-db-config", Keys: NewDBSecretKeys()},
+			objects: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "maestro", Name: "maestro-db-config"},
+				Data:       map[string][]byte{"port": []byte("postgres")},
+			}},
+			expectErr: true,
+		},
+		{
+			name:      "secret not found",
+			secretRef: &DBSecretReference{Namespace: "maestro", Name: "maestro-db-config", Keys: NewDBSecretKeys()},
+			expectErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := &GRPCServerConfig{DBConfig: newDBConfig(), DBSecretRef: c.secretRef}
+
+			resolved, err := config.ResolveDBConfig(context.Background(), kubefake.NewSimpleClientset(c.objects...))
+			if c.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			c.validate(t, resolved)
+			// the db config of the config file is not changed
+			assert.Equal(t, newDBConfig(), config.DBConfig)
+		})
+	}
+}
+
+func TestLoadDBSecretReference(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configFile, []byte(`
+db_secret_ref:
+  namespace: maestro
+  name: maestro-db-config
+`), 0600))
+
+	config, err := LoadGRPCServerConfig(configFile)
+	assert.NoError(t, err)
+	assert.Equal(t, &DBSecretReference{
+		Namespace: "maestro",
+		Name:      "maestro-db-config",
+		Keys:      NewDBSecretKeys(),
+	}, config.DBSecretRef)
+
+	// the fields resolved from the secret are not required in the db config
+	assert.Empty(t, validateDatabaseConfig(config.DBConfig, config.DBSecretRef, field.NewPath("db_config")))
+}
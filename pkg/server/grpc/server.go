@@ -0,0 +1,273 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	grpcprom "github.com/grpc-ecosystem/go-grpc-middleware/providers/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"k8s.io/apimachinery/pkg/util/errors"
+	k8smetrics "k8s.io/component-base/metrics"
+	"k8s.io/klog/v2"
+	grpcserver "open-cluster-management.io/sdk-go/pkg/server/grpc"
+	"open-cluster-management.io/sdk-go/pkg/server/grpc/authn"
+	"open-cluster-management.io/sdk-go/pkg/server/grpc/authz"
+	"open-cluster-management.io/sdk-go/pkg/server/grpc/metrics"
+)
+
+// server runs the gRPC server in the same way as the sdk-go GRPCServer, except that the TLS material is
+// provided by the servingCertificates, so it can be reloaded without restarting the server. It is forked from
+// the sdk-go GRPCServer with the modifications of server.go.patch, `make verify-grpc-server-fork` fails when the
+// upstream server is changed in the sdk-go of go.mod.
+type server struct {
+	options            *grpcserver.GRPCServerOptions
+	certificates       *servingCertificates
+	extraMetrics       []k8smetrics.Registerable
+	registerFuncs      []func(*grpc.Server)
+	authenticators     []authn.Authenticator
+	unaryAuthorizers   []authz.UnaryAuthorizer
+	streamAuthorizers  []authz.StreamAuthorizer
+	unaryInterceptors  []grpc.UnaryServerInterceptor
+	streamInterceptors []grpc.StreamServerInterceptor
+}
+
+func newServer(options *grpcserver.GRPCServerOptions, certificates *servingCertificates) *server {
+	return &server{
+		options:      options,
+		certificates: certificates,
+	}
+}
+
+func (s *server) WithRegisterFunc(registerFunc func(*grpc.Server)) *server {
+	s.registerFuncs = append(s.registerFuncs, registerFunc)
+	return s
+}
+
+func (s *server) WithExtraMetrics(metrics ...k8smetrics.Registerable) *server {
+	s.extraMetrics = append(s.extraMetrics, metrics...)
+	return s
+}
+
+func (s *server) WithAuthenticator(authenticator authn.Authenticator) *server {
+	s.authenticators = append(s.authenticators, authenticator)
+	return s
+}
+
+func (s *server) WithUnaryAuthorizer(authorizer authz.UnaryAuthorizer) *server {
+	s.unaryAuthorizers = append(s.unaryAuthorizers, authorizer)
+	return s
+}
+
+func (s *server) WithStreamAuthorizer(authorizer authz.StreamAuthorizer) *server {
+	s.streamAuthorizers = append(s.streamAuthorizers, authorizer)
+	return s
+}
+
+// WithUnaryInterceptor appends the interceptor to the unary interceptors, it is chained after the authorizers.
+func (s *server) WithUnaryInterceptor(interceptor grpc.UnaryServerInterceptor) *server {
+	s.unaryInterceptors = append(s.unaryInterceptors, interceptor)
+	return s
+}
+
+// WithStreamInterceptor appends the interceptor to the stream interceptors, it is chained after the authorizers.
+func (s *server) WithStreamInterceptor(interceptor grpc.StreamServerInterceptor) *server {
+	s.streamInterceptors = append(s.streamInterceptors, interceptor)
+	return s
+}
+
+func (s *server) Run(ctx context.Context) error {
+	grpcServerOptions := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(s.options.MaxReceiveMessageSize),
+		grpc.MaxSendMsgSize(s.options.MaxSendMessageSize),
+		grpc.MaxConcurrentStreams(s.options.MaxConcurrentStreams),
+		grpc.ConnectionTimeout(s.options.ConnectionTimeout),
+		grpc.WriteBufferSize(s.options.WriteBufferSize),
+		grpc.ReadBufferSize(s.options.ReadBufferSize),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             s.options.ClientMinPingInterval,
+			PermitWithoutStream: s.options.PermitPingWithoutStream,
+		}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionAge: s.options.MaxConnectionAge,
+			Time:             s.options.ServerPingInterval,
+			Timeout:          s.options.ServerPingTimeout,
+		}),
+		// Serve with the reloadable TLS material
+		grpc.Creds(credentials.NewTLS(s.certificates.TLSConfig())),
+		// append the stats handler for metrics
+		grpc.StatsHandler(metrics.NewGRPCMetricsHandler()),
+		// append the stats handler for tracing, the spans are discarded if the tracing is not set up
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+	}
+
+	// init prometheus middleware for grpc server
+	promMiddleware := grpcprom.NewServerMetrics(
+		// enable grpc handling time histogram to measure latency distributions of RPCs
+		grpcprom.WithServerHandlingTimeHistogram(
+			grpcprom.WithHistogramBuckets(k8smetrics.ExponentialBuckets(10e-7, 10, 10)),
+		),
+	)
+
+	unaryInterceptors := append([]grpc.UnaryServerInterceptor{
+		metrics.NewGRPCMetricsUnaryInterceptor(promMiddleware),
+		newAuthnUnaryInterceptor(s.authenticators...),
+		newAuthzUnaryInterceptor(s.unaryAuthorizers...),
+	}, s.unaryInterceptors...)
+	streamInterceptors := append([]grpc.StreamServerInterceptor{
+		metrics.NewGRPCMetricsStreamInterceptor(promMiddleware),
+		newAuthnStreamInterceptor(s.authenticators...),
+		newAuthzStreamInterceptor(s.streamAuthorizers...),
+	}, s.streamInterceptors...)
+	grpcServerOptions = append(grpcServerOptions,
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...))
+
+	grpcServer := grpc.NewServer(grpcServerOptions...)
+	// register all the general grpc server metrics
+	metrics.RegisterGRPCMetrics(promMiddleware, s.extraMetrics...)
+	// initialize grpc server metrics with appropriate value.
+	promMiddleware.InitializeMetrics(grpcServer)
+
+	for _, r := range s.registerFuncs {
+		r(grpcServer)
+	}
+
+	// Start gRPC server
+	logger := klog.FromContext(ctx)
+	lis, err := net.Listen("tcp", ":"+s.options.ServerBindPort)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %v", err)
+	}
+	logger.Info("Starting gRPC server", "addr", lis.Addr().String())
+
+	serveErrCh := make(chan error, 1)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			serveErrCh <- fmt.Errorf("failed to serve gRPC server: %w", err)
+			return
+		}
+		serveErrCh <- nil
+	}()
+
+	select {
+	case <-ctx.Done():
+		logger.Info("Shutting down gRPC server")
+		grpcServer.GracefulStop()
+		return nil
+	case err := <-serveErrCh:
+		return err
+	}
+}
+
+// newAuthnUnaryInterceptor adds the identity from the first succeeded authenticator to the request context,
+// the health methods are not authenticated.
+func newAuthnUnaryInterceptor(authenticators ...authn.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if isHealthMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticate(ctx, authenticators)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// newAuthnStreamInterceptor adds the identity from the first succeeded authenticator to the stream context.
+func newAuthnStreamInterceptor(authenticators ...authn.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if isHealthMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticate(ss.Context(), authenticators)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &wrappedAuthStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func authenticate(ctx context.Context, authenticators []authn.Authenticator) (context.Context, error) {
+	var err error
+	for _, authenticator := range authenticators {
+		authenticatedCtx, authnErr := authenticator.Authenticate(ctx)
+		if authnErr == nil {
+			return authenticatedCtx, nil
+		}
+		err = authnErr
+	}
+	return ctx, err
+}
+
+// newAuthzUnaryInterceptor authorizes the request with the authorizers in order, the first allow or deny
+// decision wins.
+func newAuthzUnaryInterceptor(authorizers ...authz.UnaryAuthorizer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if isHealthMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		var errs []error
+		for _, authorizer := range authorizers {
+			decision, err := authorizer.AuthorizeRequest(ctx, req)
+			switch decision {
+			case authz.DecisionAllow:
+				return handler(ctx, req)
+			case authz.DecisionDeny:
+				return nil, fmt.Errorf("access denied: %v", err)
+			case authz.DecisionNoOpinion:
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+
+		if len(errs) > 0 {
+			return nil, errors.NewAggregate(errs)
+		}
+		return nil, fmt.Errorf("no authorizer found for %s", info.FullMethod)
+	}
+}
+
+// newAuthzStreamInterceptor authorizes the stream with the authorizers in order, the first allow or deny
+// decision wins.
+func newAuthzStreamInterceptor(authorizers ...authz.StreamAuthorizer) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if isHealthMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		var errs []error
+		for _, authorizer := range authorizers {
+			decision, authorizedStream, err := authorizer.AuthorizeStream(ss.Context(), ss, info)
+			switch decision {
+			case authz.DecisionAllow:
+				return handler(srv, authorizedStream)
+			case authz.DecisionDeny:
+				return fmt.Errorf("access denied: %v", err)
+			case authz.DecisionNoOpinion:
+				if err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+
+		if len(errs) > 0 {
+			return errors.NewAggregate(errs)
+		}
+		return fmt.Errorf("no authorizer found for %s", info.FullMethod)
+	}
+}
+
+// wrappedAuthStream wraps a grpc.ServerStream with the context that contains the authenticated identity.
+type wrappedAuthStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (w *wrappedAuthStream) Context() context.Context {
+	return w.ctx
+}
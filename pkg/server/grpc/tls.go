@@ -0,0 +1,80 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+
+	grpcserver "open-cluster-management.io/sdk-go/pkg/server/grpc"
+)
+
+// servingCertificates holds the TLS material of the gRPC server. The TLS config is resolved for each
+// handshake, so the rotated certificates are used by the new connections while the established agent
+// streams are kept.
+type servingCertificates struct {
+	sync.RWMutex
+	config *tls.Config
+}
+
+func newServingCertificates(options *grpcserver.GRPCServerOptions) (*servingCertificates, error) {
+	c := &servingCertificates{}
+	if err := c.Reload(options); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload loads the serving certificate and the client CA from the files of the options, the current TLS
+// material is kept if the files cannot be loaded.
+func (c *servingCertificates) Reload(options *grpcserver.GRPCServerOptions) error {
+	serverCerts, err := tls.LoadX509KeyPair(options.TLSCertFile, options.TLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load server certificates: %v", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{serverCerts},
+		MinVersion:   options.TLSMinVersion,
+		MaxVersion:   options.TLSMaxVersion,
+		// the config returned by GetConfigForClient replaces the one of the grpc credentials, so the
+		// ALPN protocol of the grpc must be set here
+		NextProtos: []string{"h2"},
+	}
+
+	if options.ClientCAFile != "" {
+		certPool := x509.NewCertPool()
+		caPEM, err := os.ReadFile(options.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read server client CA file: %v", err)
+		}
+		if ok := certPool.AppendCertsFromPEM(caPEM); !ok {
+			return fmt.Errorf("failed to append server client CA to cert pool")
+		}
+		config.ClientCAs = certPool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.config = config
+	return nil
+}
+
+// TLSConfig returns the TLS config of the gRPC server credentials.
+func (c *servingCertificates) TLSConfig() *tls.Config {
+	c.RLock()
+	defer c.RUnlock()
+	return &tls.Config{
+		MinVersion:         c.config.MinVersion,
+		MaxVersion:         c.config.MaxVersion,
+		GetConfigForClient: c.getConfigForClient,
+	}
+}
+
+func (c *servingCertificates) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.config, nil
+}
@@ -0,0 +1,207 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	_ "github.com/lib/pq"
+	dbconfig "github.com/openshift-online/maestro/pkg/config"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes"
+	grpcserver "open-cluster-management.io/sdk-go/pkg/server/grpc"
+)
+
+// supportedSSLModes are the sslmode values that are supported by the postgres driver.
+var supportedSSLModes = sets.New("disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+
+// Validate validates the gRPC server config, all of the invalid fields are reported with their paths in the
+// config file.
+func (c *GRPCServerConfig) Validate() error {
+	errs := field.ErrorList{}
+	if c.servesGRPC() {
+		errs = append(errs, validateGRPCServerOptions(c.GRPCConfig, field.NewPath("grpc_config"))...)
+	}
+	errs = append(errs, validateDBSecretReference(c.DBSecretRef, field.NewPath("db_secret_ref"))...)
+	errs = append(errs, validateDatabaseConfig(c.DBConfig, c.DBSecretRef, field.NewPath("db_config"))...)
+	if c.SpecControllerConfig != nil {
+		errs = append(errs, c.SpecControllerConfig.Validate(field.NewPath("spec_controller_config"))...)
+	}
+	if c.BrokerConfig != nil {
+		errs = append(errs, c.BrokerConfig.Validate(field.NewPath("broker_config"))...)
+	}
+	if c.AuditConfig != nil {
+		errs = append(errs, c.AuditConfig.Validate(field.NewPath("audit_config"))...)
+	}
+	if c.TracingConfig != nil {
+		errs = append(errs, c.TracingConfig.Validate(field.NewPath("tracing_config"))...)
+	}
+	if c.StatusUpdateLimitConfig != nil {
+		errs = append(errs, c.StatusUpdateLimitConfig.Validate(field.NewPath("status_update_limit_config"))...)
+	}
+	if c.StatusCoalescingConfig != nil {
+		errs = append(errs, c.StatusCoalescingConfig.Validate(field.NewPath("status_coalescing_config"))...)
+	}
+	if c.LeaderElectionConfig != nil {
+		errs = append(errs, c.LeaderElectionConfig.Validate(field.NewPath("leader_election_config"))...)
+	}
+	if c.StreamOwnershipConfig != nil {
+		errs = append(errs, c.StreamOwnershipConfig.Validate(field.NewPath("stream_ownership_config"))...)
+		if !c.servesGRPC() {
+			errs = append(errs, field.Forbidden(field.NewPath("stream_ownership_config"),
+				"the stream ownership is only supported by the grpc broker"))
+		}
+	}
+	if c.ListenerConfig != nil {
+		errs = append(errs, c.ListenerConfig.Validate(field.NewPath("listener_config"))...)
+	}
+	return errs.ToAggregate()
+}
+
+// ProbeTLSFiles checks the TLS files of the gRPC server can be loaded, they are not used if the gRPC server
+// is not served.
+func (c *GRPCServerConfig) ProbeTLSFiles() error {
+	if !c.servesGRPC() {
+		return nil
+	}
+	_, err := newServingCertificates(c.GRPCConfig)
+	return err
+}
+
+// ProbeDatabase checks the database can be connected with the database config, the credentials are resolved
+// from the db_secret_ref with the kube client if it is set. The connection is not established by the session
+// factory, because it panics if the database cannot be connected.
+func (c *GRPCServerConfig) ProbeDatabase(ctx context.Context, kubeClient kubernetes.Interface) error {
+	config, err := c.ResolveDBConfig(ctx, kubeClient)
+	if err != nil {
+		return err
+	}
+
+	sqlDB, err := sql.Open(config.Dialect, config.ConnectionString(config.SSLMode != "disable"))
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer sqlDB.Close()
+
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to connect database %s:%d: %w", config.Host, config.Port, err)
+	}
+	return nil
+}
+
+func validateGRPCServerOptions(options *grpcserver.GRPCServerOptions, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if options == nil {
+		return append(errs, field.Required(fldPath, ""))
+	}
+
+	if options.TLSCertFile == "" {
+		errs = append(errs, field.Required(fldPath.Child("tls_cert_file"), ""))
+	}
+	if options.TLSKeyFile == "" {
+		errs = append(errs, field.Required(fldPath.Child("tls_key_file"), ""))
+	}
+	if options.TLSMinVersion < tls.VersionTLS12 {
+		errs = append(errs, field.Invalid(fldPath.Child("tls_min_version"), options.TLSMinVersion,
+			fmt.Sprintf("must not be lower than TLS 1.2 (%d)", tls.VersionTLS12)))
+	}
+	if options.TLSMaxVersion > tls.VersionTLS13 {
+		errs = append(errs, field.Invalid(fldPath.Child("tls_max_version"), options.TLSMaxVersion,
+			fmt.Sprintf("must not be higher than TLS 1.3 (%d)", tls.VersionTLS13)))
+	}
+	if options.TLSMinVersion > options.TLSMaxVersion {
+		errs = append(errs, field.Invalid(fldPath.Child("tls_min_version"), options.TLSMinVersion,
+			fmt.Sprintf("must not be greater than tls_max_version (%d)", options.TLSMaxVersion)))
+	}
+	if port, err := strconv.Atoi(options.ServerBindPort); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, field.Invalid(fldPath.Child("server_bind_port"), options.ServerBindPort,
+			"must be a port number between 1 and 65535"))
+	}
+	if options.MaxReceiveMessageSize <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("max_receive_message_size"), options.MaxReceiveMessageSize,
+			"must be greater than 0"))
+	}
+	if options.MaxSendMessageSize <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("max_send_message_size"), options.MaxSendMessageSize,
+			"must be greater than 0"))
+	}
+	if options.WriteBufferSize < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("write_buffer_size"), options.WriteBufferSize,
+			"must not be negative"))
+	}
+	if options.ReadBufferSize < 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("read_buffer_size"), options.ReadBufferSize,
+			"must not be negative"))
+	}
+	if options.ConnectionTimeout <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("connection_timeout"), options.ConnectionTimeout.String(),
+			"must be greater than 0"))
+	}
+	if options.ServerPingInterval <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("server_ping_interval"), options.ServerPingInterval.String(),
+			"must be greater than 0"))
+	}
+	if options.ServerPingTimeout <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("server_ping_timeout"), options.ServerPingTimeout.String(),
+			"must be greater than 0"))
+	}
+	return errs
+}
+
+func validateDBSecretReference(secretRef *DBSecretReference, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if secretRef == nil {
+		return errs
+	}
+
+	if secretRef.Namespace == "" {
+		errs = append(errs, field.Required(fldPath.Child("namespace"), ""))
+	}
+	if secretRef.Name == "" {
+		errs = append(errs, field.Required(fldPath.Child("name"), ""))
+	}
+	return errs
+}
+
+// validateDatabaseConfig validates the database config, the maestro database config has no yaml tags, so its
+// fields are named with the lowercased field names in the config file. The fields that are resolved from
+// the referenced Secret are not required in the config file.
+func validateDatabaseConfig(config *dbconfig.DatabaseConfig, secretRef *DBSecretReference,
+	fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if config == nil {
+		return append(errs, field.Required(fldPath, ""))
+	}
+
+	keys := DBSecretKeys{}
+	if secretRef != nil {
+		keys = secretRef.Keys
+	}
+
+	if config.Dialect != "postgres" {
+		errs = append(errs, field.NotSupported(fldPath.Child("dialect"), config.Dialect, []string{"postgres"}))
+	}
+	if config.Host == "" && keys.Host == "" {
+		errs = append(errs, field.Required(fldPath.Child("host"), ""))
+	}
+	if (config.Port < 1 || config.Port > 65535) && keys.Port == "" {
+		errs = append(errs, field.Invalid(fldPath.Child("port"), config.Port, "must be between 1 and 65535"))
+	}
+	if config.Name == "" && keys.Name == "" {
+		errs = append(errs, field.Required(fldPath.Child("name"), ""))
+	}
+	if config.Username == "" && keys.Username == "" {
+		errs = append(errs, field.Required(fldPath.Child("username"), ""))
+	}
+	if !supportedSSLModes.Has(config.SSLMode) {
+		errs = append(errs, field.NotSupported(fldPath.Child("sslmode"), config.SSLMode, sets.List(supportedSSLModes)))
+	}
+	if config.MaxOpenConnections < 1 {
+		errs = append(errs, field.Invalid(fldPath.Child("maxopenconnections"), config.MaxOpenConnections,
+			"must be at least 1"))
+	}
+	return errs
+}
@@ -0,0 +1,127 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+// defaultReloadDelay is the duration that the file watcher waits for the following changes before reloading,
+// a Secret or ConfigMap volume update usually produces several file events.
+const defaultReloadDelay = time.Second
+
+// fileWatcher calls the reload func when the watched files are changed. The parent directories of the
+// files are watched instead of the files, so the atomic symlink swaps of the kubelet for the Secret and
+// ConfigMap volumes are observed.
+type fileWatcher struct {
+	sync.Mutex
+	files       sets.Set[string]
+	reloadDelay time.Duration
+	reload      func(ctx context.Context) error
+	watcher     *fsnotify.Watcher
+}
+
+func newFileWatcher(reload func(ctx context.Context) error, files ...string) (*fileWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	w := &fileWatcher{
+		files:       sets.New[string](),
+		reloadDelay: defaultReloadDelay,
+		reload:      reload,
+		watcher:     watcher,
+	}
+	if err := w.Watch(files...); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Watch adds the files to the watcher, the files that are already watched are ignored.
+func (w *fileWatcher) Watch(files ...string) error {
+	w.Lock()
+	defer w.Unlock()
+
+	for _, file := range files {
+		if len(file) == 0 || w.files.Has(filepath.Clean(file)) {
+			continue
+		}
+
+		file = filepath.Clean(file)
+		if err := w.watcher.Add(filepath.Dir(file)); err != nil {
+			return fmt.Errorf("failed to watch file %s: %w", file, err)
+		}
+		w.files.Insert(file)
+	}
+	return nil
+}
+
+// Run handles the file events until the context is done, the changes in the reload delay are reloaded once.
+func (w *fileWatcher) Run(ctx context.Context) {
+	logger := klog.FromContext(ctx)
+	defer func() {
+		if err := w.watcher.Close(); err != nil {
+			logger.Error(err, "failed to close file watcher")
+		}
+	}()
+
+	timer := time.NewTimer(w.reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if !w.isWatched(event.Name) {
+				continue
+			}
+			logger.V(4).Info("watched file is changed", "file", event.Name, "op", event.Op.String())
+			timer.Reset(w.reloadDelay)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Error(err, "failed to watch files")
+		case <-timer.C:
+			if err := w.reload(ctx); err != nil {
+				logger.Error(err, "failed to reload the changed files")
+			}
+		}
+	}
+}
+
+// isWatched returns true if the event is for a watched file, or for the data directory symlink of the
+// kubelet that is swapped when a Secret or ConfigMap volume is updated.
+func (w *fileWatcher) isWatched(name string) bool {
+	w.Lock()
+	defer w.Unlock()
+
+	name = filepath.Clean(name)
+	if w.files.Has(name) {
+		return true
+	}
+
+	if filepath.Base(name) != "..data" {
+		return false
+	}
+	for file := range w.files {
+		if filepath.Dir(file) == filepath.Dir(name) {
+			return true
+		}
+	}
+	return false
+}
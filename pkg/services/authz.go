@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	grpcauthn "open-cluster-management.io/sdk-go/pkg/server/grpc/authn"
+)
+
+// The prefixes of the identities that are bound to a cluster:
+const (
+	// clusterIdentityPrefix is the prefix of the user `system:open-cluster-management:<cluster>:<agent>` and the
+	// group `system:open-cluster-management:<cluster>` of the registered agents, and the user and the group of
+	// the addon agents `system:open-cluster-management:cluster:<cluster>:addon:<addon>...`.
+	clusterIdentityPrefix = "system:open-cluster-management:"
+	addonIdentityPrefix   = clusterIdentityPrefix + "cluster:"
+	// serviceAccountPrefix is the prefix of the service accounts, the service accounts in the cluster namespace
+	// are bound to the cluster.
+	serviceAccountPrefix = "system:serviceaccount:"
+)
+
+// authorizeStatusUpdate checks the status update before it is handled by the backend: the status update must
+// carry the cluster name, and the authenticated identity of the agent must be bound to that cluster. The SAR
+// authorizer of the gRPC server only checks that the identity can access the namespace of the cluster, so an
+// identity with access to other namespaces could still publish the status updates of another cluster. The
+// identity is not checked if the context has no authenticated identity, e.g. the status updates from a message
+// queue broker, whose topics are authorized by the ACLs of the clusters. The backends check that the resource
+// belongs to the cluster when they get the resource, see deniedStatusUpdate.
+func authorizeStatusUpdate(ctx context.Context, backend Backend, evt *ce.Event) error {
+	resourceID, _ := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+	clusterName, err := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionClusterName])
+	if err != nil || len(clusterName) == 0 {
+		return denyStatusUpdate(backend.Source(), denyReasonClusterName, resourceID,
+			fmt.Errorf("the cluster name of the status update is missing"))
+	}
+
+	user, _ := ctx.Value(grpcauthn.ContextUserKey).(string)
+	groups, _ := ctx.Value(grpcauthn.ContextGroupsKey).([]string)
+	if (len(user) > 0 || len(groups) > 0) && !boundToCluster(user, groups, clusterName) {
+		return denyStatusUpdate(backend.Source(), denyReasonIdentity, resourceID,
+			fmt.Errorf("the user %q is not bound to the cluster %s", user, clusterName))
+	}
+	return nil
+}
+
+// boundToCluster returns true if the user or one of the groups is an identity of the cluster.
+func boundToCluster(user string, groups []string, clusterName string) bool {
+	if strings.HasPrefix(user, clusterIdentityPrefix+clusterName+":") ||
+		strings.HasPrefix(user, addonIdentityPrefix+clusterName+":") ||
+		strings.HasPrefix(user, serviceAccountPrefix+clusterName+":") {
+		return true
+	}
+	for _, group := range groups {
+		if group == clusterIdentityPrefix+clusterName || strings.HasPrefix(group, addonIdentityPrefix+clusterName+":") {
+			return true
+		}
+	}
+	return false
+}
+
+func denyStatusUpdate(source, reason, resourceID string, err error) error {
+	err = kubeerrors.NewForbidden(schema.GroupResource{Resource: "manifestbundles"}, resourceID, err)
+	observeStatusUpdateDenied(source, reason, resourceID, err)
+	return err
+}
+
+// deniedStatusUpdate counts the status update that is rejected by the backend with a forbidden error, e.g. the
+// DBBackend rejects the status update of a resource that does not belong to the cluster of the event. It returns
+// false if the error is not a forbidden error.
+func deniedStatusUpdate(backend Backend, evt *ce.Event, err error) bool {
+	if !kubeerrors.IsForbidden(err) {
+		return false
+	}
+	resourceID, _ := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+	observeStatusUpdateDenied(backend.Source(), denyReasonResource, resourceID, err)
+	return true
+}
+
+func observeStatusUpdateDenied(source, reason, resourceID string, err error) {
+	routerStatusUpdatesDenied.WithLabelValues(source, reason).Inc()
+	klog.Warningf("denied the %s resource status update %s: %v", source, resourceID, err)
+}
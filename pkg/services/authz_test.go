@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8smetrics "k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/testutil"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	grpcauthn "open-cluster-management.io/sdk-go/pkg/server/grpc/authn"
+)
+
+// fakeClusterBackend rejects the status updates of the resources of other clusters as the DBBackend does, and
+// records the handled status updates.
+type fakeClusterBackend struct {
+	*fakeBackend
+	clusters   map[string]string
+	handledIDs []string
+}
+
+func (b *fakeClusterBackend) HandleStatusUpdate(ctx context.Context, evt *ce.Event) error {
+	resourceID := evt.Extensions()[types.ExtensionResourceID].(string)
+	if cluster, ok := b.clusters[resourceID]; ok && cluster != evt.Extensions()[types.ExtensionClusterName] {
+		return kubeerrors.NewForbidden(schema.GroupResource{Resource: "manifestbundles"}, resourceID,
+			fmt.Errorf("the resource does not belong to the cluster"))
+	}
+	b.handledIDs = append(b.handledIDs, resourceID)
+	return nil
+}
+
+func TestBoundToCluster(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   string
+		groups []string
+		want   bool
+	}{
+		{
+			name: "registration agent user",
+			user: "system:open-cluster-management:cluster1:agent1",
+			want: true,
+		},
+		{
+			name:   "registration cluster group",
+			user:   "unknown",
+			groups: []string{"system:open-cluster-management:managed-clusters", "system:open-cluster-management:cluster1"},
+			want:   true,
+		},
+		{
+			name:   "addon agent",
+			user:   "system:open-cluster-management:cluster:cluster1:addon:foo:agent:bar",
+			groups: []string{"system:open-cluster-management:cluster:cluster1:addon:foo"},
+			want:   true,
+		},
+		{
+			name: "service account in the cluster namespace",
+			user: "system:serviceaccount:cluster1:work-agent",
+			want: true,
+		},
+		{
+			name:   "other cluster",
+			user:   "system:open-cluster-management:cluster2:agent1",
+			groups: []string{"system:open-cluster-management:managed-clusters", "system:open-cluster-management:cluster2"},
+			want:   false,
+		},
+		{
+			name: "cluster name prefix",
+			user: "system:open-cluster-management:cluster10:agent1",
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := boundToCluster(tt.user, tt.groups, "cluster1"); got != tt.want {
+				t.Errorf("boundToCluster() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterServiceAuthorizeStatusUpdate(t *testing.T) {
+	registry := k8smetrics.NewKubeRegistry()
+	registry.MustRegister(RouterMetrics()...)
+
+	// the kube resources are looked up in the namespace of the cluster name of the event
+	kube := &fakeClusterBackend{fakeBackend: newFakeBackend("kube")}
+	gitops := newFakeBackend("gitops")
+	db := &fakeClusterBackend{
+		fakeBackend: newFakeBackend("maestro"),
+		clusters:    map[string]string{"r1": "cluster1", "r2": "cluster2"},
+	}
+	router := newTestRouterService(t, kube, gitops, db)
+
+	agentCtx := context.WithValue(context.Background(), grpcauthn.ContextUserKey,
+		"system:open-cluster-management:cluster1:agent1")
+	agentCtx = context.WithValue(agentCtx, grpcauthn.ContextGroupsKey,
+		[]string{"system:open-cluster-management:cluster1"})
+
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		source         string
+		resourceID     string
+		clusterName    string
+		expectedReason string
+	}{
+		{
+			name:        "db resource of the agent cluster",
+			ctx:         agentCtx,
+			source:      "maestro",
+			resourceID:  "r1",
+			clusterName: "cluster1",
+		},
+		{
+			name:           "db resource of another cluster",
+			ctx:            agentCtx,
+			source:         "maestro",
+			resourceID:     "r2",
+			clusterName:    "cluster1",
+			expectedReason: denyReasonResource,
+		},
+		{
+			name:           "db resource claimed for another cluster",
+			ctx:            agentCtx,
+			source:         "maestro",
+			resourceID:     "r2",
+			clusterName:    "cluster2",
+			expectedReason: denyReasonIdentity,
+		},
+		{
+			name:        "deleted db resource",
+			ctx:         agentCtx,
+			source:      "maestro",
+			resourceID:  "r3",
+			clusterName: "cluster1",
+		},
+		{
+			name:        "kube resource of the agent cluster",
+			ctx:         agentCtx,
+			source:      "kube",
+			resourceID:  "w1",
+			clusterName: "cluster1",
+		},
+		{
+			name:           "kube resource claimed for another cluster",
+			ctx:            agentCtx,
+			source:         "kube",
+			resourceID:     "w1",
+			clusterName:    "cluster2",
+			expectedReason: denyReasonIdentity,
+		},
+		{
+			name:           "no cluster name",
+			ctx:            agentCtx,
+			source:         "gitops",
+			resourceID:     "app/foo",
+			expectedReason: denyReasonClusterName,
+		},
+		{
+			name:        "no authenticated identity",
+			ctx:         context.Background(),
+			source:      "kube",
+			resourceID:  "w2",
+			clusterName: "cluster2",
+		},
+		{
+			name:           "no authenticated identity for db resource of another cluster",
+			ctx:            context.Background(),
+			source:         "maestro",
+			resourceID:     "r1",
+			clusterName:    "cluster2",
+			expectedReason: denyReasonResource,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := testutil.GetCounterMetricValue(
+				routerStatusUpdatesDenied.WithLabelValues(tt.source, tt.expectedReason))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			status := ce.NewEvent()
+			status.SetExtension(types.ExtensionResourceID, tt.resourceID)
+			status.SetExtension(types.ExtensionOriginalSource, tt.source)
+			if len(tt.clusterName) > 0 {
+				status.SetExtension(types.ExtensionClusterName, tt.clusterName)
+			}
+			err = router.HandleStatusUpdate(tt.ctx, &status)
+
+			if len(tt.expectedReason) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !kubeerrors.IsForbidden(err) {
+				t.Fatalf("expected forbidden error, but got %v", err)
+			}
+			after, err := testutil.GetCounterMetricValue(
+				routerStatusUpdatesDenied.WithLabelValues(tt.source, tt.expectedReason))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if after-before != 1 {
+				t.Errorf("expected the denied status updates increased by 1, but got %v", after-before)
+			}
+		})
+	}
+
+	// the denied status updates never reach the backends
+	if expected := []string{"r1", "r3"}; fmt.Sprint(db.handledIDs) != fmt.Sprint(expected) {
+		t.Errorf("expected handled db resources %v, but got %v", expected, db.handledIDs)
+	}
+	if expected := []string{"w1", "w2"}; fmt.Sprint(kube.handledIDs) != fmt.Sprint(expected) {
+		t.Errorf("expected handled kube resources %v, but got %v", expected, kube.handledIDs)
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	"github.com/stolostron/cloudevents-conductor/pkg/audit"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/server"
+)
+
+// sourceIDSeparator separates the backend source from the backend resource ID in a routed resource ID.
+const sourceIDSeparator = "::"
+
+// Backend is a manifest bundle backend that is registered to the RouterService.
+// The server.Service methods of a backend work with its own resource IDs, the IDCodec is used by the
+// RouterService to convert them from/to the resource IDs that are exposed to the agents.
+type Backend interface {
+	server.Service
+	PagedLister
+	IDCodec
+
+	// ListChangedPages is the same as ListPages, except that only the resources that the agent needs are
+	// listed, every resource of the backend is checked with versions.Changed, see ResourceVersions.
+	ListChangedPages(ctx context.Context, listOpts types.ListOptions, versions *ResourceVersions,
+		fn func(evts []*ce.Event) error) error
+}
+
+// PagedLister lists the resources page by page, so the resources of a cluster are not loaded into memory at
+// once, e.g. when responding the spec resync request of an agent.
+type PagedLister interface {
+	// ListPages passes each page of the resources to fn in order, the listing is stopped and the error is
+	// returned if fn returns an error.
+	ListPages(ctx context.Context, listOpts types.ListOptions, fn func(evts []*ce.Event) error) error
+}
+
+// IDCodec converts between the resource IDs of a backend and the routed resource IDs.
+type IDCodec interface {
+	// Source returns the source of the backend, it is used as the prefix of the routed resource IDs and
+	// matches the original source of the status update events from the agents.
+	Source() string
+
+	// EncodeID converts a backend resource ID to a routed resource ID.
+	EncodeID(id string) string
+
+	// DecodeID converts a routed resource ID back to a backend resource ID.
+	DecodeID(resourceID string) (string, error)
+}
+
+var _ IDCodec = SourceIDCodec{}
+
+// SourceIDCodec is an IDCodec that prefixes the backend resource ID with the source,
+// e.g. `kube::<namespace>/<name>` or `maestro::<uuid>`.
+type SourceIDCodec struct {
+	source string
+}
+
+func NewSourceIDCodec(source string) SourceIDCodec {
+	return SourceIDCodec{source: source}
+}
+
+func (c SourceIDCodec) Source() string {
+	return c.source
+}
+
+func (c SourceIDCodec) EncodeID(id string) string {
+	return fmt.Sprintf("%s%s%s", c.source, sourceIDSeparator, id)
+}
+
+func (c SourceIDCodec) DecodeID(resourceID string) (string, error) {
+	id, found := strings.CutPrefix(resourceID, c.source+sourceIDSeparator)
+	if !found {
+		return "", fmt.Errorf("resource ID %s does not belong to source %s", resourceID, c.source)
+	}
+	return id, nil
+}
+
+// sourceOf returns the source of a routed resource ID or an original source,
+// e.g. both `kube::ns/name` and `kube` return `kube`.
+func sourceOf(resourceID string) string {
+	source, _, _ := strings.Cut(resourceID, sourceIDSeparator)
+	return source
+}
+
+// routedEventHandler wraps a server.EventHandler to convert the backend resource IDs to the routed resource
+// IDs before passing them to the handler, the spec events are audited if the audit logger is not nil.
+type routedEventHandler struct {
+	codec       IDCodec
+	handler     server.EventHandler
+	auditLogger *audit.Logger
+}
+
+func (h *routedEventHandler) OnCreate(ctx context.Context, t types.CloudEventsDataType, resourceID string) error {
+	routedID := h.codec.EncodeID(resourceID)
+	ctx, spec := withSpecEvent(ctx)
+	err := h.handler.OnCreate(ctx, t, routedID)
+	h.audit(ctx, audit.OperationCreate, t, types.CreateRequestAction, routedID, spec.evt, err)
+	return err
+}
+
+func (h *routedEventHandler) OnUpdate(ctx context.Context, t types.CloudEventsDataType, resourceID string) error {
+	routedID := h.codec.EncodeID(resourceID)
+	ctx, spec := withSpecEvent(ctx)
+	err := h.handler.OnUpdate(ctx, t, routedID)
+	h.audit(ctx, audit.OperationUpdate, t, types.UpdateRequestAction, routedID, spec.evt, err)
+	return err
+}
+
+func (h *routedEventHandler) OnDelete(ctx context.Context, t types.CloudEventsDataType, resourceID string) error {
+	routedID := h.codec.EncodeID(resourceID)
+	ctx, spec := withSpecEvent(ctx)
+	err := h.handler.OnDelete(ctx, t, routedID)
+	h.audit(ctx, audit.OperationDelete, t, types.DeleteRequestAction, routedID, spec.evt, err)
+	return err
+}
+
+// audit records the spec event that is sent to the agents, the cluster name and the resource version are filled
+// from the spec that is got by the handler if it is not nil.
+func (h *routedEventHandler) audit(ctx context.Context, operation audit.Operation, t types.CloudEventsDataType,
+	action types.EventAction, resourceID string, evt *ce.Event, err error) {
+	if h.auditLogger == nil {
+		return
+	}
+
+	event := audit.NewEvent(operation, resourceID, evt, err)
+	event.Source = h.codec.Source()
+	event.EventType = types.CloudEventsType{
+		CloudEventsDataType: t,
+		SubResource:         types.SubResourceSpec,
+		Action:              action,
+	}.String()
+	h.auditLogger.Log(ctx, event)
+}
+
+type specEventKey struct{}
+
+// specEvent holds the spec that the handler of a spec event gets from the RouterService, the brokers get the
+// spec with the context of the spec event before they send it.
+type specEvent struct {
+	evt *ce.Event
+}
+
+func withSpecEvent(ctx context.Context) (context.Context, *specEvent) {
+	spec := &specEvent{}
+	return context.WithValue(ctx, specEventKey{}, spec), spec
+}
+
+// recordSpecEvent sets the spec to the specEvent of the context if the spec is got for a spec event.
+func recordSpecEvent(ctx context.Context, evt *ce.Event) {
+	if spec, ok := ctx.Value(specEventKey{}).(*specEvent); ok && evt != nil {
+		spec.evt = evt
+	}
+}
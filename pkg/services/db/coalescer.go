@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/lru"
+)
+
+// flushedStatusesCacheSize is the max number of resources whose last written status is remembered, so the
+// same status of them is not written again.
+const flushedStatusesCacheSize = 10000
+
+// StatusCoalescingOptions defines the coalescing of the resource status updates before they are written to
+// the database.
+type StatusCoalescingOptions struct {
+	// Window is how long the status updates of a resource are collected after a status of it is written, only the
+	// latest status of them is written to the database after the window.
+	Window time.Duration `json:"window,omitempty" yaml:"window,omitempty"`
+}
+
+func NewStatusCoalescingOptions() *StatusCoalescingOptions {
+	return &StatusCoalescingOptions{
+		Window: 500 * time.Millisecond,
+	}
+}
+
+// Validate validates the options, the errors are reported with the given field path.
+func (o *StatusCoalescingOptions) Validate(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if o.Window <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("window"), o.Window.String(), "must be greater than 0"))
+	}
+	return errs
+}
+
+// statusDigest identifies a resource status by the resource version and the hash of the status content.
+type statusDigest struct {
+	version int64
+	hash    string
+}
+
+// coalescedStatus is the latest status update of a resource that is received in the window after a status of
+// the resource is written.
+type coalescedStatus struct {
+	ctx    context.Context
+	digest statusDigest
+	handle func(ctx context.Context) error
+}
+
+// statusWindow is the window of a resource, it starts when a status of the resource is written.
+type statusWindow struct {
+	// written is the status that is written when the window starts
+	written statusDigest
+	// pending is the latest status that is received in the window, it is written after the window
+	pending *coalescedStatus
+}
+
+// statusCoalescer writes the first status update of a resource at once, and coalesces the status updates that
+// are received while it is written and in the window after it, only the latest of them is written after the
+// window. The callers of the coalesced status updates do not wait for the window, a failed write of a coalesced
+// status is recovered by the next status update or the status resync of the agent.
+type statusCoalescer struct {
+	sync.Mutex
+	window  time.Duration
+	windows map[string]*statusWindow
+	flushed *lru.Cache
+}
+
+func newStatusCoalescer(options *StatusCoalescingOptions) *statusCoalescer {
+	return &statusCoalescer{
+		window:  options.Window,
+		windows: map[string]*statusWindow{},
+		flushed: lru.New(flushedStatusesCacheSize),
+	}
+}
+
+// Handle writes the status update of the resource at once if no window of the resource is open, the error of
+// writing it is returned. Otherwise the status update replaces the pending status of the window and nil is
+// returned. A status update that is older than the written or the pending one, or the same as the last written
+// one, is not written. The resourceID only keys the windows, so it may also carry the cluster of the update.
+func (c *statusCoalescer) Handle(ctx context.Context, resourceID string, digest statusDigest,
+	handle func(ctx context.Context) error) error {
+	c.Lock()
+	if window, ok := c.windows[resourceID]; ok {
+		if digest.version >= window.written.version &&
+			(window.pending == nil || digest.version >= window.pending.digest.version) {
+			// the status is written after the request of the status update is finished
+			window.pending = &coalescedStatus{ctx: context.WithoutCancel(ctx), digest: digest, handle: handle}
+		}
+		c.Unlock()
+		return nil
+	}
+	if flushed, ok := c.flushed.Get(resourceID); ok && flushed.(statusDigest) == digest {
+		c.Unlock()
+		return nil
+	}
+	c.windows[resourceID] = &statusWindow{written: digest}
+	c.Unlock()
+
+	return c.write(ctx, resourceID, digest, handle)
+}
+
+// write writes the status of the resource and closes the window of the resource after the window duration, the
+// pending status of the window is written then.
+func (c *statusCoalescer) write(ctx context.Context, resourceID string, digest statusDigest,
+	handle func(ctx context.Context) error) error {
+	err := handle(ctx)
+
+	c.Lock()
+	defer c.Unlock()
+	if err == nil {
+		c.flushed.Add(resourceID, digest)
+	} else {
+		c.flushed.Remove(resourceID)
+		// the window is closed at once, so the status update that is retried is written at once
+		if c.windows[resourceID].pending == nil {
+			delete(c.windows, resourceID)
+			return err
+		}
+	}
+
+	time.AfterFunc(c.window, func() { c.flush(resourceID) })
+	return err
+}
+
+func (c *statusCoalescer) flush(resourceID string) {
+	c.Lock()
+	window, ok := c.windows[resourceID]
+	if !ok {
+		c.Unlock()
+		return
+	}
+	pending := window.pending
+	if pending == nil {
+		delete(c.windows, resourceID)
+		c.Unlock()
+		return
+	}
+	// a new window is started with the pending status
+	c.windows[resourceID] = &statusWindow{written: pending.digest}
+	c.Unlock()
+
+	if err := c.write(pending.ctx, resourceID, pending.digest, pending.handle); err != nil {
+		klog.Errorf("Failed to write the coalesced status of resource %s: %v", resourceID, err)
+	}
+}
@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// fakeStatusWriter records the statuses that are written.
+type fakeStatusWriter struct {
+	sync.Mutex
+	written []string
+	err     error
+}
+
+func (w *fakeStatusWriter) handler(status string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		w.Lock()
+		defer w.Unlock()
+		w.written = append(w.written, status)
+		return w.err
+	}
+}
+
+func (w *fakeStatusWriter) statuses() []string {
+	w.Lock()
+	defer w.Unlock()
+	return append([]string{}, w.written...)
+}
+
+func TestStatusCoalescingOptionsValidate(t *testing.T) {
+	if errs := NewStatusCoalescingOptions().Validate(field.NewPath("coalescing")); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	errs := (&StatusCoalescingOptions{}).Validate(field.NewPath("coalescing"))
+	if len(errs) != 1 || errs[0].Field != "coalescing.window" {
+		t.Errorf("expected the window error, but got %v", errs)
+	}
+}
+
+// waitStatuses waits until the expected statuses are written.
+func (w *fakeStatusWriter) waitStatuses(t *testing.T, expected []string) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if fmt.Sprint(w.statuses()) == fmt.Sprint(expected) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("expected written statuses %v, but got %v", expected, w.statuses())
+}
+
+func TestStatusCoalescer(t *testing.T) {
+	cases := []struct {
+		name     string
+		updates  []statusDigest
+		expected []string
+	}{
+		{
+			name:     "single status",
+			updates:  []statusDigest{{version: 1, hash: "a"}},
+			expected: []string{"1/a"},
+		},
+		{
+			name: "latest status of the burst",
+			updates: []statusDigest{
+				{version: 1, hash: "a"},
+				{version: 1, hash: "b"},
+				{version: 2, hash: "c"},
+				{version: 2, hash: "d"},
+			},
+			expected: []string{"1/a", "2/d"},
+		},
+		{
+			name: "older resource version",
+			updates: []statusDigest{
+				{version: 2, hash: "a"},
+				{version: 1, hash: "b"},
+				{version: 3, hash: "c"},
+				{version: 2, hash: "d"},
+			},
+			expected: []string{"2/a", "3/c"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			writer := &fakeStatusWriter{}
+			window := 100 * time.Millisecond
+			coalescer := newStatusCoalescer(&StatusCoalescingOptions{Window: window})
+
+			// the first status is written at once, the others are added to the window without waiting for it
+			start := time.Now()
+			for _, digest := range c.updates {
+				status := fmt.Sprintf("%d/%s", digest.version, digest.hash)
+				if err := coalescer.Handle(context.Background(), "r1", digest, writer.handler(status)); err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}
+			if elapsed := time.Since(start); elapsed >= window {
+				t.Errorf("expected the status updates are not blocked by the window, but took %s", elapsed)
+			}
+			if actual := writer.statuses(); fmt.Sprint(actual) != fmt.Sprint(c.expected[:1]) {
+				t.Errorf("expected written statuses %v, but got %v", c.expected[:1], actual)
+			}
+
+			writer.waitStatuses(t, c.expected)
+		})
+	}
+}
+
+func TestStatusCoalescerHandled(t *testing.T) {
+	writer := &fakeStatusWriter{err: fmt.Errorf("db unavailable")}
+	coalescer := newStatusCoalescer(&StatusCoalescingOptions{Window: time.Hour})
+	digest := statusDigest{version: 1, hash: "a"}
+
+	// the failed status is written again at once
+	if err := coalescer.Handle(context.Background(), "r1", digest, writer.handler("1/a")); err == nil {
+		t.Errorf("expected error, but got nil")
+	}
+	writer.err = nil
+	if err := coalescer.Handle(context.Background(), "r1", digest, writer.handler("1/a")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if expected := []string{"1/a", "1/a"}; fmt.Sprint(writer.statuses()) != fmt.Sprint(expected) {
+		t.Errorf("expected written statuses %v, but got %v", expected, writer.statuses())
+	}
+
+	// the same status is not written again after the window is closed
+	coalescer.flush("r1")
+	if err := coalescer.Handle(context.Background(), "r1", digest, writer.handler("1/a")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := coalescer.Handle(context.Background(), "r2", digest, writer.handler("r2 1/a")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if expected := []string{"1/a", "1/a", "r2 1/a"}; fmt.Sprint(writer.statuses()) != fmt.Sprint(expected) {
+		t.Errorf("expected written statuses %v, but got %v", expected, writer.statuses())
+	}
+
+	// the coalesced status is written even if the request of the status update is done
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := coalescer.Handle(ctx, "r2", statusDigest{version: 2, hash: "b"}, writer.handler("r2 2/b")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	cancel()
+	coalescer.flush("r2")
+	writer.waitStatuses(t, []string{"1/a", "1/a", "r2 1/a", "r2 2/b"})
+}
@@ -2,13 +2,18 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net/url"
+	"strings"
 
 	ce "github.com/cloudevents/sdk-go/v2"
 	cetypes "github.com/cloudevents/sdk-go/v2/types"
 	"github.com/openshift-online/maestro/pkg/api"
 	"github.com/openshift-online/maestro/pkg/constants"
 	"github.com/openshift-online/maestro/pkg/services"
+	"github.com/stolostron/cloudevents-conductor/pkg/tracing"
 	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -21,10 +26,15 @@ import (
 
 var _ server.Service = &DBWorkService{}
 
+// listPageSize is the number of resources that are loaded from the database at once when listing the
+// resources of a cluster.
+const listPageSize int64 = 500
+
 // DBWorkService implements the server.Service interface for handling work resources.
 type DBWorkService struct {
 	resourceService    services.ResourceService
 	statusEventService services.StatusEventService
+	statusCoalescer    *statusCoalescer
 }
 
 func NewDBWorkService(resourceService services.ResourceService,
@@ -35,6 +45,13 @@ func NewDBWorkService(resourceService services.ResourceService,
 	}
 }
 
+// WithStatusCoalescing writes the first status update of a resource at once and coalesces the status updates in
+// the window of the options after it, only the latest status of the window is written to the database.
+func (s *DBWorkService) WithStatusCoalescing(options *StatusCoalescingOptions) *DBWorkService {
+	s.statusCoalescer = newStatusCoalescer(options)
+	return s
+}
+
 // Get the cloudEvent based on resourceID from the service
 func (s *DBWorkService) Get(ctx context.Context, resourceID string) (*ce.Event, error) {
 
@@ -52,25 +69,94 @@ func (s *DBWorkService) Get(ctx context.Context, resourceID string) (*ce.Event,
 
 // List the cloudEvent from the service
 func (s *DBWorkService) List(listOpts types.ListOptions) ([]*ce.Event, error) {
-	resources, err := s.resourceService.List(listOpts)
-	if err != nil {
+	evts := []*ce.Event{}
+	if err := s.ListPages(context.TODO(), listOpts, func(page []*ce.Event) error {
+		evts = append(evts, page...)
+		return nil
+	}); err != nil {
 		return nil, err
 	}
 
-	evts := []*ce.Event{}
-	for _, res := range resources {
-		evt, err := encodeResourceSpec(res)
+	return evts, nil
+}
+
+// ListPages lists the cloudEvent of the cluster from the database page by page, each page holds at most
+// listPageSize resources and is passed to fn before the next page is loaded. The resources are walked in
+// the order of their IDs, so a resource deleted in the middle of the walk does not shift the later pages.
+func (s *DBWorkService) ListPages(ctx context.Context, listOpts types.ListOptions, fn func(evts []*ce.Event) error) error {
+	return s.ListChangedPages(ctx, listOpts, nil, fn)
+}
+
+// ListChangedPages is the same as ListPages, except that the resources for which changed returns false are
+// skipped before they are encoded, the pages that have no changed resources are not passed to fn.
+// All of the resources are listed if changed is nil.
+func (s *DBWorkService) ListChangedPages(ctx context.Context, listOpts types.ListOptions,
+	changed func(resourceID string, resourceVersion int64, deleting bool) bool, fn func(evts []*ce.Event) error) error {
+	lastID := ""
+	for {
+		search, err := resourcesSearch(listOpts.ClusterName, lastID)
 		if err != nil {
-			return nil, kubeerrors.NewInternalError(err)
+			return err
+		}
+		listArgs := services.NewListArguments(url.Values{})
+		listArgs.Page = 1
+		listArgs.Size = listPageSize
+		listArgs.OrderBy = []string{"id asc"}
+		listArgs.Search = search
+
+		resources := []api.Resource{}
+		if _, svcErr := s.resourceService.ListWithArgs(ctx, "", listArgs, &resources); svcErr != nil {
+			return kubeerrors.NewInternalError(svcErr)
+		}
+		if len(resources) == 0 {
+			return nil
+		}
+
+		evts := make([]*ce.Event, 0, len(resources))
+		for i := range resources {
+			res := &resources[i]
+			if changed != nil && !changed(res.ID, int64(res.Version), !res.GetDeletionTimestamp().IsZero()) {
+				continue
+			}
+
+			evt, err := encodeResourceSpec(res)
+			if err != nil {
+				return kubeerrors.NewInternalError(err)
+			}
+			evts = append(evts, evt)
 		}
-		evts = append(evts, evt)
+		if len(evts) > 0 {
+			if err := fn(evts); err != nil {
+				return err
+			}
+		}
+
+		if int64(len(resources)) < listPageSize {
+			return nil
+		}
+		lastID = resources[len(resources)-1].ID
 	}
+}
 
-	return evts, nil
+// resourcesSearch returns the search of the resources of the cluster whose IDs are after lastID, all of the
+// resources of the cluster are searched if lastID is empty. The cluster name is sent by the agent, so it is
+// rejected if it would end the quoted value of the search.
+func resourcesSearch(clusterName, lastID string) (string, error) {
+	if strings.ContainsRune(clusterName, '\'') {
+		return "", kubeerrors.NewBadRequest(fmt.Sprintf("invalid cluster name %q", clusterName))
+	}
+	search := fmt.Sprintf("consumer_name = '%s'", clusterName)
+	if len(lastID) > 0 {
+		search = fmt.Sprintf("%s and id > '%s'", search, lastID)
+	}
+	return search, nil
 }
 
 // HandleStatusUpdate processes the resource status update from the agent.
-func (s *DBWorkService) HandleStatusUpdate(ctx context.Context, evt *ce.Event) error {
+func (s *DBWorkService) HandleStatusUpdate(ctx context.Context, evt *ce.Event) (err error) {
+	ctx, span := tracing.Start(ctx, "DBWorkService.HandleStatusUpdate", tracing.EventAttributes(evt)...)
+	defer func() { tracing.End(span, err) }()
+
 	// decode the cloudevent data as resource with status
 	resource, err := decodeResourceStatus(evt)
 	if err != nil {
@@ -78,11 +164,19 @@ func (s *DBWorkService) HandleStatusUpdate(ctx context.Context, evt *ce.Event) e
 	}
 
 	// handle the resource status update according status update type
-	if err := handleStatusUpdate(ctx, resource, s.resourceService, s.statusEventService); err != nil {
-		return fmt.Errorf("failed to handle resource status update %s: %s", resource.ID, err.Error())
+	handle := func(ctx context.Context) error {
+		if err := handleStatusUpdate(ctx, resource, s.resourceService, s.statusEventService); err != nil {
+			return fmt.Errorf("failed to handle resource status update %s: %w", resource.ID, err)
+		}
+		return nil
+	}
+	if s.statusCoalescer == nil {
+		return handle(ctx)
 	}
 
-	return nil
+	// the status updates are coalesced per cluster, so a status update that is denied for the resource does not
+	// replace the pending status from the cluster of the resource
+	return s.statusCoalescer.Handle(ctx, resource.ConsumerName+"/"+resource.ID, newStatusDigest(resource.Version, evt), handle)
 }
 
 // RegisterHandler register the handler to the service.
@@ -92,7 +186,8 @@ func (s *DBWorkService) RegisterHandler(handler server.EventHandler) {
 // handleStatusUpdate processes the resource status update from the agent.
 // The resource argument contains the updated status.
 // The function performs the following steps:
-// 1. Verifies if the resource is still in the Maestro server and checks if the consumer name matches.
+// 1. Verifies if the resource is still in the Maestro server and checks if the consumer name matches, a forbidden
+// error is returned if the resource does not belong to the cluster of the status update.
 // 2. Retrieves the resource from Maestro and fills back the work metadata from the spec event to the status event.
 // 3. Checks if the resource has been deleted from the agent. If so, creates a status event and deletes the resource from Maestro;
 // otherwise, updates the resource status and creates a status event.
@@ -109,8 +204,10 @@ func handleStatusUpdate(ctx context.Context, resource *api.Resource, resourceSer
 		return fmt.Errorf("failed to get resource %s, %s", resource.ID, svcErr.Error())
 	}
 
+	// the agent is authorized for the cluster of the status update, the resource must belong to it
 	if found.ConsumerName != resource.ConsumerName {
-		return fmt.Errorf("unmatched consumer name %s for resource %s", resource.ConsumerName, resource.ID)
+		return kubeerrors.NewForbidden(schema.GroupResource{Resource: "manifestbundles"}, resource.ID,
+			fmt.Errorf("the resource does not belong to the cluster %s", resource.ConsumerName))
 	}
 
 	// set the resource source and type back for broadcast
@@ -187,6 +284,13 @@ func handleStatusUpdate(ctx context.Context, resource *api.Resource, resourceSer
 	return nil
 }
 
+// newStatusDigest returns the digest of the status cloudevent with the resource version and the hash of its
+// data, the ID and the time of the cloudevent are not included.
+func newStatusDigest(resourceVersion int32, evt *ce.Event) statusDigest {
+	hash := sha256.Sum256(evt.Data())
+	return statusDigest{version: int64(resourceVersion), hash: hex.EncodeToString(hash[:])}
+}
+
 // decodeResourceStatus translates a CloudEvent into a resource containing the status JSON map.
 func decodeResourceStatus(evt *ce.Event) (*api.Resource, error) {
 	evtExtensions := evt.Context.GetExtensions()
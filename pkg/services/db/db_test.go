@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestResourcesSearch(t *testing.T) {
+	cases := []struct {
+		name        string
+		clusterName string
+		lastID      string
+		expected    string
+		expectedErr bool
+	}{
+		{
+			name:        "first page",
+			clusterName: "cluster1",
+			expected:    "consumer_name = 'cluster1'",
+		},
+		{
+			name:        "next page",
+			clusterName: "cluster1",
+			lastID:      "a7b5d2c1-0b3e-4f8a-9c6d-1e2f3a4b5c6d",
+			expected:    "consumer_name = 'cluster1' and id > 'a7b5d2c1-0b3e-4f8a-9c6d-1e2f3a4b5c6d'",
+		},
+		{
+			name:        "quoted cluster name",
+			clusterName: "cluster1' or consumer_name != '",
+			expectedErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			search, err := resourcesSearch(c.clusterName, c.lastID)
+			if c.expectedErr {
+				if !kubeerrors.IsBadRequest(err) {
+					t.Errorf("expected a bad request error, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if search != c.expected {
+				t.Errorf("expected search %q, but got %q", c.expected, search)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package event
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/openshift-online/maestro/pkg/dao"
 	"github.com/openshift-online/maestro/pkg/db"
 	"github.com/openshift-online/maestro/pkg/services"
@@ -10,3 +14,64 @@ import (
 func NewEventService(sessionFactory db.SessionFactory) services.EventService {
 	return services.NewEventService(dao.NewEventDao(&sessionFactory))
 }
+
+// purgeReconciledEventsSQL deletes a batch of the events that were reconciled before the given time.
+const purgeReconciledEventsSQL = `DELETE FROM events WHERE id IN (
+	SELECT id FROM events WHERE reconciled_date IS NOT NULL AND reconciled_date < ? ORDER BY reconciled_date LIMIT ?)`
+
+// countReconciledEventsSQL counts the events that were reconciled before the given time.
+const countReconciledEventsSQL = `SELECT count(*) FROM events WHERE reconciled_date IS NOT NULL AND reconciled_date < ?`
+
+// ReconciledEventsPurger deletes the reconciled events from the database in batches.
+type ReconciledEventsPurger struct {
+	sessionFactory db.SessionFactory
+}
+
+// NewReconciledEventsPurger creates a new ReconciledEventsPurger with the provided session factory to interact with
+// the database.
+func NewReconciledEventsPurger(sessionFactory db.SessionFactory) *ReconciledEventsPurger {
+	return &ReconciledEventsPurger{sessionFactory: sessionFactory}
+}
+
+// PurgeReconciledEvents deletes the events that were reconciled before the given time, each transaction deletes
+// at most batchSize events. It returns the number of the deleted events.
+func (p *ReconciledEventsPurger) PurgeReconciledEvents(ctx context.Context, reconciledBefore time.Time, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("the batch size must be positive, but got %d", batchSize)
+	}
+
+	var purged int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return purged, err
+		}
+
+		result := p.sessionFactory.New(ctx).Exec(purgeReconciledEventsSQL, reconciledBefore, batchSize)
+		if result.Error != nil {
+			return purged, fmt.Errorf("failed to purge reconciled events: %w", result.Error)
+		}
+
+		purged += result.RowsAffected
+		if result.RowsAffected < int64(batchSize) {
+			return purged, nil
+		}
+	}
+}
+
+// CountReconciledEvents returns the number of the events that were reconciled before the given time, they are
+// deleted by PurgeReconciledEvents.
+func (p *ReconciledEventsPurger) CountReconciledEvents(ctx context.Context, reconciledBefore time.Time) (int64, error) {
+	var count int64
+	if err := p.sessionFactory.New(ctx).Raw(countReconciledEventsSQL, reconciledBefore).Scan(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count reconciled events: %w", err)
+	}
+	return count, nil
+}
+
+// NotifyEvent sends the event ID to the listeners of the channel, so the event is handled again.
+func NotifyEvent(ctx context.Context, sessionFactory db.SessionFactory, channel, eventID string) error {
+	if err := sessionFactory.New(ctx).Exec("SELECT pg_notify(?, ?)", channel, eventID).Error; err != nil {
+		return fmt.Errorf("failed to notify event %s on channel %s: %w", eventID, channel, err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/openshift-online/maestro/pkg/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PoisonedEvent is an event that is failed to be handled after the max retries, it is kept out of the spec
+// controller queue until it is re-driven by an operator.
+type PoisonedEvent struct {
+	EventID    string `gorm:"primaryKey"`
+	Source     string
+	SourceID   string
+	EventType  string
+	Attempts   int
+	LastError  string
+	PoisonedAt time.Time
+}
+
+// TableName returns the table of the poisoned events, it is owned by the conductor instead of the maestro.
+func (PoisonedEvent) TableName() string {
+	return "conductor_poisoned_events"
+}
+
+// PoisonedEventStore persists the poisoned events in the database.
+type PoisonedEventStore struct {
+	sessionFactory db.SessionFactory
+}
+
+// NewPoisonedEventStore creates a new PoisonedEventStore with the provided session factory to interact with the database.
+func NewPoisonedEventStore(sessionFactory db.SessionFactory) *PoisonedEventStore {
+	return &PoisonedEventStore{sessionFactory: sessionFactory}
+}
+
+// Migrate creates the poisoned events table if it does not exist.
+func (s *PoisonedEventStore) Migrate(ctx context.Context) error {
+	if err := s.sessionFactory.New(ctx).AutoMigrate(&PoisonedEvent{}); err != nil {
+		return fmt.Errorf("failed to migrate the poisoned events table: %w", err)
+	}
+	return nil
+}
+
+// Poison creates the poisoned event, or updates it if the event was poisoned before.
+func (s *PoisonedEventStore) Poison(ctx context.Context, event *PoisonedEvent) error {
+	if err := s.sessionFactory.New(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(event).Error; err != nil {
+		return fmt.Errorf("failed to poison event %s: %w", event.EventID, err)
+	}
+	return nil
+}
+
+// Get returns the poisoned event by the event ID, it returns nil if the event is not poisoned.
+func (s *PoisonedEventStore) Get(ctx context.Context, eventID string) (*PoisonedEvent, error) {
+	event := &PoisonedEvent{}
+	if err := s.sessionFactory.New(ctx).Take(event, "event_id = ?", eventID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get poisoned event %s: %w", eventID, err)
+	}
+	return event, nil
+}
+
+// List returns all of the poisoned events ordered by the poisoned time.
+func (s *PoisonedEventStore) List(ctx context.Context) ([]*PoisonedEvent, error) {
+	events := []*PoisonedEvent{}
+	if err := s.sessionFactory.New(ctx).Order("poisoned_at").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to list poisoned events: %w", err)
+	}
+	return events, nil
+}
+
+// Delete deletes the poisoned event, so the event can be handled again.
+func (s *PoisonedEventStore) Delete(ctx context.Context, eventID string) error {
+	if err := s.sessionFactory.New(ctx).Delete(&PoisonedEvent{}, "event_id = ?", eventID).Error; err != nil {
+		return fmt.Errorf("failed to delete poisoned event %s: %w", eventID, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// listenerPingInterval is how often the connection of a listener is checked when no notification is received,
+// so a broken connection is found and re-established without waiting for a notification.
+const listenerPingInterval = 10 * time.Second
+
+// ListenerOptions defines the listener of the database events that are notified by the maestro.
+type ListenerOptions struct {
+	// Channel is the pg channel that the events are notified on.
+	Channel string `json:"channel,omitempty" yaml:"channel,omitempty"`
+
+	// MinReconnectInterval is how long the listener waits before it reconnects the database the first time
+	// after the connection is broken, the interval is doubled for each failed attempt.
+	MinReconnectInterval time.Duration `json:"min_reconnect_interval,omitempty" yaml:"min_reconnect_interval,omitempty"`
+
+	// MaxReconnectInterval is the max interval between the attempts to reconnect the database.
+	MaxReconnectInterval time.Duration `json:"max_reconnect_interval,omitempty" yaml:"max_reconnect_interval,omitempty"`
+}
+
+func NewListenerOptions() *ListenerOptions {
+	return &ListenerOptions{
+		Channel:              "events",
+		MinReconnectInterval: time.Second,
+		MaxReconnectInterval: time.Minute,
+	}
+}
+
+// Validate validates the options, the errors are reported with the given field path.
+func (o *ListenerOptions) Validate(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if o.Channel == "" {
+		errs = append(errs, field.Required(fldPath.Child("channel"), ""))
+	}
+	if o.MinReconnectInterval <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("min_reconnect_interval"), o.MinReconnectInterval.String(),
+			"must be greater than 0"))
+	}
+	if o.MaxReconnectInterval < o.MinReconnectInterval {
+		errs = append(errs, field.Invalid(fldPath.Child("max_reconnect_interval"), o.MaxReconnectInterval.String(),
+			fmt.Sprintf("must not be less than min_reconnect_interval (%s)", o.MinReconnectInterval)))
+	}
+	return errs
+}
+
+// supervisedListener listens the channel of the options with a pq listener, the pq listener reconnects the
+// database with a backoff when the connection is broken and listens the channel again.
+type supervisedListener struct {
+	options  *ListenerOptions
+	callback func(id string)
+	// connected is called after the channel is listened, including after the connection is re-established,
+	// the notifications that are sent while the listener is not connected are missed.
+	connected func()
+}
+
+// run listens the channel with the pq listener until the context is done or the pq listener is closed.
+func (l *supervisedListener) run(ctx context.Context, listener *pq.Listener) {
+	// the channel is listened again after the listener is failed to listen it, e.g. the database is not ready
+	backoff := wait.Backoff{
+		Duration: l.options.MinReconnectInterval,
+		Cap:      l.options.MaxReconnectInterval,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    math.MaxInt32,
+	}
+	if err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+		if err := listener.Listen(l.options.Channel); err != nil && !errors.Is(err, pq.ErrChannelAlreadyOpen) {
+			klog.Errorf("failed to listen channel %s, retrying: %v", l.options.Channel, err)
+			return false, nil
+		}
+		return true, nil
+	}); err != nil {
+		return
+	}
+
+	klog.Infof("listening the events on channel %s", l.options.Channel)
+	l.notifyConnected()
+	l.dispatch(ctx, listener.NotificationChannel(), listener.Ping)
+}
+
+// dispatch calls the callback with the payloads of the notifications until the context is done or the
+// notifications channel is closed. A nil notification is sent by the pq listener after the connection is
+// re-established.
+func (l *supervisedListener) dispatch(ctx context.Context, notifications <-chan *pq.Notification, ping func() error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n, ok := <-notifications:
+			if !ok {
+				return
+			}
+			if n == nil {
+				klog.Infof("listener of channel %s is reconnected", l.options.Channel)
+				l.notifyConnected()
+				continue
+			}
+			l.callback(n.Extra)
+		case <-time.After(listenerPingInterval):
+			// the broken connection is closed by the ping and re-established by the pq listener
+			go func() {
+				if err := ping(); err != nil {
+					klog.Errorf("failed to ping listener of channel %s: %v", l.options.Channel, err)
+				}
+			}()
+		}
+	}
+}
+
+func (l *supervisedListener) notifyConnected() {
+	if l.connected != nil {
+		l.connected()
+	}
+}
+
+// logEvent logs the connection events of the pq listener.
+func (l *supervisedListener) logEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected:
+		klog.Errorf("listener of channel %s is disconnected: %v", l.options.Channel, err)
+	case pq.ListenerEventConnectionAttemptFailed:
+		klog.Errorf("failed to connect listener of channel %s: %v", l.options.Channel, err)
+	}
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestListenerOptionsValidate(t *testing.T) {
+	if errs := NewListenerOptions().Validate(field.NewPath("listener")); len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	errs := (&ListenerOptions{MaxReconnectInterval: -time.Second}).Validate(field.NewPath("listener"))
+	fields := []string{}
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	expected := []string{"listener.channel", "listener.min_reconnect_interval", "listener.max_reconnect_interval"}
+	if len(fields) != len(expected) {
+		t.Fatalf("expected errors of %v, but got %v", expected, fields)
+	}
+	for i := range expected {
+		if fields[i] != expected[i] {
+			t.Errorf("expected errors of %v, but got %v", expected, fields)
+		}
+	}
+}
+
+func TestSupervisedListenerDispatch(t *testing.T) {
+	ids := []string{}
+	connected := 0
+	l := &supervisedListener{
+		options:   NewListenerOptions(),
+		callback:  func(id string) { ids = append(ids, id) },
+		connected: func() { connected++ },
+	}
+
+	notifications := make(chan *pq.Notification, 3)
+	notifications <- &pq.Notification{Extra: "1"}
+	// the pq listener sends a nil notification after the connection is re-established
+	notifications <- nil
+	notifications <- &pq.Notification{Extra: "2"}
+	close(notifications)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.dispatch(context.Background(), notifications, func() error { return nil })
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the dispatch is not returned after the notifications channel is closed")
+	}
+
+	if len(ids) != 2 || ids[0] != "1" || ids[1] != "2" {
+		t.Errorf("expected events [1 2], but got %v", ids)
+	}
+	if connected != 1 {
+		t.Errorf("expected 1 reconnect, but got %d", connected)
+	}
+}
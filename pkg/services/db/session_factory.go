@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/lib/pq"
+	dbconfig "github.com/openshift-online/maestro/pkg/config"
+	maestrodb "github.com/openshift-online/maestro/pkg/db"
+	"gorm.io/gorm"
+	"k8s.io/klog/v2"
+)
+
+var _ maestrodb.SessionFactory = &ReloadableSessionFactory{}
+
+// ReloadableSessionFactory implements the maestro db.SessionFactory by delegating to a session factory that
+// is rebuilt when the database configuration is changed, e.g. the database password is rotated. The
+// services hold the ReloadableSessionFactory, so they use the rebuilt session factory without being
+// recreated.
+type ReloadableSessionFactory struct {
+	sync.RWMutex
+	config     *dbconfig.DatabaseConfig
+	factory    maestrodb.SessionFactory
+	newFactory func(config *dbconfig.DatabaseConfig) maestrodb.SessionFactory
+	listeners  []*reloadableListener
+}
+
+// reloadableListener is a listener started by the ReloadableSessionFactory, it is restarted with the
+// rebuilt database configuration.
+type reloadableListener struct {
+	supervisedListener
+	ctx      context.Context
+	cancel   context.CancelFunc
+	listener *pq.Listener
+}
+
+// NewReloadableSessionFactory creates a ReloadableSessionFactory with the initial database configuration,
+// the newFactory is used to build the session factory from a database configuration.
+func NewReloadableSessionFactory(config *dbconfig.DatabaseConfig,
+	newFactory func(config *dbconfig.DatabaseConfig) maestrodb.SessionFactory) *ReloadableSessionFactory {
+	return &ReloadableSessionFactory{
+		config:     config,
+		factory:    newFactory(config),
+		newFactory: newFactory,
+	}
+}
+
+// Reload rebuilds the session factory if the database configuration is changed. The current session factory
+// is kept if the rebuilt one cannot connect to the database, otherwise the listeners are restarted with the
+// changed configuration and the current one is closed after the in-flight queries are finished.
+func (f *ReloadableSessionFactory) Reload(ctx context.Context, config *dbconfig.DatabaseConfig) (bool, error) {
+	f.RLock()
+	changed := !reflect.DeepEqual(f.config, config)
+	f.RUnlock()
+	if !changed {
+		return false, nil
+	}
+
+	factory := f.newFactory(config)
+	if err := factory.CheckConnection(); err != nil {
+		if closeErr := factory.Close(); closeErr != nil {
+			klog.Errorf("failed to close session factory: %v", closeErr)
+		}
+		return false, fmt.Errorf("failed to connect database with the changed config: %w", err)
+	}
+
+	f.Lock()
+	if reflect.DeepEqual(f.config, config) {
+		// the factory is already rebuilt with the same config by a concurrent reload
+		f.Unlock()
+		if err := factory.Close(); err != nil {
+			klog.Errorf("failed to close session factory: %v", err)
+		}
+		return false, nil
+	}
+	staled := f.factory
+	f.config = config
+	f.factory = factory
+	restarts := []*reloadableListener{}
+	for _, l := range f.listeners {
+		if l.cancel == nil {
+			// the listener is being started with the changed config
+			continue
+		}
+		l.stop()
+		restarts = append(restarts, l)
+	}
+	f.Unlock()
+
+	for _, l := range restarts {
+		f.startListener(l)
+	}
+
+	klog.FromContext(ctx).Info("database session factory is rebuilt with the changed config")
+	return true, staled.Close()
+}
+
+func (f *ReloadableSessionFactory) current() maestrodb.SessionFactory {
+	f.RLock()
+	defer f.RUnlock()
+	return f.factory
+}
+
+func (f *ReloadableSessionFactory) Init(config interface{}) {
+	f.current().Init(config)
+}
+
+func (f *ReloadableSessionFactory) DirectDB() *sql.DB {
+	return f.current().DirectDB()
+}
+
+func (f *ReloadableSessionFactory) New(ctx context.Context) *gorm.DB {
+	return f.current().New(ctx)
+}
+
+func (f *ReloadableSessionFactory) CheckConnection() error {
+	return f.current().CheckConnection()
+}
+
+// CheckConnectionContext checks the database connection with a query that is cancelled when the context is done.
+func (f *ReloadableSessionFactory) CheckConnectionContext(ctx context.Context) error {
+	return f.current().New(ctx).Exec("SELECT 1").Error
+}
+
+func (f *ReloadableSessionFactory) Close() error {
+	f.Lock()
+	defer f.Unlock()
+	for _, l := range f.listeners {
+		if l.cancel != nil {
+			l.stop()
+		}
+	}
+	return f.factory.Close()
+}
+
+func (f *ReloadableSessionFactory) ResetDB() {
+	f.current().ResetDB()
+}
+
+// NewListener starts a listener of the channel with the default listener options, see Listen.
+func (f *ReloadableSessionFactory) NewListener(ctx context.Context, channel string, callback func(id string)) *pq.Listener {
+	options := NewListenerOptions()
+	options.Channel = channel
+	return f.Listen(ctx, options, callback, nil)
+}
+
+// Listen starts a listener of the channel of the options with the current database configuration, the callback
+// is called with the payload of each notification. The listener reconnects the database with a backoff when the
+// connection is broken, and it is restarted when the database configuration is changed. The connected func is
+// called every time after the channel is listened, so the events that are missed before can be handled.
+func (f *ReloadableSessionFactory) Listen(ctx context.Context, options *ListenerOptions, callback func(id string),
+	connected func()) *pq.Listener {
+	l := &reloadableListener{
+		supervisedListener: supervisedListener{options: options, callback: callback, connected: connected},
+		ctx:                ctx,
+	}
+	f.Lock()
+	f.listeners = append(f.listeners, l)
+	f.Unlock()
+
+	return f.startListener(l)
+}
+
+func (f *ReloadableSessionFactory) startListener(l *reloadableListener) *pq.Listener {
+	f.Lock()
+	defer f.Unlock()
+	ctx, cancel := context.WithCancel(l.ctx)
+	l.cancel = cancel
+	l.listener = pq.NewListener(f.config.ConnectionString(f.config.SSLMode != "disable"),
+		l.options.MinReconnectInterval, l.options.MaxReconnectInterval, l.logEvent)
+
+	go l.run(ctx, l.listener)
+	return l.listener
+}
+
+// PingListeners checks the connections of the started listeners are alive, an error is returned if a listener
+// is not pinged before the context is done. The listeners are pinged without holding the lock, so a hanging
+// ping does not block the reload of the session factory.
+func (f *ReloadableSessionFactory) PingListeners(ctx context.Context) error {
+	f.RLock()
+	channels := make([]string, 0, len(f.listeners))
+	listeners := make([]*pq.Listener, 0, len(f.listeners))
+	for _, l := range f.listeners {
+		channels = append(channels, l.options.Channel)
+		listeners = append(listeners, l.listener)
+	}
+	f.RUnlock()
+
+	for i, listener := range listeners {
+		if listener == nil {
+			return fmt.Errorf("listener of channel %s is not started", channels[i])
+		}
+		if err := pingListener(ctx, listener); err != nil {
+			return fmt.Errorf("listener of channel %s is not alive: %w", channels[i], err)
+		}
+	}
+	return nil
+}
+
+// pingListener pings the listener until the context is done, the pq listener cannot ping with a context.
+func pingListener(ctx context.Context, listener *pq.Listener) error {
+	pinged := make(chan error, 1)
+	go func() {
+		pinged <- listener.Ping()
+	}()
+
+	select {
+	case err := <-pinged:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *reloadableListener) stop() {
+	l.cancel()
+	if l.listener != nil {
+		if err := l.listener.Close(); err != nil {
+			klog.V(4).Infof("failed to close listener of channel %s: %v", l.options.Channel, err)
+		}
+		l.listener = nil
+	}
+}
@@ -0,0 +1,30 @@
+package streamowner
+
+import (
+	k8smetrics "k8s.io/component-base/metrics"
+)
+
+// subsystem used to define the metrics of the stream ownership
+const streamOwnershipMetricsSubsystem = "stream_ownership"
+
+// Names of the stream ownership metrics:
+const (
+	ownedClustersMetric = "owned_clusters"
+)
+
+// streamOwnedClusters is a gauge metric that tracks the number of the clusters whose manifest bundle streams are
+// held by this instance.
+var streamOwnedClusters = k8smetrics.NewGauge(&k8smetrics.GaugeOpts{
+	Subsystem:      streamOwnershipMetricsSubsystem,
+	Name:           ownedClustersMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Current number of the clusters whose manifest bundle streams are held by this conductor instance.",
+})
+
+// StreamOwnershipMetrics returns the metrics of the stream ownership, they measure the clusters owned by this
+// instance.
+func StreamOwnershipMetrics() []k8smetrics.Registerable {
+	return []k8smetrics.Registerable{
+		streamOwnedClusters,
+	}
+}
@@ -0,0 +1,268 @@
+package streamowner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/openshift-online/maestro/pkg/db"
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	pbv1 "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/options/grpc/protobuf/v1"
+)
+
+// claimSQL records the instance as the owner of the cluster, the instance of the latest stream of the cluster
+// takes over the cluster from the previous owner.
+const claimSQL = `INSERT INTO conductor_stream_owners (cluster_name, instance_id, renewed_at) VALUES (?, ?, now())
+	ON CONFLICT (cluster_name) DO UPDATE SET instance_id = EXCLUDED.instance_id, renewed_at = EXCLUDED.renewed_at`
+
+// renewSQL renews the clusters of the instance, the clusters that are taken over by another live instance
+// are kept, and the expired or missing clusters are claimed again.
+const renewSQL = `INSERT INTO conductor_stream_owners (cluster_name, instance_id, renewed_at)
+	SELECT cluster_name, ?, now() FROM unnest(?::text[]) AS cluster_name
+	ON CONFLICT (cluster_name) DO UPDATE SET instance_id = EXCLUDED.instance_id, renewed_at = EXCLUDED.renewed_at
+	WHERE conductor_stream_owners.instance_id = EXCLUDED.instance_id OR
+		conductor_stream_owners.renewed_at < now() - make_interval(secs => ?)`
+
+// releaseSQL deletes the cluster if it is still owned by the instance.
+const releaseSQL = `DELETE FROM conductor_stream_owners WHERE cluster_name = ? AND instance_id = ?`
+
+// releaseAllSQL deletes all of the clusters of the instance.
+const releaseAllSQL = `DELETE FROM conductor_stream_owners WHERE instance_id = ?`
+
+// othersSQL returns the clusters that are owned by the other live instances.
+const othersSQL = `SELECT cluster_name FROM conductor_stream_owners
+	WHERE instance_id <> ? AND renewed_at >= now() - make_interval(secs => ?)`
+
+// eventClusterSQL returns the cluster of the resource that the event is for.
+const eventClusterSQL = `SELECT r.consumer_name FROM events e JOIN resources r ON r.id = e.source_id WHERE e.id = ?`
+
+// Options defines how the conductor instances record the ownership of the clusters whose agent streams they hold.
+type Options struct {
+	// HeartbeatInterval is how often an instance renews the ownership of its clusters.
+	HeartbeatInterval time.Duration `json:"heartbeat_interval,omitempty" yaml:"heartbeat_interval,omitempty"`
+
+	// Expiration is how long the ownership of a cluster is kept after it is renewed, the events of the cluster
+	// are handled by any instance after the ownership is expired.
+	Expiration time.Duration `json:"expiration,omitempty" yaml:"expiration,omitempty"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		HeartbeatInterval: 10 * time.Second,
+		Expiration:        30 * time.Second,
+	}
+}
+
+// Validate validates the options, the errors are reported with the given field path.
+func (o *Options) Validate(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if o.HeartbeatInterval <= 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("heartbeat_interval"), o.HeartbeatInterval.String(),
+			"must be greater than 0"))
+	}
+	if o.Expiration <= o.HeartbeatInterval {
+		errs = append(errs, field.Invalid(fldPath.Child("expiration"), o.Expiration.String(),
+			fmt.Sprintf("must be greater than heartbeat_interval (%s)", o.HeartbeatInterval)))
+	}
+	return errs
+}
+
+// StreamOwner is a cluster whose manifest bundle stream is held by a conductor instance.
+type StreamOwner struct {
+	ClusterName string `gorm:"primaryKey"`
+	InstanceID  string `gorm:"index;not null"`
+	RenewedAt   time.Time
+}
+
+// TableName returns the table of the stream owners, it is owned by the conductor instead of the maestro.
+func (StreamOwner) TableName() string {
+	return "conductor_stream_owners"
+}
+
+// Registry records the clusters whose agents subscribe to the manifest bundles through this conductor instance,
+// so the database events of a cluster are only handled by the instance that can deliver them to its agent.
+type Registry struct {
+	sync.Mutex
+	sessionFactory db.SessionFactory
+	instanceID     string
+	options        *Options
+	// streams is the number of the streams of each cluster on this instance
+	streams map[string]int
+	// others are the clusters that are owned by the other live instances, they are loaded on every heartbeat
+	others sets.Set[string]
+}
+
+// NewRegistry creates a Registry of the instance.
+func NewRegistry(sessionFactory db.SessionFactory, instanceID string, options *Options) *Registry {
+	return &Registry{
+		sessionFactory: sessionFactory,
+		instanceID:     instanceID,
+		options:        options,
+		streams:        map[string]int{},
+		others:         sets.New[string](),
+	}
+}
+
+// Migrate creates the stream owners table if it does not exist.
+func (r *Registry) Migrate(ctx context.Context) error {
+	if err := r.sessionFactory.New(ctx).AutoMigrate(&StreamOwner{}); err != nil {
+		return fmt.Errorf("failed to migrate the stream owners table: %w", err)
+	}
+	return nil
+}
+
+// Run renews the ownership of the clusters and loads the clusters of the other instances periodically until the
+// context is done.
+func (r *Registry) Run(ctx context.Context) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		r.renew(ctx)
+		r.loadOthers(ctx)
+	}, r.options.HeartbeatInterval)
+}
+
+// Close releases all of the clusters of this instance, so their events are handled by the other instances
+// before the ownership is expired.
+func (r *Registry) Close(ctx context.Context) error {
+	if err := r.sessionFactory.New(ctx).Exec(releaseAllSQL, r.instanceID).Error; err != nil {
+		return fmt.Errorf("failed to release the clusters of instance %s: %w", r.instanceID, err)
+	}
+	return nil
+}
+
+// Acquire records this instance as the owner of the cluster when a stream of the cluster is opened.
+func (r *Registry) Acquire(ctx context.Context, clusterName string) error {
+	r.Lock()
+	r.streams[clusterName]++
+	streamOwnedClusters.Set(float64(len(r.streams)))
+	r.Unlock()
+
+	if err := r.sessionFactory.New(ctx).Exec(claimSQL, clusterName, r.instanceID).Error; err != nil {
+		return fmt.Errorf("failed to claim cluster %s: %w", clusterName, err)
+	}
+	return nil
+}
+
+// Release releases the cluster after the last stream of the cluster on this instance is closed.
+func (r *Registry) Release(ctx context.Context, clusterName string) error {
+	r.Lock()
+	r.streams[clusterName]--
+	if r.streams[clusterName] > 0 {
+		r.Unlock()
+		return nil
+	}
+	delete(r.streams, clusterName)
+	streamOwnedClusters.Set(float64(len(r.streams)))
+	r.Unlock()
+
+	if err := r.sessionFactory.New(ctx).Exec(releaseSQL, clusterName, r.instanceID).Error; err != nil {
+		return fmt.Errorf("failed to release cluster %s: %w", clusterName, err)
+	}
+	return nil
+}
+
+// OwnsEvent returns false if the cluster of the event is owned by another live instance. The events of the
+// clusters that are not owned by any instance are owned by all of the instances. The clusters of the other
+// instances are the ones of the last heartbeat, so the cluster of the event is not queried if the other instances
+// own no cluster. A cluster that is taken over by another instance since the last heartbeat is still owned by this
+// instance until the next heartbeat, its agent gets the resources with the resync request of the new stream.
+func (r *Registry) OwnsEvent(ctx context.Context, eventID string) (bool, error) {
+	r.Lock()
+	others := r.others
+	r.Unlock()
+	if others.Len() == 0 {
+		return true, nil
+	}
+
+	clusterNames := []string{}
+	if err := r.sessionFactory.New(ctx).Raw(eventClusterSQL, eventID).Scan(&clusterNames).Error; err != nil {
+		return false, fmt.Errorf("failed to get the cluster of event %s: %w", eventID, err)
+	}
+	return len(clusterNames) == 0 || !others.Has(clusterNames[0]), nil
+}
+
+func (r *Registry) renew(ctx context.Context) {
+	r.Lock()
+	clusterNames := make([]string, 0, len(r.streams))
+	for clusterName := range r.streams {
+		clusterNames = append(clusterNames, clusterName)
+	}
+	r.Unlock()
+
+	if len(clusterNames) == 0 {
+		return
+	}
+	if err := r.sessionFactory.New(ctx).Exec(renewSQL, r.instanceID, pq.Array(clusterNames),
+		r.options.Expiration.Seconds()).Error; err != nil {
+		klog.Errorf("Failed to renew the %d clusters of instance %s: %v", len(clusterNames), r.instanceID, err)
+	}
+}
+
+// loadOthers loads the clusters of the other live instances, the last loaded clusters are kept if they cannot be
+// loaded.
+func (r *Registry) loadOthers(ctx context.Context) {
+	clusterNames := []string{}
+	if err := r.sessionFactory.New(ctx).Raw(othersSQL, r.instanceID, r.options.Expiration.Seconds()).
+		Scan(&clusterNames).Error; err != nil {
+		klog.Errorf("Failed to load the clusters of the other instances: %v", err)
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.others = sets.New(clusterNames...)
+}
+
+// StreamServerInterceptor records the owner of the cluster for the manifest bundle subscriptions, the cluster
+// is released after its stream is closed.
+func (r *Registry) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if info.FullMethod != pbv1.CloudEventService_Subscribe_FullMethodName {
+			return handler(srv, ss)
+		}
+
+		stream := &ownedStream{ServerStream: ss, registry: r}
+		defer stream.release()
+		return handler(srv, stream)
+	}
+}
+
+// ownedStream acquires the cluster of the manifest bundle subscription after the request is received.
+type ownedStream struct {
+	grpc.ServerStream
+	registry    *Registry
+	clusterName string
+}
+
+func (s *ownedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	req, ok := m.(*pbv1.SubscriptionRequest)
+	if !ok || s.clusterName != "" || req.ClusterName == "" ||
+		req.DataType != payload.ManifestBundleEventDataType.String() {
+		return nil
+	}
+	// the stream is served even if the ownership is not recorded, it is recorded again by the next renew
+	s.clusterName = req.ClusterName
+	if err := s.registry.Acquire(s.Context(), s.clusterName); err != nil {
+		klog.Errorf("Failed to acquire the stream of cluster %s: %v", s.clusterName, err)
+	}
+	return nil
+}
+
+func (s *ownedStream) release() {
+	if s.clusterName == "" {
+		return
+	}
+	if err := s.registry.Release(context.Background(), s.clusterName); err != nil {
+		klog.Errorf("Failed to release the stream of cluster %s: %v", s.clusterName, err)
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	"github.com/openshift-online/maestro/pkg/api"
+	"github.com/openshift-online/maestro/pkg/constants"
+	"github.com/openshift-online/maestro/pkg/controllers"
+	"github.com/stolostron/cloudevents-conductor/pkg/controller"
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db"
+	"github.com/stolostron/cloudevents-conductor/pkg/tracing"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/server"
+)
+
+var _ Backend = &DBBackend{}
+
+// DBBackend is the Backend for the resources in the maestro database, its resource IDs are the resource uuids.
+type DBBackend struct {
+	SourceIDCodec
+	dbService      *db.DBWorkService
+	specController *controller.SpecControllerManager
+}
+
+func NewDBBackend(dbService *db.DBWorkService, specController *controller.SpecControllerManager) *DBBackend {
+	return &DBBackend{
+		SourceIDCodec:  NewSourceIDCodec(constants.DefaultSourceID),
+		dbService:      dbService,
+		specController: specController,
+	}
+}
+
+func (b *DBBackend) Get(ctx context.Context, resourceID string) (*ce.Event, error) {
+	return b.dbService.Get(ctx, resourceID)
+}
+
+func (b *DBBackend) List(listOpts types.ListOptions) ([]*ce.Event, error) {
+	return b.dbService.List(listOpts)
+}
+
+func (b *DBBackend) ListPages(ctx context.Context, listOpts types.ListOptions, fn func(evts []*ce.Event) error) error {
+	return b.dbService.ListPages(ctx, listOpts, fn)
+}
+
+// ListChangedPages compares the resource versions before the resources are encoded, so the unchanged
+// resources are not converted to cloudevents.
+func (b *DBBackend) ListChangedPages(ctx context.Context, listOpts types.ListOptions, versions *ResourceVersions,
+	fn func(evts []*ce.Event) error) error {
+	return b.dbService.ListChangedPages(ctx, listOpts, versions.Changed, fn)
+}
+
+func (b *DBBackend) HandleStatusUpdate(ctx context.Context, evt *ce.Event) error {
+	return b.dbService.HandleStatusUpdate(ctx, evt)
+}
+
+// RegisterHandler registers the event handler to the spec controller.
+func (b *DBBackend) RegisterHandler(handler server.EventHandler) {
+	b.specController.Add(&controllers.ControllerConfig{
+		Source:   "Resources",
+		Handlers: b.ControllerHandlerFuncs(handler),
+	})
+}
+
+// ControllerHandlerFuncs returns the ControllerHandlerFuncs for the DBBackend, each resource change is handled
+// in a span of the trace of the spec controller.
+func (b *DBBackend) ControllerHandlerFuncs(handler server.EventHandler) map[api.EventType][]controllers.ControllerHandlerFunc {
+	return map[api.EventType][]controllers.ControllerHandlerFunc{
+		api.CreateEventType: {tracedHandlerFunc("DBBackend.OnCreate", func(ctx context.Context, resourceID string) error {
+			return handler.OnCreate(ctx, payload.ManifestBundleEventDataType, resourceID)
+		})},
+		api.UpdateEventType: {tracedHandlerFunc("DBBackend.OnUpdate", func(ctx context.Context, resourceID string) error {
+			return handler.OnUpdate(ctx, payload.ManifestBundleEventDataType, resourceID)
+		})},
+		api.DeleteEventType: {tracedHandlerFunc("DBBackend.OnDelete", func(ctx context.Context, resourceID string) error {
+			return handler.OnDelete(ctx, payload.ManifestBundleEventDataType, resourceID)
+		})},
+	}
+}
+
+func tracedHandlerFunc(name string, fn controllers.ControllerHandlerFunc) controllers.ControllerHandlerFunc {
+	return func(ctx context.Context, resourceID string) error {
+		ctx, span := tracing.Start(ctx, name, tracing.ResourceIDKey.String(resourceID))
+		err := fn(ctx, resourceID)
+		tracing.End(span, err)
+		return err
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	workinformers "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
+	"open-cluster-management.io/ocm/pkg/server/services"
+	"open-cluster-management.io/ocm/pkg/server/services/work"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/server"
+)
+
+var _ Backend = &KubeBackend{}
+
+// KubeBackend is the Backend for the ManifestWorks on the hub, its resource IDs are `<namespace>/<name>`.
+type KubeBackend struct {
+	SourceIDCodec
+	workService  *work.WorkService
+	workInformer workinformers.ManifestWorkInformer
+}
+
+func NewKubeBackend(workService *work.WorkService, workInformer workinformers.ManifestWorkInformer) *KubeBackend {
+	return &KubeBackend{
+		SourceIDCodec: NewSourceIDCodec(services.CloudEventsSourceKube),
+		workService:   workService,
+		workInformer:  workInformer,
+	}
+}
+
+func (b *KubeBackend) Get(ctx context.Context, resourceID string) (*ce.Event, error) {
+	return b.workService.Get(ctx, resourceID)
+}
+
+func (b *KubeBackend) List(listOpts types.ListOptions) ([]*ce.Event, error) {
+	return b.workService.List(listOpts)
+}
+
+// ListPages passes the ManifestWorks of the cluster to fn as a single page, since they are already cached
+// by the informer.
+func (b *KubeBackend) ListPages(ctx context.Context, listOpts types.ListOptions, fn func(evts []*ce.Event) error) error {
+	evts, err := b.workService.List(listOpts)
+	if err != nil {
+		return err
+	}
+	return fn(evts)
+}
+
+func (b *KubeBackend) ListChangedPages(ctx context.Context, listOpts types.ListOptions, versions *ResourceVersions,
+	fn func(evts []*ce.Event) error) error {
+	return b.ListPages(ctx, listOpts, func(evts []*ce.Event) error {
+		if changed := versions.Filter(evts); len(changed) > 0 {
+			return fn(changed)
+		}
+		return nil
+	})
+}
+
+func (b *KubeBackend) HandleStatusUpdate(ctx context.Context, evt *ce.Event) error {
+	return b.workService.HandleStatusUpdate(ctx, evt)
+}
+
+// RegisterHandler registers the event handler to the ManifestWork informer.
+func (b *KubeBackend) RegisterHandler(handler server.EventHandler) {
+	if _, err := b.workInformer.Informer().AddEventHandler(b.EventHandlerFuncs(handler)); err != nil {
+		klog.Errorf("failed to register work informer event handler, %v", err)
+	}
+}
+
+// EventHandlerFuncs returns the ResourceEventHandlerFuncs for the KubeBackend.
+func (b *KubeBackend) EventHandlerFuncs(handler server.EventHandler) *cache.ResourceEventHandlerFuncs {
+	return &cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			id, err := workResourceID(obj)
+			if err != nil {
+				klog.Errorf("failed to get accessor for work %v", err)
+				return
+			}
+			if err := handler.OnCreate(context.Background(), payload.ManifestBundleEventDataType, id); err != nil {
+				klog.Error(err)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			id, err := workResourceID(newObj)
+			if err != nil {
+				klog.Errorf("failed to get accessor for work %v", err)
+				return
+			}
+			if err := handler.OnUpdate(context.Background(), payload.ManifestBundleEventDataType, id); err != nil {
+				klog.Error(err)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			id, err := workResourceID(obj)
+			if err != nil {
+				klog.Errorf("failed to get accessor for work %v", err)
+				return
+			}
+			if err := handler.OnDelete(context.Background(), payload.ManifestBundleEventDataType, id); err != nil {
+				klog.Error(err)
+			}
+		},
+	}
+}
+
+func workResourceID(obj interface{}) (string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", err
+	}
+	return accessor.GetNamespace() + "/" + accessor.GetName(), nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	k8smetrics "k8s.io/component-base/metrics"
+)
+
+// subsystem used to define the metrics of the router
+const routerMetricsSubsystem = "router"
+
+// Names of the labels added to metrics:
+const (
+	routerMetricsSourceLabel = "source"
+	routerMetricsReasonLabel = "reason"
+)
+
+// Values of the reason label:
+const (
+	// denyReasonClusterName means the status update has no cluster name
+	denyReasonClusterName = "cluster_name"
+	// denyReasonIdentity means the authenticated identity is not bound to the cluster of the status update
+	denyReasonIdentity = "identity"
+	// denyReasonResource means the resource does not belong to the cluster of the status update
+	denyReasonResource = "resource"
+)
+
+// Names of the router metrics:
+const (
+	statusUpdatesDeniedCountMetric = "status_updates_denied_total"
+)
+
+// routerStatusUpdatesDenied is a counter metric that tracks the number of the denied status updates by
+// the backend source and the reason.
+var routerStatusUpdatesDenied = k8smetrics.NewCounterVec(&k8smetrics.CounterOpts{
+	Subsystem:      routerMetricsSubsystem,
+	Name:           statusUpdatesDeniedCountMetric,
+	StabilityLevel: k8smetrics.ALPHA,
+	Help:           "Total number of the resource status updates denied by the router.",
+}, []string{routerMetricsSourceLabel, routerMetricsReasonLabel})
+
+// RouterMetrics returns the metrics of the router, they count the status updates that are denied before they
+// reach a backend.
+func RouterMetrics() []k8smetrics.Registerable {
+	return []k8smetrics.Registerable{
+		routerStatusUpdatesDenied,
+	}
+}
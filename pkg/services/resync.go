@@ -0,0 +1,83 @@
+package services
+
+import (
+	"sort"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+// ResourceVersions tracks the resource versions that an agent has during a resync. The resources that are
+// checked by the backends are marked as found, so the rest are the resources that have been deleted on the hub.
+type ResourceVersions struct {
+	versions map[string]int64
+	found    sets.Set[string]
+}
+
+func NewResourceVersions(versionList *payload.ResourceVersionList) *ResourceVersions {
+	versions := map[string]int64{}
+	if versionList != nil {
+		for _, rv := range versionList.Versions {
+			versions[rv.ResourceID] = rv.ResourceVersion
+		}
+	}
+
+	return &ResourceVersions{
+		versions: versions,
+		found:    sets.New[string](),
+	}
+}
+
+// Changed marks the resource as found and returns true if the agent needs the resource, that is the resource is
+// deleting, its version is not maintained (0), or the agent does not have it or has an older version of it.
+func (v *ResourceVersions) Changed(resourceID string, resourceVersion int64, deleting bool) bool {
+	v.found.Insert(resourceID)
+	if deleting || resourceVersion == 0 {
+		return true
+	}
+
+	lastResourceVersion, ok := v.versions[resourceID]
+	return !ok || resourceVersion > lastResourceVersion
+}
+
+// Filter returns the events of the resources that the agent needs, see Changed. The events without a valid
+// resource ID or resource version are dropped.
+func (v *ResourceVersions) Filter(evts []*ce.Event) []*ce.Event {
+	changed := []*ce.Event{}
+	for _, evt := range evts {
+		resourceID, err := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+		if err != nil {
+			continue
+		}
+		_, deleting := evt.Extensions()[types.ExtensionDeletionTimestamp]
+		resourceVersion, err := cloudeventstypes.ToInteger(evt.Extensions()[types.ExtensionResourceVersion])
+		if err != nil && !deleting {
+			continue
+		}
+
+		if v.Changed(resourceID, int64(resourceVersion), deleting) {
+			changed = append(changed, evt)
+		}
+	}
+	return changed
+}
+
+// Missing returns the resource versions that the agent has but none of the backends has found, ordered by
+// the resource ID.
+func (v *ResourceVersions) Missing() []payload.ResourceVersion {
+	missing := []payload.ResourceVersion{}
+	for resourceID, resourceVersion := range v.versions {
+		if v.found.Has(resourceID) {
+			continue
+		}
+		missing = append(missing, payload.ResourceVersion{ResourceID: resourceID, ResourceVersion: resourceVersion})
+	}
+
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].ResourceID < missing[j].ResourceID
+	})
+	return missing
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	cepayload "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+func newResyncEvent(resourceID string, resourceVersion int64, deleting bool) *ce.Event {
+	builder := types.NewEventBuilder("test", types.CloudEventsType{
+		CloudEventsDataType: payload.ManifestBundleEventDataType,
+		SubResource:         types.SubResourceSpec,
+	}).WithResourceID(resourceID).
+		WithResourceVersion(resourceVersion).
+		WithClusterName("cluster1")
+	if deleting {
+		builder = builder.WithDeletionTimestamp(time.Now())
+	}
+	evt := builder.NewEvent()
+	return &evt
+}
+
+func TestResourceVersions(t *testing.T) {
+	versions := NewResourceVersions(&cepayload.ResourceVersionList{
+		Versions: []cepayload.ResourceVersion{
+			{ResourceID: "r1", ResourceVersion: 1},
+			{ResourceID: "r2", ResourceVersion: 2},
+			{ResourceID: "r3", ResourceVersion: 1},
+			{ResourceID: "r4", ResourceVersion: 1},
+			{ResourceID: "r6", ResourceVersion: 3},
+		},
+	})
+
+	changed := versions.Filter([]*ce.Event{
+		// newer on the hub
+		newResyncEvent("r1", 2, false),
+		// same version on the hub
+		newResyncEvent("r2", 2, false),
+		// deleting on the hub
+		newResyncEvent("r3", 1, true),
+		// the version is not maintained on the hub
+		newResyncEvent("r4", 0, false),
+		// missing on the agent
+		newResyncEvent("r5", 1, false),
+	})
+
+	changedIDs := []string{}
+	for _, evt := range changed {
+		changedIDs = append(changedIDs, evt.Extensions()[types.ExtensionResourceID].(string))
+	}
+	if expected := []string{"r1", "r3", "r4", "r5"}; !reflect.DeepEqual(changedIDs, expected) {
+		t.Errorf("expected changed resources %v, but got %v", expected, changedIDs)
+	}
+
+	if versions.Changed("r6", 3, false) {
+		t.Errorf("expected r6 is unchanged")
+	}
+
+	if missing := versions.Missing(); len(missing) != 0 {
+		t.Errorf("expected no missing resources, but got %v", missing)
+	}
+
+	versions = NewResourceVersions(&cepayload.ResourceVersionList{
+		Versions: []cepayload.ResourceVersion{
+			{ResourceID: "r2", ResourceVersion: 2},
+			{ResourceID: "r1", ResourceVersion: 1},
+		},
+	})
+	expected := []cepayload.ResourceVersion{
+		{ResourceID: "r1", ResourceVersion: 1},
+		{ResourceID: "r2", ResourceVersion: 2},
+	}
+	if missing := versions.Missing(); !reflect.DeepEqual(missing, expected) {
+		t.Errorf("expected missing resources %v, but got %v", expected, missing)
+	}
+}
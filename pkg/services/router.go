@@ -3,74 +3,149 @@ package services
 import (
 	"context"
 	"fmt"
+	"time"
 
 	ce "github.com/cloudevents/sdk-go/v2"
 	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
-	"github.com/openshift-online/maestro/pkg/api"
-	"github.com/openshift-online/maestro/pkg/constants"
-	"github.com/openshift-online/maestro/pkg/controllers"
-	"github.com/stolostron/cloudevents-conductor/pkg/controller"
-	"github.com/stolostron/cloudevents-conductor/pkg/services/db"
-	"k8s.io/apimachinery/pkg/api/meta"
-	"k8s.io/client-go/tools/cache"
-	"k8s.io/klog/v2"
-	workinformers "open-cluster-management.io/api/client/work/informers/externalversions/work/v1"
-	"open-cluster-management.io/ocm/pkg/server/services"
-	"open-cluster-management.io/ocm/pkg/server/services/work"
-	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	"github.com/stolostron/cloudevents-conductor/pkg/audit"
+	"github.com/stolostron/cloudevents-conductor/pkg/tracing"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/payload"
 	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
 	"open-cluster-management.io/sdk-go/pkg/cloudevents/server"
 )
 
 var _ server.Service = &RouterService{}
+var _ PagedLister = &RouterService{}
 
-// RouterService implements the server.Service interface for routing the request to dbservice or workservice.
+// resyncSourceID is the source of the delete markers that are listed by ListChanged, the deleted resources
+// cannot be traced back to a backend.
+const resyncSourceID = "cloudevents-conductor"
+
+// RouterService implements the server.Service interface for routing the request to the registered backends
+// by the source prefix of the resource ID, e.g. `kube::<namespace>/<name>` is routed to the backend of the
+// `kube` source.
 type RouterService struct {
-	dbService      *db.DBWorkService
-	specController *controller.SpecControllerManager
-	workService    *work.WorkService
-	workInformer   workinformers.ManifestWorkInformer
+	backends map[string]Backend
+	// sources keeps the registration order of the backends, so the list result is stable
+	sources []string
+	// auditLogger records the operations of the router, they are not audited if it is nil
+	auditLogger *audit.Logger
 }
 
-func NewRouterService(dbService *db.DBWorkService, specController *controller.SpecControllerManager,
-	workService *work.WorkService, workInformer workinformers.ManifestWorkInformer) *RouterService {
+func NewRouterService() *RouterService {
 	return &RouterService{
-		dbService:      dbService,
-		specController: specController,
-		workService:    workService,
-		workInformer:   workInformer,
+		backends: map[string]Backend{},
 	}
 }
 
+// WithAuditLogger enables the audit of the Get, HandleStatusUpdate and the spec OnCreate/OnUpdate/OnDelete
+// of the router, it must be called before the RouterService is registered to the broker.
+func (s *RouterService) WithAuditLogger(logger *audit.Logger) *RouterService {
+	s.auditLogger = logger
+	return s
+}
+
+// Register adds a backend to the RouterService. All of the backends must be registered before the
+// RouterService is registered to the broker, the source of each backend must be unique.
+func (s *RouterService) Register(backend Backend) error {
+	source := backend.Source()
+	if len(source) == 0 {
+		return fmt.Errorf("the backend source is required")
+	}
+	if _, exists := s.backends[source]; exists {
+		return fmt.Errorf("the backend for source %s is already registered", source)
+	}
+
+	s.backends[source] = backend
+	s.sources = append(s.sources, source)
+	return nil
+}
+
+// Get the cloudEvent from the backend of the resource ID, the trace context of ctx is set to the cloudEvent,
+// so the trace is continued by the agent.
 func (s *RouterService) Get(ctx context.Context, resourceID string) (*ce.Event, error) {
-	switch {
-	case isKubeResource(resourceID):
-		id := resourceID[len(services.CloudEventsSourceKube+"::"):]
-		return s.workService.Get(ctx, id)
-	case isDBResource(resourceID):
-		id := resourceID[len(constants.DefaultSourceID+"::"):]
-		return s.dbService.Get(ctx, id)
-	default:
+	ctx, span := tracing.Start(ctx, "RouterService.Get", tracing.ResourceIDKey.String(resourceID))
+	evt, err := s.get(ctx, resourceID)
+	if evt != nil {
+		span.SetAttributes(tracing.EventAttributes(evt)...)
+		tracing.InjectContext(ctx, evt)
+	}
+	tracing.End(span, err)
+
+	s.auditLogger.Log(ctx, audit.NewEvent(audit.OperationGet, resourceID, evt, err))
+	recordSpecEvent(ctx, evt)
+	return evt, err
+}
+
+func (s *RouterService) get(ctx context.Context, resourceID string) (*ce.Event, error) {
+	backend, found := s.backendFor(resourceID)
+	if !found {
 		return nil, fmt.Errorf("unknown resource ID format: %s", resourceID)
 	}
+
+	id, err := backend.DecodeID(resourceID)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Get(ctx, id)
 }
 
-// List the cloudEvent from both kube and db service
+// List the cloudEvent from all of the registered backends, the brokers of the conductor respond the resync
+// requests with ListChanged instead, so the resources of a cluster are not loaded into memory at once.
 func (s *RouterService) List(listOpts types.ListOptions) ([]*ce.Event, error) {
-	// List the cloudEvents from kube
-	evts, err := s.workService.List(listOpts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list work resources: %w", err)
+	evts := []*ce.Event{}
+	if err := s.ListPages(context.TODO(), listOpts, func(page []*ce.Event) error {
+		evts = append(evts, page...)
+		return nil
+	}); err != nil {
+		return nil, err
 	}
 
-	// List the cloudEvents from db
-	dbEvents, err := s.dbService.List(listOpts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list db resources: %w", err)
+	return evts, nil
+}
+
+// ListPages passes the pages of the cloudEvent from the registered backends to fn in the registration
+// order of the backends.
+func (s *RouterService) ListPages(ctx context.Context, listOpts types.ListOptions, fn func(evts []*ce.Event) error) error {
+	for _, source := range s.sources {
+		if err := s.backends[source].ListPages(ctx, listOpts, fn); err != nil {
+			return fmt.Errorf("failed to list %s resources: %w", source, err)
+		}
 	}
 
-	// Combine the events from both kube and db services
-	return append(evts, dbEvents...), nil
+	return nil
+}
+
+// ListChanged passes the cloudEvent that the agent needs to resync to fn, the agent has the resources of
+// versionList. Only the resources that are deleting, missing on the agent or newer than the agent are listed
+// from the registered backends, they are followed by the delete markers of the resources that the agent has
+// but none of the backends has. It is used by both the gRPC and the message queue brokers of the conductor to
+// respond the spec resync requests.
+func (s *RouterService) ListChanged(ctx context.Context, listOpts types.ListOptions,
+	versionList *payload.ResourceVersionList, fn func(evts []*ce.Event) error) error {
+	versions := NewResourceVersions(versionList)
+	for _, source := range s.sources {
+		if err := s.backends[source].ListChangedPages(ctx, listOpts, versions, fn); err != nil {
+			return fmt.Errorf("failed to list changed %s resources: %w", source, err)
+		}
+	}
+
+	markers := []*ce.Event{}
+	for _, rv := range versions.Missing() {
+		marker := types.NewEventBuilder(resyncSourceID, types.CloudEventsType{
+			CloudEventsDataType: listOpts.CloudEventsDataType,
+			SubResource:         types.SubResourceSpec,
+		}).WithResourceID(rv.ResourceID).
+			WithResourceVersion(rv.ResourceVersion).
+			WithClusterName(listOpts.ClusterName).
+			WithDeletionTimestamp(time.Now()).
+			NewEvent()
+		markers = append(markers, &marker)
+	}
+	if len(markers) == 0 {
+		return nil
+	}
+	return fn(markers)
 }
 
 // HandleStatusUpdate processes the resource status update from the agent.
@@ -78,122 +153,61 @@ func (s *RouterService) HandleStatusUpdate(ctx context.Context, evt *ce.Event) e
 	if evt == nil {
 		return fmt.Errorf("event cannot be nil")
 	}
+
+	// continue the trace of the agent if the status update carries its trace context
+	ctx, span := tracing.Start(tracing.ExtractContext(ctx, evt), "RouterService.HandleStatusUpdate",
+		tracing.EventAttributes(evt)...)
+	err := s.handleStatusUpdate(ctx, evt)
+	tracing.End(span, err)
+
+	resourceID, _ := cloudeventstypes.ToString(evt.Extensions()[types.ExtensionResourceID])
+	s.auditLogger.Log(ctx, audit.NewEvent(audit.OperationStatusUpdate, resourceID, evt, err))
+	return err
+}
+
+func (s *RouterService) handleStatusUpdate(ctx context.Context, evt *ce.Event) error {
 	originalSource, err := cloudeventstypes.ToString(evt.Context.GetExtensions()[types.ExtensionOriginalSource])
 	if err != nil {
 		return fmt.Errorf("failed to get original source from event: %w", err)
 	}
-	switch {
-	case isKubeResource(originalSource):
-		// Handle the status update for kube resources
-		if err := s.workService.HandleStatusUpdate(ctx, evt); err != nil {
-			return fmt.Errorf("failed to handle kube resource status update: %w", err)
-		}
-	case isDBResource(originalSource):
-		// Handle the status update for db resources
-		if err := s.dbService.HandleStatusUpdate(ctx, evt); err != nil {
-			return fmt.Errorf("failed to handle db resource status update: %w", err)
-		}
-	default:
-		return fmt.Errorf("unknown resource original source: %s", originalSource)
-	}
 
-	return nil
-}
-
-// RegisterHandler registers the event handler for the RouterService.
-func (w *RouterService) RegisterHandler(handler server.EventHandler) {
-	w.specController.Add(&controllers.ControllerConfig{
-		Source:   "Resources",
-		Handlers: w.ControllerHandlerFuncs(handler),
-	})
-
-	// Register the handler for kube resource
-	if _, err := w.workInformer.Informer().AddEventHandler(w.EventHandlerFuncs(handler)); err != nil {
-		klog.Errorf("failed to register work informer event handler, %v", err)
+	backend, found := s.backendFor(originalSource)
+	if !found {
+		return fmt.Errorf("unknown resource original source: %s", originalSource)
 	}
-}
 
-// ControllerHandlerFuncs returns the ControllerHandlerFuncs for the RouterService.
-func (w *RouterService) ControllerHandlerFuncs(handler server.EventHandler) map[api.EventType][]controllers.ControllerHandlerFunc {
-	return map[api.EventType][]controllers.ControllerHandlerFunc{
-		api.CreateEventType: {func(ctx context.Context, resourceID string) error {
-			id := generateDBResourceID(constants.DefaultSourceID, resourceID)
-			return handler.OnCreate(ctx, payload.ManifestBundleEventDataType, id)
-		}},
-		api.UpdateEventType: {func(ctx context.Context, resourceID string) error {
-			id := generateDBResourceID(constants.DefaultSourceID, resourceID)
-			return handler.OnUpdate(ctx, payload.ManifestBundleEventDataType, id)
-		}},
-		api.DeleteEventType: {func(ctx context.Context, resourceID string) error {
-			id := generateDBResourceID(constants.DefaultSourceID, resourceID)
-			return handler.OnDelete(ctx, payload.ManifestBundleEventDataType, id)
-		}},
+	// reject the status updates without a cluster or from the identity of another cluster before they are
+	// handled by the backend
+	if err := authorizeStatusUpdate(ctx, backend, evt); err != nil {
+		return err
 	}
-}
 
-// EventHandlerFuncs returns the ResourceEventHandlerFuncs for the RouterService.
-func (w *RouterService) EventHandlerFuncs(handler server.EventHandler) *cache.ResourceEventHandlerFuncs {
-	return &cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			accessor, err := meta.Accessor(obj)
-			if err != nil {
-				klog.Errorf("failed to get accessor for work %v", err)
-				return
-			}
-			id := generateKubeResourceID(services.CloudEventsSourceKube, accessor.GetNamespace(), accessor.GetName())
-			if err := handler.OnCreate(context.Background(), payload.ManifestBundleEventDataType, id); err != nil {
-				klog.Error(err)
-			}
-		},
-		UpdateFunc: func(oldObj, newObj interface{}) {
-			accessor, err := meta.Accessor(newObj)
-			if err != nil {
-				klog.Errorf("failed to get accessor for work %v", err)
-				return
-			}
-			id := generateKubeResourceID(services.CloudEventsSourceKube, accessor.GetNamespace(), accessor.GetName())
-			if err := handler.OnUpdate(context.Background(), payload.ManifestBundleEventDataType, id); err != nil {
-				klog.Error(err)
-			}
-		},
-		DeleteFunc: func(obj interface{}) {
-			accessor, err := meta.Accessor(obj)
-			if err != nil {
-				klog.Errorf("failed to get accessor for work %v", err)
-				return
-			}
-			id := generateKubeResourceID(services.CloudEventsSourceKube, accessor.GetNamespace(), accessor.GetName())
-			if err := handler.OnDelete(context.Background(), payload.ManifestBundleEventDataType, id); err != nil {
-				klog.Error(err)
-			}
-		},
+	if err := backend.HandleStatusUpdate(ctx, evt); err != nil {
+		if deniedStatusUpdate(backend, evt, err) {
+			return err
+		}
+		return fmt.Errorf("failed to handle %s resource status update: %w", backend.Source(), err)
 	}
-}
-
-func generateKubeResourceID(source, namespace, name string) string {
-	// Generate a resource ID based on the source, namespace, and name
-	return fmt.Sprintf("%s::%s/%s", source, namespace, name)
-}
 
-func generateDBResourceID(source, uuid string) string {
-	// Generate a resource ID based on the source and uuid
-	return fmt.Sprintf("%s::%s", source, uuid)
+	return nil
 }
 
-func isKubeResource(resourceID string) bool {
-	if len(resourceID) == 0 {
-		return false
+// RegisterHandler registers the event handler to all of the registered backends, the resource IDs
+// from the backends are converted to the routed resource IDs before they are passed to the handler. The
+// spec events that are passed to the handler are audited with their outcomes.
+func (s *RouterService) RegisterHandler(handler server.EventHandler) {
+	for _, source := range s.sources {
+		backend := s.backends[source]
+		backend.RegisterHandler(&routedEventHandler{codec: backend, handler: handler, auditLogger: s.auditLogger})
 	}
-	// Check if the resourceID starts with "kube::" or is equal to the kube source indicating it's a kube resource
-	return resourceID == services.CloudEventsSourceKube ||
-		resourceID[:len(services.CloudEventsSourceKube+"::")] == services.CloudEventsSourceKube+"::"
 }
 
-func isDBResource(resourceID string) bool {
+// backendFor returns the backend for a routed resource ID or an original source.
+func (s *RouterService) backendFor(resourceID string) (Backend, bool) {
 	if len(resourceID) == 0 {
-		return false
+		return nil, false
 	}
-	// Check if the resourceID starts with "maestro::" or is equal to the maestro source indicating it's a DB resource
-	return resourceID == constants.DefaultSourceID ||
-		resourceID[:len(constants.DefaultSourceID+"::")] == constants.DefaultSourceID+"::"
+
+	backend, found := s.backends[sourceOf(resourceID)]
+	return backend, found
 }
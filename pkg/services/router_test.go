@@ -1,13 +1,122 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 
+	ce "github.com/cloudevents/sdk-go/v2"
 	"github.com/openshift-online/maestro/pkg/constants"
+	"github.com/stolostron/cloudevents-conductor/pkg/audit"
+	"github.com/stolostron/cloudevents-conductor/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"open-cluster-management.io/ocm/pkg/server/services"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	cepayload "open-cluster-management.io/sdk-go/pkg/cloudevents/generic/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/server"
 )
 
-func TestIsKubeResource(t *testing.T) {
+type fakeBackend struct {
+	SourceIDCodec
+	getIDs  []string
+	handler server.EventHandler
+}
+
+func newFakeBackend(source string) *fakeBackend {
+	return &fakeBackend{SourceIDCodec: NewSourceIDCodec(source)}
+}
+
+func (b *fakeBackend) Get(ctx context.Context, resourceID string) (*ce.Event, error) {
+	b.getIDs = append(b.getIDs, resourceID)
+	evt := ce.NewEvent()
+	evt.SetID(resourceID)
+	evt.SetSource(b.Source())
+	evt.SetExtension(types.ExtensionClusterName, "cluster1")
+	return &evt, nil
+}
+
+func (b *fakeBackend) List(listOpts types.ListOptions) ([]*ce.Event, error) {
+	evts := []*ce.Event{}
+	err := b.ListPages(context.Background(), listOpts, func(page []*ce.Event) error {
+		evts = append(evts, page...)
+		return nil
+	})
+	return evts, err
+}
+
+func (b *fakeBackend) ListPages(ctx context.Context, listOpts types.ListOptions, fn func(evts []*ce.Event) error) error {
+	for i := 0; i < 2; i++ {
+		evt := ce.NewEvent()
+		evt.SetSource(b.Source())
+		evt.SetID(fmt.Sprintf("%d", i))
+		evt.SetExtension(types.ExtensionResourceID, fmt.Sprintf("%s-%d", b.Source(), i))
+		evt.SetExtension(types.ExtensionResourceVersion, int64(1))
+		if err := fn([]*ce.Event{&evt}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *fakeBackend) ListChangedPages(ctx context.Context, listOpts types.ListOptions, versions *ResourceVersions,
+	fn func(evts []*ce.Event) error) error {
+	return b.ListPages(ctx, listOpts, func(evts []*ce.Event) error {
+		if changed := versions.Filter(evts); len(changed) > 0 {
+			return fn(changed)
+		}
+		return nil
+	})
+}
+
+func (b *fakeBackend) HandleStatusUpdate(ctx context.Context, evt *ce.Event) error {
+	return nil
+}
+
+func (b *fakeBackend) RegisterHandler(handler server.EventHandler) {
+	b.handler = handler
+}
+
+type fakeEventHandler struct {
+	createdIDs []string
+	// service gets the created resources as the brokers do before they send them, if it is not nil
+	service server.Service
+}
+
+func (h *fakeEventHandler) OnCreate(ctx context.Context, t types.CloudEventsDataType, resourceID string) error {
+	h.createdIDs = append(h.createdIDs, resourceID)
+	if h.service != nil {
+		if _, err := h.service.Get(ctx, resourceID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *fakeEventHandler) OnUpdate(ctx context.Context, t types.CloudEventsDataType, resourceID string) error {
+	return nil
+}
+
+func (h *fakeEventHandler) OnDelete(ctx context.Context, t types.CloudEventsDataType, resourceID string) error {
+	return nil
+}
+
+func newTestRouterService(t *testing.T, backends ...Backend) *RouterService {
+	router := NewRouterService()
+	for _, backend := range backends {
+		if err := router.Register(backend); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return router
+}
+
+func TestBackendForKubeResource(t *testing.T) {
 	tests := []struct {
 		name       string
 		resourceID string
@@ -45,17 +154,19 @@ func TestIsKubeResource(t *testing.T) {
 		},
 	}
 
+	router := newTestRouterService(t, newFakeBackend(services.CloudEventsSourceKube), newFakeBackend(constants.DefaultSourceID))
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := isKubeResource(tt.resourceID)
+			backend, found := router.backendFor(tt.resourceID)
+			got := found && backend.Source() == services.CloudEventsSourceKube
 			if got != tt.want {
-				t.Errorf("isKubeResource(%q) = %v, want %v", tt.resourceID, got, tt.want)
+				t.Errorf("backendFor(%q) is kube = %v, want %v", tt.resourceID, got, tt.want)
 			}
 		})
 	}
 }
 
-func TestIsDBResource(t *testing.T) {
+func TestBackendForDBResource(t *testing.T) {
 	tests := []struct {
 		name       string
 		resourceID string
@@ -88,12 +199,209 @@ func TestIsDBResource(t *testing.T) {
 		},
 	}
 
+	router := newTestRouterService(t, newFakeBackend(services.CloudEventsSourceKube), newFakeBackend(constants.DefaultSourceID))
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := isDBResource(tt.resourceID)
+			backend, found := router.backendFor(tt.resourceID)
+			got := found && backend.Source() == constants.DefaultSourceID
 			if got != tt.want {
-				t.Errorf("isDBResource(%q) = %v, want %v", tt.resourceID, got, tt.want)
+				t.Errorf("backendFor(%q) is db = %v, want %v", tt.resourceID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterBackend(t *testing.T) {
+	router := NewRouterService()
+	if err := router.Register(newFakeBackend("")); err == nil {
+		t.Errorf("expected error for empty source, but got nil")
+	}
+	if err := router.Register(newFakeBackend("gitops")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := router.Register(newFakeBackend("gitops")); err == nil {
+		t.Errorf("expected error for duplicated source, but got nil")
+	}
+}
+
+func TestRouterServiceFanOut(t *testing.T) {
+	kube := newFakeBackend(services.CloudEventsSourceKube)
+	gitops := newFakeBackend("gitops")
+	router := newTestRouterService(t, kube, gitops)
+
+	if _, err := router.Get(context.Background(), "gitops::app/foo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gitops.getIDs) != 1 || gitops.getIDs[0] != "app/foo" {
+		t.Errorf("expected the gitops backend gets app/foo, but got %v", gitops.getIDs)
+	}
+	if len(kube.getIDs) != 0 {
+		t.Errorf("expected the kube backend is not called, but got %v", kube.getIDs)
+	}
+	if _, err := router.Get(context.Background(), "unknown::foo"); err == nil {
+		t.Errorf("expected error for unknown source, but got nil")
+	}
+
+	evts, err := router.List(types.ListOptions{ClusterName: "cluster1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(evts) != 4 || evts[0].Source() != services.CloudEventsSourceKube || evts[3].Source() != "gitops" {
+		t.Errorf("expected events from kube and gitops in order, but got %v", evts)
+	}
+
+	handler := &fakeEventHandler{}
+	router.RegisterHandler(handler)
+	if err := gitops.handler.OnCreate(context.Background(), payload.ManifestBundleEventDataType, "app/foo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(handler.createdIDs) != 1 || handler.createdIDs[0] != "gitops::app/foo" {
+		t.Errorf("expected the routed resource ID gitops::app/foo, but got %v", handler.createdIDs)
+	}
+}
+
+func TestRouterServiceListPages(t *testing.T) {
+	router := newTestRouterService(t, newFakeBackend(services.CloudEventsSourceKube), newFakeBackend("gitops"))
+
+	pages := []string{}
+	err := router.ListPages(context.Background(), types.ListOptions{ClusterName: "cluster1"}, func(evts []*ce.Event) error {
+		for _, evt := range evts {
+			pages = append(pages, evt.Source()+"/"+evt.ID())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"kube/0", "kube/1", "gitops/0", "gitops/1"}
+	if fmt.Sprint(pages) != fmt.Sprint(expected) {
+		t.Errorf("expected pages %v, but got %v", expected, pages)
+	}
+
+	// the listing is stopped once fn fails
+	pages = []string{}
+	err = router.ListPages(context.Background(), types.ListOptions{ClusterName: "cluster1"}, func(evts []*ce.Event) error {
+		pages = append(pages, evts[0].Source()+"/"+evts[0].ID())
+		return fmt.Errorf("send failed")
+	})
+	if err == nil || err.Error() != "failed to list kube resources: send failed" {
+		t.Errorf("expected the send error, but got %v", err)
+	}
+	if len(pages) != 1 {
+		t.Errorf("expected the listing is stopped after the first page, but got %v", pages)
+	}
+}
+
+func TestRouterServiceListChanged(t *testing.T) {
+	router := newTestRouterService(t, newFakeBackend(services.CloudEventsSourceKube), newFakeBackend("gitops"))
+
+	listed := []string{}
+	err := router.ListChanged(context.Background(),
+		types.ListOptions{ClusterName: "cluster1", CloudEventsDataType: payload.ManifestBundleEventDataType},
+		&cepayload.ResourceVersionList{Versions: []cepayload.ResourceVersion{
+			{ResourceID: "kube-0", ResourceVersion: 1},
+			{ResourceID: "gitops-1", ResourceVersion: 0},
+			{ResourceID: "gone", ResourceVersion: 3},
+		}},
+		func(evts []*ce.Event) error {
+			for _, evt := range evts {
+				resourceID := evt.Extensions()[types.ExtensionResourceID].(string)
+				if _, ok := evt.Extensions()[types.ExtensionDeletionTimestamp]; ok {
+					resourceID = "delete " + resourceID
+				}
+				listed = append(listed, resourceID)
 			}
+			return nil
 		})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// kube-0 is unchanged on the agent, gone does not exist on any backend
+	expected := []string{"kube-1", "gitops-0", "gitops-1", "delete gone"}
+	if fmt.Sprint(listed) != fmt.Sprint(expected) {
+		t.Errorf("expected listed resources %v, but got %v", expected, listed)
+	}
+}
+
+func TestRouterServiceAudit(t *testing.T) {
+	buf := &bytes.Buffer{}
+	gitops := newFakeBackend("gitops")
+	router := newTestRouterService(t, gitops).WithAuditLogger(audit.NewWriterLogger(buf))
+	router.RegisterHandler(&fakeEventHandler{service: router})
+
+	if _, err := router.Get(context.Background(), "gitops::app/foo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := router.Get(context.Background(), "unknown::foo"); err == nil {
+		t.Errorf("expected error for unknown source, but got nil")
+	}
+	if err := gitops.handler.OnCreate(context.Background(), payload.ManifestBundleEventDataType, "app/foo"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status := ce.NewEvent()
+	status.SetExtension(types.ExtensionResourceID, "app/foo")
+	status.SetExtension(types.ExtensionOriginalSource, "gitops")
+	status.SetExtension(types.ExtensionClusterName, "cluster1")
+	if err := router.HandleStatusUpdate(context.Background(), &status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	actual := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		event := audit.Event{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		actual = append(actual, fmt.Sprintf("%s %s %s %s %s", event.Operation, event.ResourceID, event.Source,
+			event.ClusterName, event.Outcome))
+	}
+	// the spec that is sent to the agent is audited with the cluster of the spec that is got by the broker
+	expected := []string{
+		"get gitops::app/foo gitops cluster1 success",
+		"get unknown::foo   failure",
+		"get gitops::app/foo gitops cluster1 success",
+		"create gitops::app/foo gitops cluster1 success",
+		"status_update app/foo gitops cluster1 success",
+	}
+	if fmt.Sprint(actual) != fmt.Sprint(expected) {
+		t.Errorf("expected audit events %v, but got %v", expected, actual)
+	}
+}
+
+func TestRouterServiceTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(tracing.NewTracerProvider(sdktrace.NewSimpleSpanProcessor(exporter), sdktrace.AlwaysSample()))
+	defer otel.SetTracerProvider(original)
+
+	router := newTestRouterService(t, newFakeBackend("gitops"))
+
+	// the trace context of the spec is carried to the agent
+	evt, err := router.Get(context.Background(), "gitops::app/foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := evt.Extensions()["traceparent"]; !ok {
+		t.Errorf("expected the traceparent extension, but got %v", evt.Extensions())
+	}
+
+	// the status update continues the trace of the agent
+	status := ce.NewEvent()
+	status.SetExtension(types.ExtensionResourceID, "app/foo")
+	status.SetExtension(types.ExtensionOriginalSource, "gitops")
+	status.SetExtension(types.ExtensionClusterName, "cluster1")
+	status.SetExtension("traceparent", evt.Extensions()["traceparent"])
+	if err := router.HandleStatusUpdate(context.Background(), &status); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 || spans[0].Name != "RouterService.Get" || spans[1].Name != "RouterService.HandleStatusUpdate" {
+		t.Fatalf("expected the get and status update spans, but got %v", spans)
+	}
+	if spans[1].Parent.SpanID() != spans[0].SpanContext.SpanID() {
+		t.Errorf("expected the status update span is a child of the get span")
 	}
 }
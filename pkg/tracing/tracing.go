@@ -0,0 +1,168 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	cloudeventstypes "github.com/cloudevents/sdk-go/v2/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+const (
+	tracerName  = "github.com/stolostron/cloudevents-conductor"
+	serviceName = "cloudevents-conductor"
+)
+
+// The attribute keys of the spans.
+const (
+	ResourceIDKey      = attribute.Key("conductor.resource.id")
+	ResourceVersionKey = attribute.Key("conductor.resource.version")
+	ClusterNameKey     = attribute.Key("conductor.cluster.name")
+	EventTypeKey       = attribute.Key("conductor.event.type")
+	EventIDKey         = attribute.Key("conductor.event.id")
+	EventSourceKey     = attribute.Key("conductor.event.source")
+	EventActionKey     = attribute.Key("conductor.event.action")
+)
+
+// propagator propagates the trace context with the W3C traceparent and tracestate, they are carried by the
+// cloudevent extensions of the same names, see the distributed tracing extension of the cloudevents.
+var propagator = propagation.TraceContext{}
+
+// Options defines the options of the tracing.
+type Options struct {
+	// Endpoint is the host:port of the OTLP gRPC receiver that the spans are exported to.
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+
+	// Insecure disables the TLS of the connection to the OTLP receiver.
+	Insecure bool `json:"insecure,omitempty" yaml:"insecure,omitempty"`
+
+	// SamplingRatio is the ratio of the traces started by the conductor that are sampled, it is greater than 0
+	// and at most 1. A trace that is continued from a sampled span of the agent is always sampled.
+	SamplingRatio float64 `json:"sampling_ratio,omitempty" yaml:"sampling_ratio,omitempty"`
+}
+
+func NewOptions() *Options {
+	return &Options{
+		SamplingRatio: 1,
+	}
+}
+
+// Validate validates the options, the errors are reported with the given field path.
+func (o *Options) Validate(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if o.Endpoint == "" {
+		errs = append(errs, field.Required(fldPath.Child("endpoint"), ""))
+	}
+	if o.SamplingRatio <= 0 || o.SamplingRatio > 1 {
+		errs = append(errs, field.Invalid(fldPath.Child("sampling_ratio"), o.SamplingRatio,
+			"must be greater than 0 and at most 1"))
+	}
+	return errs
+}
+
+// Setup exports the spans to the OTLP receiver of the options, the returned function flushes the pending
+// spans and stops the export. The spans are discarded if Setup is not called.
+func Setup(ctx context.Context, options *Options) (func(context.Context) error, error) {
+	clientOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(options.Endpoint)}
+	if options.Insecure {
+		clientOptions = append(clientOptions, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, clientOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	provider := NewTracerProvider(sdktrace.NewBatchSpanProcessor(exporter),
+		sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SamplingRatio)))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider creates the tracer provider of the conductor with the span processor, e.g. a syncer of
+// the in-memory exporter in the tests.
+func NewTracerProvider(processor sdktrace.SpanProcessor, sampler sdktrace.Sampler) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+}
+
+// Start starts a span of the conductor with the attributes.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error on the span if it is not nil and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// EventAttributes returns the resource ID, the resource version, the cluster name and the type of the
+// cloudevent as the span attributes, the missing ones are skipped.
+func EventAttributes(evt *ce.Event) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{EventTypeKey.String(evt.Type())}
+	extensions := evt.Extensions()
+	if resourceID, err := cloudeventstypes.ToString(extensions[types.ExtensionResourceID]); err == nil {
+		attrs = append(attrs, ResourceIDKey.String(resourceID))
+	}
+	if resourceVersion, err := cloudeventstypes.ToInteger(extensions[types.ExtensionResourceVersion]); err == nil {
+		attrs = append(attrs, ResourceVersionKey.Int64(int64(resourceVersion)))
+	}
+	if clusterName, err := cloudeventstypes.ToString(extensions[types.ExtensionClusterName]); err == nil {
+		attrs = append(attrs, ClusterNameKey.String(clusterName))
+	}
+	return attrs
+}
+
+// InjectContext sets the trace context of ctx to the cloudevent extensions, so the agent can continue the
+// trace. Nothing is set if ctx has no valid span.
+func InjectContext(ctx context.Context, evt *ce.Event) {
+	propagator.Inject(ctx, eventCarrier{evt: evt})
+}
+
+// ExtractContext returns a copy of ctx with the remote span of the trace context in the cloudevent
+// extensions, ctx is returned if the cloudevent has no trace context.
+func ExtractContext(ctx context.Context, evt *ce.Event) context.Context {
+	return propagator.Extract(ctx, eventCarrier{evt: evt})
+}
+
+// eventCarrier adapts the cloudevent extensions to a propagation.TextMapCarrier.
+type eventCarrier struct {
+	evt *ce.Event
+}
+
+func (c eventCarrier) Get(key string) string {
+	value, err := cloudeventstypes.ToString(c.evt.Extensions()[key])
+	if err != nil {
+		return ""
+	}
+	return value
+}
+
+func (c eventCarrier) Set(key, value string) {
+	c.evt.SetExtension(key, value)
+}
+
+func (c eventCarrier) Keys() []string {
+	keys := []string{}
+	for key := range c.evt.Extensions() {
+		keys = append(keys, key)
+	}
+	return keys
+}
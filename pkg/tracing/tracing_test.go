@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	ce "github.com/cloudevents/sdk-go/v2"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
+)
+
+// setupInMemoryExporter records the spans of the test in memory.
+func setupInMemoryExporter(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	original := otel.GetTracerProvider()
+	otel.SetTracerProvider(NewTracerProvider(sdktrace.NewSimpleSpanProcessor(exporter), sdktrace.AlwaysSample()))
+	t.Cleanup(func() { otel.SetTracerProvider(original) })
+	return exporter
+}
+
+func TestOptionsValidate(t *testing.T) {
+	options := NewOptions()
+	options.Endpoint = "otel-collector:4317"
+	assert.Empty(t, options.Validate(field.NewPath("tracing")))
+
+	options.Endpoint = ""
+	options.SamplingRatio = 0
+	fields := []string{}
+	for _, err := range options.Validate(field.NewPath("tracing")) {
+		fields = append(fields, err.Field)
+	}
+	assert.Equal(t, []string{"tracing.endpoint", "tracing.sampling_ratio"}, fields)
+}
+
+func TestPropagation(t *testing.T) {
+	exporter := setupInMemoryExporter(t)
+
+	evt := types.NewEventBuilder("maestro", types.CloudEventsType{
+		CloudEventsDataType: payload.ManifestBundleEventDataType,
+		SubResource:         types.SubResourceSpec,
+		Action:              types.CreateRequestAction,
+	}).WithResourceID("r1").
+		WithResourceVersion(2).
+		WithClusterName("cluster1").
+		NewEvent()
+
+	// nothing is injected without a span
+	InjectContext(context.Background(), &evt)
+	assert.NotContains(t, evt.Extensions(), "traceparent")
+	assert.Equal(t, context.Background(), ExtractContext(context.Background(), &evt))
+
+	ctx, span := Start(context.Background(), "spec", EventAttributes(&evt)...)
+	InjectContext(ctx, &evt)
+	End(span, nil)
+	assert.Contains(t, evt.Extensions(), "traceparent")
+
+	// the agent sends the status with the trace context of the spec
+	status := ce.NewEvent()
+	status.SetExtension("traceparent", evt.Extensions()["traceparent"])
+	_, statusSpan := Start(ExtractContext(context.Background(), &status), "status")
+	End(statusSpan, fmt.Errorf("failed"))
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "spec", spans[0].Name)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		EventTypeKey.String("io.open-cluster-management.works.v1alpha1.manifestbundles.spec.create_request"),
+		ResourceIDKey.String("r1"),
+		ResourceVersionKey.Int64(2),
+		ClusterNameKey.String("cluster1"),
+	}, spans[0].Attributes)
+
+	assert.Equal(t, "status", spans[1].Name)
+	assert.Equal(t, spans[0].SpanContext.TraceID(), spans[1].SpanContext.TraceID())
+	assert.Equal(t, spans[0].SpanContext.SpanID(), spans[1].Parent.SpanID())
+	assert.Equal(t, trace.SpanKindInternal, spans[1].SpanKind)
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+	assert.Equal(t, "failed", spans[1].Status.Description)
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/openshift-online/maestro/pkg/api"
 	"github.com/openshift-online/maestro/pkg/services"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/clients/work/payload"
+	"open-cluster-management.io/sdk-go/pkg/cloudevents/generic/types"
 )
 
 // FindConsumerByName checks if a consumer with the given name exists in the maestro service.
@@ -33,3 +35,39 @@ func CreateConsumer(ctx context.Context, consumerService services.ConsumerServic
 
 	return nil
 }
+
+// DeleteConsumer deletes the consumer with the given name from the maestro service,
+// it does nothing if the consumer does not exist.
+func DeleteConsumer(ctx context.Context, consumerService services.ConsumerService, consumerName string) error {
+	consumers, svcErr := consumerService.FindByNames(ctx, []string{consumerName})
+	if svcErr != nil {
+		if svcErr.Is404() {
+			return nil
+		}
+		return fmt.Errorf("failed to get consumers by name %s: %w", consumerName, svcErr)
+	}
+	for _, consumer := range consumers {
+		if consumer.Name != consumerName {
+			continue
+		}
+
+		if svcErr := consumerService.Delete(ctx, consumer.ID); svcErr != nil && !svcErr.Is404() {
+			return fmt.Errorf("failed to delete consumer %s: %w", consumerName, svcErr)
+		}
+	}
+
+	return nil
+}
+
+// ListConsumerResources lists the manifest bundle resources of the consumer with the given name from the maestro service.
+func ListConsumerResources(resourceService services.ResourceService, consumerName string) ([]*api.Resource, error) {
+	resources, err := resourceService.List(types.ListOptions{
+		ClusterName:         consumerName,
+		CloudEventsDataType: payload.ManifestBundleEventDataType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resources of consumer %s: %w", consumerName, err)
+	}
+
+	return resources, nil
+}
@@ -4,9 +4,10 @@ import "context"
 
 // MockMessageQueueAuthzCreator is a mock implementation of MessageQueueAuthzCreator
 // that can be used in tests to verify the behavior of components that depend on it.
-// It records the cluster name for which authorizations are created or deleted.
+// It records the cluster names for which authorizations are created or deleted.
 type MockMessageQueueAuthzCreator struct {
-	clusterName string
+	clusterName        string
+	deletedClusterName string
 }
 
 func NewMockMessageQueueAuthzCreator() *MockMessageQueueAuthzCreator {
@@ -19,9 +20,14 @@ func (a *MockMessageQueueAuthzCreator) CreateAuthorizations(ctx context.Context,
 }
 
 func (a *MockMessageQueueAuthzCreator) DeleteAuthorizations(ctx context.Context, clusterName string) error {
+	a.deletedClusterName = clusterName
 	return nil
 }
 
 func (a *MockMessageQueueAuthzCreator) ClusterName() string {
 	return a.clusterName
 }
+
+func (a *MockMessageQueueAuthzCreator) DeletedClusterName() string {
+	return a.deletedClusterName
+}
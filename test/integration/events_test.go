@@ -0,0 +1,190 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/openshift-online/maestro/pkg/api"
+	"k8s.io/apimachinery/pkg/util/rand"
+
+	"github.com/stolostron/cloudevents-conductor/pkg/cli/events"
+	dbevent "github.com/stolostron/cloudevents-conductor/pkg/services/db/event"
+)
+
+const eventsTest = "eventstest"
+
+var _ = Describe("Events commands", Ordered, Label("events-test"), func() {
+	var ctx context.Context
+	var source string
+
+	// createEvent creates an event of the test source, the test events are skipped by the conductor since no
+	// controller is registered for the source.
+	createEvent := func() string {
+		event, svcErr := dbevent.NewEventService(maestroInstance.SessionFactory()).Create(ctx, &api.Event{
+			Source:    source,
+			SourceID:  rand.String(10),
+			EventType: api.UpdateEventType,
+		})
+		Expect(svcErr).ToNot(HaveOccurred())
+		return event.ID
+	}
+
+	reconcileEvent := func(eventID string, reconciledAgo time.Duration) {
+		Expect(maestroInstance.SessionFactory().New(ctx).
+			Exec("UPDATE events SET reconciled_date = ? WHERE id = ?", time.Now().Add(-reconciledAgo), eventID).
+			Error).ToNot(HaveOccurred())
+	}
+
+	eventExists := func(eventID string) bool {
+		var count int64
+		Expect(maestroInstance.SessionFactory().New(ctx).
+			Raw("SELECT count(*) FROM events WHERE id = ?", eventID).Scan(&count).Error).ToNot(HaveOccurred())
+		return count == 1
+	}
+
+	newEventsOptions := func() *events.EventsOptions {
+		options := events.NewEventsOptions()
+		options.GRPCServerConfigFile = grpcServerConfigFile
+		return options
+	}
+
+	BeforeAll(func() {
+		ctx = context.Background()
+		source = fmt.Sprintf("%s-%s", eventsTest, rand.String(5))
+		Expect(dbevent.NewPoisonedEventStore(maestroInstance.SessionFactory()).Migrate(ctx)).To(Succeed())
+	})
+
+	AfterAll(func() {
+		Expect(maestroInstance.SessionFactory().New(ctx).
+			Exec("DELETE FROM events WHERE source = ?", source).Error).ToNot(HaveOccurred())
+	})
+
+	Context("Replay", func() {
+		var channel string
+		var notified chan string
+
+		BeforeEach(func() {
+			// the events are replayed on a channel of the test, so they are not handled by the conductor
+			channel = fmt.Sprintf("%s_%s", eventsTest, rand.String(5))
+			notified = make(chan string, 10)
+			listenerCtx, stop := context.WithCancel(ctx)
+			DeferCleanup(stop)
+			maestroInstance.SessionFactory().NewListener(listenerCtx, channel, func(id string) { notified <- id })
+		})
+
+		It("notifies the unreconciled events", func() {
+			eventID := createEvent()
+
+			options := newEventsOptions()
+			options.Channel = channel
+			out := &bytes.Buffer{}
+			Expect(options.RunReplay(ctx, out, []string{eventID})).To(Succeed())
+			Expect(out.String()).To(Equal(fmt.Sprintf("event %s is replayed\n", eventID)))
+			Eventually(notified, eventuallyTimeout, eventuallyInterval).Should(Receive(Equal(eventID)))
+		})
+
+		It("does not notify the events in the dry run", func() {
+			eventID := createEvent()
+
+			options := newEventsOptions()
+			options.Channel = channel
+			options.DryRun = true
+			out := &bytes.Buffer{}
+			Expect(options.RunReplay(ctx, out, []string{eventID})).To(Succeed())
+			Expect(out.String()).To(Equal(fmt.Sprintf("event %s would be replayed (dry run)\n", eventID)))
+			Consistently(notified, 2*time.Second, 500*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("rejects the reconciled events", func() {
+			eventID := createEvent()
+			reconcileEvent(eventID, time.Minute)
+
+			options := newEventsOptions()
+			options.Channel = channel
+			err := options.RunReplay(ctx, &bytes.Buffer{}, []string{eventID})
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("event %s was reconciled at", eventID))))
+			Consistently(notified, 2*time.Second, 500*time.Millisecond).ShouldNot(Receive())
+		})
+
+		It("rejects the poisoned events", func() {
+			eventID := createEvent()
+			store := dbevent.NewPoisonedEventStore(maestroInstance.SessionFactory())
+			Expect(store.Poison(ctx, &dbevent.PoisonedEvent{
+				EventID:    eventID,
+				Source:     source,
+				EventType:  string(api.UpdateEventType),
+				Attempts:   1,
+				LastError:  "test",
+				PoisonedAt: time.Now(),
+			})).To(Succeed())
+			DeferCleanup(func() {
+				Expect(store.Delete(ctx, eventID)).To(Succeed())
+			})
+
+			options := newEventsOptions()
+			options.Channel = channel
+			err := options.RunReplay(ctx, &bytes.Buffer{}, []string{eventID})
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("event %s is poisoned", eventID))))
+			Consistently(notified, 2*time.Second, 500*time.Millisecond).ShouldNot(Receive())
+		})
+	})
+
+	Context("Purge", func() {
+		// the purged events are reconciled days ago, so the events of the other tests are not purged
+		var oldEvents []string
+		var recentEvent, pendingEvent string
+
+		BeforeEach(func() {
+			oldEvents = []string{createEvent(), createEvent()}
+			for _, eventID := range oldEvents {
+				reconcileEvent(eventID, 72*time.Hour)
+			}
+			recentEvent = createEvent()
+			reconcileEvent(recentEvent, time.Hour)
+			pendingEvent = createEvent()
+		})
+
+		It("counts the events that would be purged in the dry run", func() {
+			options := newEventsOptions()
+			options.DryRun = true
+			options.OlderThan = 48 * time.Hour
+			out := &bytes.Buffer{}
+			Expect(options.RunPurge(ctx, out)).To(Succeed())
+			Expect(out.String()).To(HavePrefix("2 events reconciled before "))
+			Expect(out.String()).To(HaveSuffix(" would be purged (dry run)\n"))
+
+			for _, eventID := range append(oldEvents, recentEvent, pendingEvent) {
+				Expect(eventExists(eventID)).To(BeTrue())
+			}
+		})
+
+		It("purges the events that were reconciled longer ago than the older-than duration", func() {
+			options := newEventsOptions()
+			options.OlderThan = 48 * time.Hour
+			options.PurgeBatchSize = 1
+			out := &bytes.Buffer{}
+			Expect(options.RunPurge(ctx, out)).To(Succeed())
+			Expect(out.String()).To(HavePrefix("2 events reconciled before "))
+
+			for _, eventID := range oldEvents {
+				Expect(eventExists(eventID)).To(BeFalse())
+			}
+			Expect(eventExists(recentEvent)).To(BeTrue())
+			Expect(eventExists(pendingEvent)).To(BeTrue())
+		})
+
+		It("rejects the negative older-than duration", func() {
+			options := newEventsOptions()
+			options.OlderThan = -time.Hour
+			Expect(options.RunPurge(ctx, &bytes.Buffer{})).To(MatchError(ContainSubstring("must not be negative")))
+
+			for _, eventID := range oldEvents {
+				Expect(eventExists(eventID)).To(BeTrue())
+			}
+		})
+	})
+})
@@ -74,6 +74,7 @@ var bootstrapGRPCConfigFile string
 var stopHub context.CancelFunc
 
 var stopGRPCServer context.CancelFunc
+var grpcServerConfigFile string
 
 var gRPCServerOptions *grpcserver.GRPCServerOptions
 var gRPCCAKeyFile string
@@ -279,6 +280,7 @@ func runGRPCServer(name string, gRPCServerConfig *grpcserver.GRPCServerOptions,
 		klog.Fatalf("Failed to marshal grpc server config: %v", err)
 	}
 	serverConfigFile := path.Join(util.TestDir, "grpcserver", "server-config.yaml")
+	grpcServerConfigFile = serverConfigFile
 	if err := os.MkdirAll(path.Dir(serverConfigFile), 0755); err != nil {
 		klog.Fatalf("Failed to create directory for grpc server config file %s: %v", serverConfigFile, err)
 	}
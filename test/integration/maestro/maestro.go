@@ -187,3 +187,7 @@ func (m *Maestro) ResetDB(ctx context.Context) error {
 func (m *Maestro) ResourceService() services.ResourceService {
 	return m.resources
 }
+
+func (m *Maestro) SessionFactory() db.SessionFactory {
+	return m.dbFactory
+}
@@ -0,0 +1,172 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/openshift-online/maestro/pkg/constants"
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db/consumer"
+	"github.com/stolostron/cloudevents-conductor/pkg/services/db/streamowner"
+	maestroutils "github.com/stolostron/cloudevents-conductor/pkg/utils/maestro"
+	"github.com/stolostron/cloudevents-conductor/test/helper"
+	"k8s.io/apimachinery/pkg/util/rand"
+)
+
+const streamOwnerTest = "streamownertest"
+
+var _ = Describe("Stream ownership of the conductor instances", Ordered, Label("stream-ownership-test"), func() {
+	var ctx context.Context
+	var options *streamowner.Options
+	var instanceA, instanceB string
+	var registryA, registryB *streamowner.Registry
+
+	ownerOf := func(clusterName string) string {
+		owners := []string{}
+		Expect(maestroInstance.SessionFactory().New(ctx).
+			Raw("SELECT instance_id FROM conductor_stream_owners WHERE cluster_name = ?", clusterName).
+			Scan(&owners).Error).ToNot(HaveOccurred())
+		if len(owners) == 0 {
+			return ""
+		}
+		return owners[0]
+	}
+
+	BeforeAll(func() {
+		ctx = context.Background()
+		postfix := rand.String(5)
+		options = &streamowner.Options{HeartbeatInterval: time.Second, Expiration: 3 * time.Second}
+		instanceA = fmt.Sprintf("%s-instance-a-%s", streamOwnerTest, postfix)
+		instanceB = fmt.Sprintf("%s-instance-b-%s", streamOwnerTest, postfix)
+		registryA = streamowner.NewRegistry(maestroInstance.SessionFactory(), instanceA, options)
+		registryB = streamowner.NewRegistry(maestroInstance.SessionFactory(), instanceB, options)
+		Expect(registryA.Migrate(ctx)).To(Succeed())
+	})
+
+	AfterAll(func() {
+		Expect(registryA.Close(ctx)).To(Succeed())
+		Expect(registryB.Close(ctx)).To(Succeed())
+	})
+
+	It("releases the cluster after the last stream of it is closed", func() {
+		clusterName := fmt.Sprintf("%s-cluster-%s", streamOwnerTest, rand.String(5))
+
+		Expect(registryA.Acquire(ctx, clusterName)).To(Succeed())
+		Expect(registryA.Acquire(ctx, clusterName)).To(Succeed())
+		Expect(ownerOf(clusterName)).To(Equal(instanceA))
+
+		Expect(registryA.Release(ctx, clusterName)).To(Succeed())
+		Expect(ownerOf(clusterName)).To(Equal(instanceA))
+
+		Expect(registryA.Release(ctx, clusterName)).To(Succeed())
+		Expect(ownerOf(clusterName)).To(BeEmpty())
+	})
+
+	It("takes over the cluster by the latest stream and claims it again after the ownership is expired", func() {
+		clusterName := fmt.Sprintf("%s-cluster-%s", streamOwnerTest, rand.String(5))
+
+		Expect(registryA.Acquire(ctx, clusterName)).To(Succeed())
+		Expect(registryB.Acquire(ctx, clusterName)).To(Succeed())
+		Expect(ownerOf(clusterName)).To(Equal(instanceB))
+
+		runCtx, stop := context.WithCancel(ctx)
+		defer stop()
+		go registryA.Run(runCtx)
+		runCtxB, stopB := context.WithCancel(ctx)
+		defer stopB()
+		go registryB.Run(runCtxB)
+
+		By("keeping the cluster of the live instance on the renew", func() {
+			Consistently(func() string {
+				return ownerOf(clusterName)
+			}, 2*options.HeartbeatInterval, options.HeartbeatInterval/2).Should(Equal(instanceB))
+		})
+
+		By("claiming the cluster again on the renew after the ownership is expired", func() {
+			stopB()
+			Expect(maestroInstance.SessionFactory().New(ctx).
+				Exec("UPDATE conductor_stream_owners SET renewed_at = now() - interval '1 hour' WHERE cluster_name = ?",
+					clusterName).Error).ToNot(HaveOccurred())
+			Eventually(func() string {
+				return ownerOf(clusterName)
+			}, eventuallyTimeout, eventuallyInterval).Should(Equal(instanceA))
+		})
+
+		// the release of the stale stream keeps the cluster of the live owner
+		Expect(registryB.Release(ctx, clusterName)).To(Succeed())
+		Expect(ownerOf(clusterName)).To(Equal(instanceA))
+		Expect(registryA.Release(ctx, clusterName)).To(Succeed())
+	})
+
+	It("owns the events of the clusters that are not owned by the other instances", func() {
+		consumerService := consumer.NewConsumerService(maestroInstance.SessionFactory())
+		postfix := rand.String(5)
+		ownedCluster := fmt.Sprintf("%s-owned-%s", streamOwnerTest, postfix)
+		otherCluster := fmt.Sprintf("%s-other-%s", streamOwnerTest, postfix)
+		unownedCluster := fmt.Sprintf("%s-unowned-%s", streamOwnerTest, postfix)
+
+		eventOf := map[string]string{}
+		for _, clusterName := range []string{ownedCluster, otherCluster, unownedCluster} {
+			Expect(maestroutils.CreateConsumer(ctx, consumerService, clusterName)).To(Succeed())
+			res, err := helper.NewResource(clusterName, constants.DefaultSourceID, 1, 1)
+			Expect(err).ToNot(HaveOccurred())
+			res, svcErr := resourceService.Create(ctx, res)
+			Expect(svcErr).ToNot(HaveOccurred())
+
+			eventIDs := []string{}
+			Expect(maestroInstance.SessionFactory().New(ctx).
+				Raw("SELECT id FROM events WHERE source_id = ?", res.ID).Scan(&eventIDs).Error).ToNot(HaveOccurred())
+			Expect(eventIDs).To(HaveLen(1))
+			eventOf[clusterName] = eventIDs[0]
+		}
+
+		Expect(registryA.Acquire(ctx, ownedCluster)).To(Succeed())
+		Expect(registryB.Acquire(ctx, otherCluster)).To(Succeed())
+		defer func() {
+			Expect(registryA.Release(ctx, ownedCluster)).To(Succeed())
+			Expect(registryB.Release(ctx, otherCluster)).To(Succeed())
+		}()
+
+		By("owning all of the events before the clusters of the other instances are loaded", func() {
+			owned, err := registryA.OwnsEvent(ctx, eventOf[otherCluster])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(owned).To(BeTrue())
+		})
+
+		runCtx, stop := context.WithCancel(ctx)
+		defer stop()
+		go registryA.Run(runCtx)
+		runCtxB, stopB := context.WithCancel(ctx)
+		defer stopB()
+		go registryB.Run(runCtxB)
+
+		By("skipping the events of the clusters of the other instances after they are loaded", func() {
+			Eventually(func() (bool, error) {
+				return registryA.OwnsEvent(ctx, eventOf[otherCluster])
+			}, eventuallyTimeout, eventuallyInterval).Should(BeFalse())
+
+			for _, clusterName := range []string{ownedCluster, unownedCluster} {
+				owned, err := registryA.OwnsEvent(ctx, eventOf[clusterName])
+				Expect(err).ToNot(HaveOccurred())
+				Expect(owned).To(BeTrue())
+			}
+
+			// the events that are not found are owned by all of the instances
+			owned, err := registryA.OwnsEvent(ctx, "missing")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(owned).To(BeTrue())
+		})
+
+		By("owning the events of the cluster after the ownership of the other instance is expired", func() {
+			stopB()
+			Expect(maestroInstance.SessionFactory().New(ctx).
+				Exec("UPDATE conductor_stream_owners SET renewed_at = now() - interval '1 hour' WHERE cluster_name = ?",
+					otherCluster).Error).ToNot(HaveOccurred())
+			Eventually(func() (bool, error) {
+				return registryA.OwnsEvent(ctx, eventOf[otherCluster])
+			}, eventuallyTimeout, eventuallyInterval).Should(BeTrue())
+		})
+	})
+})